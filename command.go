@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/wamuir/go-jsonapi-server/config"
+	"github.com/wamuir/go-jsonapi-server/ndjson"
+)
+
+// Run the subcommand name, with args:
+//
+//	export [file]                             write the graph to file (or stdout) as NDJSON
+//	import [-batch n] [file]                  read the graph from file (or stdin) as NDJSON
+//	migrate                                   migrate the schema of the graph
+//	migrate -from dsn -to dsn [-batch n]      copy the graph of one DSN to another, and verify it
+//
+// The graph is that of config.DSN, unless given.  A copy is of the graph as
+// is, with its times, revisions, tombstoned resources and history, and is
+// refused unless the graph copied to is empty.  The graph copied from is
+// not written: one of an older schema is refused, rather than migrated in
+// place, and is to be migrated first.
+func command(name string, args []string, logger *log.Logger) error {
+
+	var (
+		batch    int
+		from, to string
+	)
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	switch name {
+	case "import":
+		flags.IntVar(&batch, "batch", 1000, "number of lines imported per transaction")
+	case "migrate":
+		flags.IntVar(&batch, "batch", 1000, "number of resources copied per transaction")
+		flags.StringVar(&from, "from", "", "DSN of the graph to copy from")
+		flags.StringVar(&to, "to", "", "DSN of the graph to copy to")
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	} else if flags.NArg() > 1 || name == "migrate" && flags.NArg() > 0 {
+		return fmt.Errorf("%s: too many arguments", name)
+	}
+
+	progress := func(vertices, edges int64) {
+		logger.Printf("%sed %d vertices and %d edges", name, vertices, edges)
+	}
+
+	switch name {
+
+	case "export":
+		g, err := connect(config.DSN.String(), config.MigrateOnStart)
+		if err != nil {
+			return err
+		}
+		defer g.Close()
+
+		var w io.Writer = os.Stdout
+		if flags.NArg() == 1 {
+			f, err := os.Create(flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		err = ndjson.Export(context.Background(), g, w, progress)
+		if err != nil {
+			return err
+		}
+		if f, ok := w.(*os.File); ok && f != os.Stdout {
+			return f.Close()
+		}
+		return nil
+
+	case "import":
+		g, err := connect(config.DSN.String(), config.MigrateOnStart)
+		if err != nil {
+			return err
+		}
+		defer g.Close()
+
+		var r io.Reader = os.Stdin
+		if flags.NArg() == 1 {
+			f, err := os.Open(flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		return ndjson.Import(context.Background(), g, r, batch, progress)
+
+	case "migrate":
+		if from == "" && to == "" {
+			dsn := config.DSN.String()
+			before, after, err := backendOf(dsn).migrate(dsn)
+			if err != nil {
+				return err
+			}
+			logger.Printf("migrated schema from version %d to %d", before, after)
+			return nil
+		} else if from == "" || to == "" {
+			return errors.New("migrate: -from and -to are given together")
+		}
+
+		src, err := connect(from, false)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		dst, err := connect(to, true)
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+
+		summary, err := ndjson.Copy(context.Background(), src, dst, batch, func(vertices, edges int64) {
+			logger.Printf("copied %d vertices and %d edges", vertices, edges)
+		})
+		if err != nil {
+			return err
+		}
+		logger.Printf("verified %s", summary)
+		return nil
+
+	default:
+		return errors.New("unknown command " + name + "; want export, import or migrate")
+	}
+}
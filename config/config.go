@@ -1,6 +1,7 @@
 package config
 
 import (
+	"github.com/wamuir/go-jsonapi-server/graph"
 	"github.com/wamuir/go-jsonapi-server/model"
 	"net/url"
 	"path/filepath"
@@ -33,9 +34,114 @@ var (
 	ListenPort int    = 8080
 )
 
+// Cache-Control for successful responses to GET and HEAD, by route pattern.
+// Routes not listed take the policy of "*".  Responses carry an entity tag
+// (ETag) and, where known, Last-Modified, so that clients holding a cached
+// representation may revalidate it by If-None-Match or If-Modified-Since and
+// be answered 304 Not Modified.
+//
+//  routes:  /{type}/, /{type}/{id}/, /{type}/{id}/{related},
+//           /{type}/{id}/relationships/{relationship},
+//           /{type}/{id}/-/history, /{type}/{id}/-/restore
+//
+//  example:  "*":         "no-cache",
+//            "/{type}/":  "private, max-age=5",
+//
+var CachePolicy = map[string]string{
+	"*": "no-cache",
+}
+
+// Request header naming the actor to whom changes are attributed in the
+// history of resources (GET /{type}/{id}/-/history), as set by a proxy that
+// authenticates requests.  Leave empty to attribute changes to no actor.
+// Changes are also attributed to the ID of the request, from X-Request-Id
+// or else generated.
+//
+//  example:  X-Forwarded-User
+//
+var ActorHeader string = ""
+
+// Soft delete.  If enabled, resources deleted (DELETE /{type}/{id}, or
+// removed by an atomic operation) are tombstoned: hidden, along with their
+// relationships, until restored (POST /{type}/{id}/-/restore) or purged.
+// Tombstones older than the retention period are purged at each interval.
+// All times are in hours.
+//
+//      enabled:  whether resources deleted are tombstoned
+//    retention:  the minimum age of a tombstone before it is purged
+//     interval:  the time between purges
+//
+var (
+	SoftDelete     bool = false
+	RetentionHours int  = 30 * 24
+	PurgeHours     int  = 1
+)
+
+// Change feed (GET /_changes), as Server-Sent Events.  The feed is polled
+// for committed changes at each interval.  Streams end after the timeout,
+// which must be less than the write timeout of the HTTP server, and clients
+// reconnect, resuming from the last event received.  All times are in
+// milliseconds.
+//
+//     interval:  the time between polls of the feed
+//      timeout:  the maximum duration of a stream
+//
+var (
+	ChangesInterval int = 500
+	ChangesTimeout  int = 4000
+)
+
+// Outbox.  Each change is also written to an outbox, in the transaction
+// making it, for consumers such as webhooks, and deleted once acked by every
+// consumer.  While no consumer is registered, changes older than the
+// retention are purged at each interval.  All times are in hours.
+//
+//    retention:  the minimum age of a change in the outbox before it is
+//                purged, while no consumer is registered
+//     interval:  the time between purges
+//
+var (
+	OutboxRetentionHours int = 7 * 24
+	OutboxPurgeHours     int = 1
+)
+
+// Webhooks.  Subscriptions are resources of the type given, managed as any
+// other (e.g., POST /webhooks/), with attributes url, secret, resourceType,
+// relationship (optional) and events (optional: create, update, delete, add
+// and remove).  Payloads are signed with the secret, which is write-only:
+// omitted from responses, and not to be filtered or sorted on.  Leave the
+// type empty to disable.  Deliveries are made only to URLs of scheme http
+// or https and of an allowed host, not following redirects, so that
+// subscriptions may not reach hosts internal to the server.  Deliveries not
+// accepted are attempted again after the backoff, doubling for each
+// attempt, up to the maximum, and are dead once out of attempts.  All times
+// are in seconds.
+//
+//          type:  the resource type of subscriptions
+//                 e.g., webhooks
+//         hosts:  the hosts to which deliveries may be made, or, if
+//                 prefixed by "*.", any of their subdomains
+//                 e.g., hooks.example.com, *.example.org
+//      attempts:  the maximum number of attempts at a delivery
+//       backoff:  the time before the second attempt at a delivery
+//    maxBackoff:  the maximum time between attempts at a delivery
+//      interval:  the time between polls for changes and deliveries due
+//       timeout:  the maximum duration of an attempt at a delivery
+//
+var (
+	WebhookType       string   = ""
+	WebhookHosts      []string = nil
+	WebhookAttempts   int      = 8
+	WebhookBackoff    int      = 10
+	WebhookMaxBackoff int      = 3600
+	WebhookInterval   int      = 1
+	WebhookTimeout    int      = 10
+)
+
 // Data source name (DSN) for connection to backend data store.
 //
 //      sqlite3:  see github.com/mattn/go-sqlite3 for additional info
+//       memory:  "memory:", for an empty graph held in memory and lost on exit
 //        other:  reference the relevant documentation
 //
 var DSN = url.URL{
@@ -48,6 +154,22 @@ var DSN = url.URL{
 	}.Encode(),
 }
 
+// Whether to migrate the schema of the backend data store at startup.  If
+// false, the server refuses to start until the schema is migrated, by the
+// migrate command.  Either way, it refuses to start against a schema newer
+// than it supports.
+//
+var MigrateOnStart bool = true
+
+// Directory of JSON schemas for the attributes and meta of resources, by
+// resource type, named <type>.attributes.json and <type>.meta.json.  Each
+// resource posted or patched is validated against the schemas for its type,
+// if any.  Leave empty for none.
+//
+//  example:  /etc/jsonapi/schemas
+//
+var SchemaDir string = ""
+
 // Timeouts for the HTTP server.  All times are in seconds.
 //
 //         read:  the maximum duration for reading the entire request,
@@ -66,18 +188,53 @@ var (
 
 // Query parameters.
 //
+// filter[path]:  for filtering collections of resources by attribute, with
+//                an optional operator (eq, ne, lt, le, gt, ge, in, like, null)
+//                e.g., ?filter[author.name]=Alice, ?filter[age][ge]=21
 //      include:  for inclusion of resources related to primary data
 //                e.g., ?include=contractor,contractor.subcontractors
-//  page[limit]:  page size for paginated collections of resources
+// fields[type]:  sparse fieldsets, the attributes and relationships to
+//                return for resources of a type
+//                e.g., ?fields[people]=name,age
+//   page[size]:  page size for collections paginated by cursor
+//                e.g., ?page[size]=10
+//  page[after]:  cursor for the page following a resource, as given in the
+//                links of a paginated collection
+// page[before]:  cursor for the page preceding a resource, as given in the
+//                links of a paginated collection
+//  page[limit]:  page size for collections paginated by offset
 //                e.g., ?page[limit]=10
-// page[offset]:  page offset for paginated collections of resources
+// page[offset]:  page offset for collections paginated by offset
 //                e.g., ?page[offset]=0
+//         sort:  for sorting collections of resources by one or more
+//                attributes, descending if prefixed with a minus
+//                e.g., ?sort=-createdAt,title,id
+//
+// Collections are paginated by offset, with page[limit] and page[offset],
+// unless page[size] is allowed.  For pagination by cursor instead, allow
+// page[size], page[after] and page[before]; as offset parameters are then
+// refused, this breaks clients paginating by offset, which is why offset
+// stays the default.  Large collections, whose later pages are slow to
+// reach by offset, are better paginated by cursor, with links to cursors
+// throughout.
 //
 var Parameters = model.Parameters{
+	"fields": model.Parameter{
+		Allowed: true,
+	},
+	"filter": model.Parameter{
+		Allowed: true,
+	},
 	"include": model.Parameter{
 		Allowed: true,
 		Maximum: 3, // Maximum depth for traversal
 	},
+	"page[after]": model.Parameter{
+		Allowed: false,
+	},
+	"page[before]": model.Parameter{
+		Allowed: false,
+	},
 	"page[limit]": model.Parameter{
 		Allowed: true,
 		Default: 10,
@@ -90,7 +247,26 @@ var Parameters = model.Parameters{
 		Minimum: 0,
 		Maximum: 1<<63 - 1,
 	},
+	"page[size]": model.Parameter{
+		Allowed: false,
+		Default: 10,
+		Minimum: 1,
+		Maximum: 1<<63 - 1,
+	},
 	"sort": model.Parameter{
 		Allowed: true,
 	},
 }
+
+// Cardinality of relationships, keyed by resource type and then by
+// relationship key.  Relationships not declared here take the cardinality
+// of the first data member written to them: to-one for a resource object
+// and to-many for an array.  A declaration does not override a cardinality
+// already held by the graph.
+//
+//  example:  "articles": {
+//                "author":   graph.ToOne,
+//                "comments": graph.ToMany,
+//            },
+//
+var Relationships = map[string]map[string]graph.Cardinality{}
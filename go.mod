@@ -6,7 +6,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/go-chi/chi/v5 v5.0.3
 	github.com/lib/pq v1.10.2
-	github.com/mattn/go-sqlite3 v1.14.7
+	github.com/mattn/go-sqlite3 v1.14.12
 	github.com/rs/xid v1.3.0
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/wamuir/go-jsonapi-core v0.0.0-20201229124324-23efe398b23e
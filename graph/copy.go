@@ -0,0 +1,25 @@
+package graph
+
+// Copier reads and writes a graph as is, so that it may be copied to
+// another graph, of any backend, with the times and revisions of its
+// vertices and edges, its tombstoned vertices and its history.
+//
+// DumpVertices calls fn with each vertex, tombstoned or not, in order of
+// insertion, and DumpEdges with each edge, from or to a tombstoned vertex
+// or not, with its position, of which the vertices have only their types
+// and IDs.  DumpChanges calls fn with each change of the history, in order
+// of ID.  Each returns the first error of fn, if any, once fn returns it.
+//
+// LoadVertex, LoadEdge and LoadChange write a vertex, edge or change as
+// dumped, with its times and revision, the time a vertex was tombstoned,
+// and the ID of a change, and record no change of their own.  Edges are
+// loaded after the vertices from and to which they are, and changes in
+// order of ID, each after any before it.
+type Copier interface {
+	DumpChanges(fn func(Change) error) error
+	DumpEdges(fn func(Edge) error) error
+	DumpVertices(fn func(Vertex) error) error
+	LoadChange(change Change) error
+	LoadEdge(edge Edge) error
+	LoadVertex(vertex Vertex) error
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"time"
+)
+
+// DeliveryState is the state of a Delivery.
+type DeliveryState string
+
+const (
+	// Yet to be delivered, at NextAttempt
+	Pending DeliveryState = "pending"
+
+	// Delivered, at the last attempt
+	Delivered DeliveryState = "delivered"
+
+	// Not delivered, with no attempts left
+	Dead DeliveryState = "dead"
+)
+
+// Delivery is the delivery of a Change, as the payload of an event, to the
+// webhook subscribed to it by the vertex of SubscriptionType and
+// SubscriptionID.  Each attempt at delivery is logged as a DeliveryAttempt.
+type Delivery struct {
+	ID               int64
+	SubscriptionType string
+	SubscriptionID   string
+	ChangeID         int64
+	Event            string
+	Payload          []byte
+	State            DeliveryState
+	Attempts         int
+	NextAttempt      time.Time
+	Created          time.Time
+	Updated          time.Time
+}
+
+// DeliveryAttempt is an attempt at a Delivery, with the status code of the
+// response to it, if any, or else the error.
+type DeliveryAttempt struct {
+	DeliveryID int64
+	Time       time.Time
+	StatusCode int
+	Error      string
+}
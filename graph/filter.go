@@ -0,0 +1,30 @@
+package graph
+
+// Operator is a comparison applied by a Filter.
+type Operator string
+
+const (
+	Equal              Operator = "eq"
+	NotEqual           Operator = "ne"
+	LessThan           Operator = "lt"
+	LessThanOrEqual    Operator = "le"
+	GreaterThan        Operator = "gt"
+	GreaterThanOrEqual Operator = "ge"
+	In                 Operator = "in"
+	Like               Operator = "like"
+	Null               Operator = "null"
+	NotNull            Operator = "notnull"
+)
+
+// Filter is a condition on a vertex attribute, addressed by Path from the
+// root of the attributes object (e.g., ["author", "name"]), or on the vertex
+// identifier when Path is ["id"], or on the time of creation or last update,
+// in TimeFormat, when Path is CreatedPath or UpdatedPath.  Values are
+// strings, float64s or bools.  Operators Equal through Like compare against
+// the first of Values, except In, which matches any.  Null and NotNull take
+// no values.
+type Filter struct {
+	Path     []string
+	Operator Operator
+	Values   []interface{}
+}
@@ -3,13 +3,18 @@ package graph
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 type Edge struct {
-	From Vertex
-	To   Vertex
-	Key  string
-	Meta []byte
+	From     Vertex
+	To       Vertex
+	Key      string
+	Meta     []byte
+	Position int       // Order among edges of Key from From, if found by FindEdge, FindEdges or DumpEdges
+	Created  time.Time // Zero if written before times were kept
+	Updated  time.Time
+	Cursor   string // Position within a page, if found by FindEdges
 }
 
 type Vertex struct {
@@ -17,11 +22,49 @@ type Vertex struct {
 	Identifier string
 	Attributes []byte
 	Meta       []byte
+	Revision   int64     // Incremented on each update, if found by FindVertex
+	Created    time.Time // Zero if written before times were kept
+	Updated    time.Time
+	Tombstoned time.Time // Zero unless tombstoned, if found by DumpVertices
+	Cursor     string    // Position within a page, if found by FindVertices
 }
 
+// TimeFormat is the layout of the times at which vertices and edges were
+// created and updated, as held by the graph and compared by filters and
+// sort keys.  Times are in UTC, so that the layout sorts chronologically.
+const TimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// Paths of filters and sort keys for the times at which vertices were
+// created and last updated, rather than for attributes.
+var (
+	CreatedPath = []string{"meta", "created"}
+	UpdatedPath = []string{"meta", "updated"}
+)
+
+// Cardinality is the declared cardinality of the relationships keyed by an
+// edge key, for vertices of a type.  The zero value is undeclared.
+type Cardinality string
+
+const (
+	ToOne  Cardinality = "one"
+	ToMany Cardinality = "many"
+)
+
 var (
 	ErrNoRows   = errors.New("no rows in result set")
 	ErrConflict = errors.New("unique constraint violation in graph")
+
+	// DeleteVertex and UpdateVertex, given a nonzero revision, fail with
+	// ErrStaleRevision if the vertex is of another revision
+	ErrStaleRevision = errors.New("revision of vertex is stale")
+
+	ErrInvalidCursor = errors.New("invalid cursor for page")
+
+	// Connecting to a database of which the schema is of a version after
+	// the last migration known fails with ErrSchemaNewer, and opening one
+	// of a version before it, without migrating, with ErrSchemaOlder
+	ErrSchemaNewer = errors.New("schema of graph is newer than supported")
+	ErrSchemaOlder = errors.New("schema of graph is older than supported")
 )
 
 type Graph interface {
@@ -29,18 +72,67 @@ type Graph interface {
 	Transaction(ctx context.Context, readOnly bool) (Tx, error)
 }
 
+// Tx is a transaction on a graph.  Each insert, update and delete of a
+// vertex or edge within it, including edges deleted with a vertex, is
+// recorded as a Change, attributed by the Audit of the context with which
+// the transaction was begun, and written to its Outbox.
+//
+// TombstoneVertex deletes a vertex softly: the vertex, and edges from or to
+// it, are hidden from every read and write until restored by RestoreVertex
+// or deleted by PurgeVertices, once tombstoned before the time given.
+//
+// FindChangesAfter returns changes of any vertex, or of vertices of the
+// types given, with IDs greater than that given, in order of ID, up to
+// limit.  Only committed changes are found, and a change is found only once
+// every change with a lesser ID is committed or rolled back, so that the
+// ID of the last change found may be given to resume from it.
+// FindLastChangeID returns the greatest ID of a change so found, or zero.
+//
+// Deliveries of changes to webhooks are inserted pending, due at their next
+// attempt or else at once, and once only for each subscription and change.  Those pending
+// and due at a time are found by FindDueDeliveries, in order of due time.
+// ClaimDueDeliveries finds them likewise and defers the next attempt of each
+// to until, its lease, so that no other claim finds it due until then; of
+// transactions claiming at once, each delivery is claimed by one alone.
+// UpdateDelivery updates the state, attempts and next attempt of delivery.
 type Tx interface {
+	Copier
+	Outbox
+	ClaimDueDeliveries(due, until time.Time, limit int64) ([]Delivery, error)
 	Close() error
 	Commit() error
-	CountRelatedVertices(fromVertexType, fromVertexID, key string) (int64, error)
-	CountVertices(vertexType string) (int64, error)
+	CountChanges(vertexType, vertexID string) (int64, error)
+	CountRelatedVertices(fromVertexType, fromVertexID, key string, filters []Filter) (int64, error)
+	CountVertices(vertexType string, filters []Filter) (int64, error)
+	DeclareEdgeKey(fromVertexType, key string, cardinality Cardinality) error
 	DeleteEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) error
-	DeleteVertex(vertexType, vertexID string) error
+	DeleteEdges(fromVertexType, fromVertexID, key string) error
+	DeleteVertex(vertexType, vertexID string, revision int64) error
+	FindAttributeKeys(vertexType string) ([]string, error)
+	FindChanges(vertexType, vertexID string, page Page) ([]Change, error)
+	FindChangesAfter(id int64, vertexTypes []string, limit int64) ([]Change, error)
+	FindDeliveries(subscriptionType, subscriptionID string, page Page) ([]Delivery, error)
+	FindDeliveryAttempts(deliveryID int64) ([]DeliveryAttempt, error)
 	FindDistinctEdgeKeys(fromVertexType, fromVertexID string) ([]string, error)
+	FindDueDeliveries(due time.Time, limit int64) ([]Delivery, error)
 	FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) (Edge, error)
-	FindEdges(fromVertexType, fromVertexID, key string, limit, offset int64) ([]Edge, error)
+	FindEdgeKey(fromVertexType, key string) (Cardinality, error)
+	FindEdgeKeys(fromVertexType string) ([]string, error)
+	FindEdges(fromVertexType, fromVertexID, key string, filters []Filter, sort []SortKey, page Page) ([]Edge, error)
+	FindLastChangeID() (int64, error)
+	FindRelatedAttributeKeys(fromVertexType, fromVertexID, key string) ([]string, error)
 	FindVertex(vertexType, vertexID string) (Vertex, error)
-	FindVertices(vertexType string, limit, offset int64, sort string) ([]Vertex, error)
+	FindVertexType(vertexType string) error
+	FindVertexTypes() ([]string, error)
+	FindVertices(vertexType string, filters []Filter, sort []SortKey, page Page) ([]Vertex, error)
+	InsertDelivery(delivery Delivery) error
+	InsertDeliveryAttempt(attempt DeliveryAttempt) error
 	InsertEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error
 	InsertVertex(vertexType, vertexID string, attributes, meta []byte) error
+	PurgeVertices(tombstonedBefore time.Time) (int64, error)
+	RestoreVertex(vertexType, vertexID string) error
+	TombstoneVertex(vertexType, vertexID string, revision int64) error
+	UpdateDelivery(delivery Delivery) error
+	UpdateEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error
+	UpdateVertex(vertexType, vertexID string, revision int64, attributes, meta []byte) error
 }
@@ -0,0 +1,1071 @@
+// Package graphtest is the conformance suite of the backends of graph: each
+// is tested against the same expectations, so that each behaves as any
+// other.
+package graphtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Run runs each test of the suite as a subtest of t, with a graph opened by
+// open, which is to be empty, and closed once the test is done.
+func Run(t *testing.T, open func() graph.Graph) {
+
+	for _, test := range []struct {
+		name string
+		fn   func(*testing.T, graph.Graph)
+	}{
+		{"InsertEdge", testInsertEdge},
+		{"InsertVertex", testInsertVertex},
+		{"UpdateVertex", testUpdateVertex},
+		{"DeleteVertex", testDeleteVertex},
+		{"DeleteEdge", testDeleteEdge},
+		{"UpdateEdge", testUpdateEdge},
+		{"DeleteEdges", testDeleteEdges},
+		{"CountVertices", testCountVertices},
+		{"FindVertices", testFindVertices},
+		{"FindVerticesFiltered", testFindVerticesFiltered},
+		{"FindVerticesSorted", testFindVerticesSorted},
+		{"FindVerticesPaged", testFindVerticesPaged},
+		{"FindVertexType", testFindVertexType},
+		{"FindVertex", testFindVertex},
+		{"FindDistinctEdgeKeys", testFindDistinctEdgeKeys},
+		{"FindAttributeKeys", testFindAttributeKeys},
+		{"FindEdgeKeys", testFindEdgeKeys},
+		{"DeclareEdgeKey", testDeclareEdgeKey},
+		{"FindRelatedAttributeKeys", testFindRelatedAttributeKeys},
+		{"CountRelatedVertices", testCountRelatedVertices},
+		{"FindEdges", testFindEdges},
+		{"Edge", testEdge},
+		{"Timestamps", testTimestamps},
+		{"History", testHistory},
+		{"Tombstones", testTombstones},
+		{"FindChangesAfter", testFindChangesAfter},
+		{"Outbox", testOutbox},
+		{"ClaimDueDeliveries", testClaimDueDeliveries},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			g := open()
+			defer g.Close()
+
+			test.fn(t, g)
+		})
+	}
+}
+
+func testInsertEdge(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+
+	if err := tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testInsertVertex(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	if err := tx.InsertVertex("typeA", "idA", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testUpdateVertex(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"a":"b"}`), nil)
+
+	if err := tx.UpdateVertex("typeA", "idA", 0, []byte(`{"a":"c"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := tx.FindVertex("typeA", "idA")
+	if err != nil {
+		t.Fatal(err)
+	} else if string(v.Attributes) != `{"a":"c"}` {
+		t.Fatalf("Got %s, want %s", v.Attributes, `{"a":"c"}`)
+	} else if v.Revision != 2 {
+		t.Fatalf("Got revision %d, want %d", v.Revision, 2)
+	}
+
+	// Conditional on revision
+	if err := tx.UpdateVertex("typeA", "idA", 1, nil, nil); err != graph.ErrStaleRevision {
+		t.Fatalf("Got %v, want %v", err, graph.ErrStaleRevision)
+	}
+
+	if err := tx.UpdateVertex("typeA", "idA", 2, []byte(`{"a":"d"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.UpdateVertex("typeA", "idB", 0, nil, nil); err != graph.ErrNoRows {
+		t.Fatalf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+}
+
+func testDeleteVertex(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+
+	if err := tx.DeleteVertex("typeA", "idA", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Conditional on revision
+	if err := tx.DeleteVertex("typeA", "idB", 2); err != graph.ErrStaleRevision {
+		t.Fatalf("Got %v, want %v", err, graph.ErrStaleRevision)
+	}
+
+	if err := tx.DeleteVertex("typeA", "idB", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.DeleteVertex("typeA", "idB", 1); err != graph.ErrNoRows {
+		t.Fatalf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+}
+
+func testDeleteEdge(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+
+	if err := tx.DeleteEdge("typeA", "idA", "typeB", "idB", "key"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testUpdateEdge(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+
+	before, _ := tx.FindEdge("typeA", "idA", "typeB", "idB", "key")
+
+	if err := tx.UpdateEdge("typeA", "idA", "typeB", "idB", "key", 1, []byte(`{"a":"b"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := tx.FindEdge("typeA", "idA", "typeB", "idB", "key")
+	if err != nil {
+		t.Fatal(err)
+	} else if e.Position != 1 || string(e.Meta) != `{"a":"b"}` {
+		t.Fatalf("Got position %d and meta %s, want %d and %s", e.Position, e.Meta, 1, `{"a":"b"}`)
+	} else if !e.Created.Equal(before.Created) {
+		t.Fatalf("Got created %v, want %v", e.Created, before.Created)
+	}
+
+	if err := tx.UpdateEdge("typeA", "idA", "typeB", "idB", "other", 0, nil); err != graph.ErrNoRows {
+		t.Fatalf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+}
+
+func testDeleteEdges(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 1, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyB", 0, nil)
+
+	if err := tx.DeleteEdges("typeA", "idA", "keyA"); err != nil {
+		t.Fatal(err)
+	}
+
+	if i, _ := tx.CountRelatedVertices("typeA", "idA", "keyA", nil); i != 0 {
+		t.Fatalf("Got %d, want %d", i, 0)
+	} else if i, _ := tx.CountRelatedVertices("typeA", "idA", "keyB", nil); i != 1 {
+		t.Fatalf("Got %d, want %d", i, 1)
+	}
+}
+
+func testCountVertices(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+
+	i, err := tx.CountVertices("typeA", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if i != 2 {
+		t.Fatalf("Got %d, want %d", i, 2)
+	}
+}
+
+func testFindVertices(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+
+	v, err := tx.FindVertices("typeA", nil, nil, graph.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 {
+		t.Fatalf("%v", v)
+	}
+}
+
+func testFindVerticesFiltered(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"n":1,"s":"abc","o":{"p":true}}`), nil)
+	_ = tx.InsertVertex("typeA", "idB", []byte(`{"n":2,"s":"abd","o":{"p":false}}`), nil)
+	_ = tx.InsertVertex("typeA", "idC", []byte(`{"n":3}`), nil)
+	_ = tx.InsertVertex("typeA", "idD", nil, nil)
+
+	tests := []struct {
+		filter graph.Filter
+		want   int
+	}{
+		{graph.Filter{Path: []string{"n"}, Operator: graph.Equal, Values: []interface{}{float64(2)}}, 1},
+		{graph.Filter{Path: []string{"n"}, Operator: graph.NotEqual, Values: []interface{}{float64(2)}}, 3},
+		{graph.Filter{Path: []string{"n"}, Operator: graph.GreaterThan, Values: []interface{}{float64(1)}}, 2},
+		{graph.Filter{Path: []string{"n"}, Operator: graph.LessThanOrEqual, Values: []interface{}{float64(2)}}, 2},
+		{graph.Filter{Path: []string{"n"}, Operator: graph.In, Values: []interface{}{float64(1), float64(3)}}, 2},
+		{graph.Filter{Path: []string{"s"}, Operator: graph.Like, Values: []interface{}{"ab%"}}, 2},
+		{graph.Filter{Path: []string{"s"}, Operator: graph.Null}, 2},
+		{graph.Filter{Path: []string{"s"}, Operator: graph.NotNull}, 2},
+		{graph.Filter{Path: []string{"o", "p"}, Operator: graph.Equal, Values: []interface{}{true}}, 1},
+		{graph.Filter{Path: []string{"id"}, Operator: graph.Equal, Values: []interface{}{"idD"}}, 1},
+	}
+
+	for _, test := range tests {
+
+		filters := []graph.Filter{test.filter}
+
+		v, err := tx.FindVertices("typeA", filters, nil, graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(v) != test.want {
+			t.Errorf("%v: got %d, want %d", test.filter, len(v), test.want)
+		}
+
+		i, err := tx.CountVertices("typeA", filters)
+		if err != nil {
+			t.Fatal(err)
+		} else if i != int64(test.want) {
+			t.Errorf("%v: got %d, want %d", test.filter, i, test.want)
+		}
+	}
+}
+
+func testFindVerticesSorted(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"n":2,"o":{"p":"x"}}`), nil)
+	_ = tx.InsertVertex("typeA", "idB", []byte(`{"n":1,"o":{"p":"y"}}`), nil)
+	_ = tx.InsertVertex("typeA", "idC", []byte(`{"n":2,"o":{"p":"z"}}`), nil)
+	_ = tx.InsertVertex("typeA", "idD", nil, nil)
+
+	tests := []struct {
+		sort []graph.SortKey
+		want []string
+	}{
+		{nil, []string{"idA", "idB", "idC", "idD"}},
+		{[]graph.SortKey{{Path: []string{"n"}}}, []string{"idB", "idA", "idC", "idD"}},
+		{[]graph.SortKey{{Path: []string{"n"}, Descending: true}}, []string{"idD", "idA", "idC", "idB"}},
+		{[]graph.SortKey{{Path: []string{"n"}, Descending: true}, {Path: []string{"id"}, Descending: true}}, []string{"idD", "idC", "idA", "idB"}},
+		{[]graph.SortKey{{Path: []string{"o", "p"}, Descending: true}}, []string{"idD", "idC", "idB", "idA"}},
+	}
+
+	for _, test := range tests {
+
+		v, err := tx.FindVertices("typeA", nil, test.sort, graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, vertex := range v {
+			got = append(got, vertex.Identifier)
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%v: got %v, want %v", test.sort, got, test.want)
+		}
+	}
+}
+
+func testFindVerticesPaged(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"n":2}`), nil)
+	_ = tx.InsertVertex("typeA", "idB", []byte(`{"n":1.5}`), nil)
+	_ = tx.InsertVertex("typeA", "idC", []byte(`{"n":2}`), nil)
+	_ = tx.InsertVertex("typeA", "idD", nil, nil)
+	_ = tx.InsertVertex("typeA", "idE", []byte(`{"n":"x"}`), nil)
+
+	sorts := [][]graph.SortKey{
+		nil,
+		{{Path: []string{"n"}}},
+		{{Path: []string{"n"}, Descending: true}},
+		{{Path: []string{"n"}, Descending: true}, {Path: []string{"id"}, Descending: true}},
+	}
+
+	for _, sort := range sorts {
+
+		all, err := tx.FindVertices("typeA", nil, sort, graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Forward, by page[after]
+		var page graph.Page = graph.Page{Limit: 2}
+		for i := 0; i < len(all); i += 2 {
+			v, err := tx.FindVertices("typeA", nil, sort, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j, vertex := range v {
+				if vertex.Identifier != all[i+j].Identifier {
+					t.Errorf("%v: got %s at %d, want %s", sort, vertex.Identifier, i+j, all[i+j].Identifier)
+				}
+			}
+			page.After = v[len(v)-1].Cursor
+		}
+
+		// Backward, by page[before]
+		page = graph.Page{Limit: 2, Before: all[len(all)-1].Cursor}
+		for i := len(all) - 1; i > 0; i -= 2 {
+			v, err := tx.FindVertices("typeA", nil, sort, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j, vertex := range v {
+				if k := i - len(v) + j; vertex.Identifier != all[k].Identifier {
+					t.Errorf("%v: got %s at %d, want %s", sort, vertex.Identifier, k, all[k].Identifier)
+				}
+			}
+			page.Before = v[0].Cursor
+		}
+	}
+
+	_, err := tx.FindVertices("typeA", nil, nil, graph.Page{Limit: 2, After: "invalid"})
+	if err != graph.ErrInvalidCursor {
+		t.Errorf("got %v, want %v", err, graph.ErrInvalidCursor)
+	}
+}
+
+func testFindVertexType(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.DeleteVertex("typeA", "idA", 0)
+
+	err := tx.FindVertexType("typeA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.FindVertexType("typeB")
+	if err != graph.ErrNoRows {
+		t.Fatalf("got %v, want %v", err, graph.ErrNoRows)
+	}
+}
+
+func testFindVertex(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+
+	_, err := tx.FindVertex("typeA", "idA")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testFindDistinctEdgeKeys(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyB", 0, nil)
+
+	e, err := tx.FindDistinctEdgeKeys("typeA", "idA")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(e) != 2 {
+		t.Fatalf("%v", e)
+	}
+}
+
+func testFindAttributeKeys(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"a":1,"b":2}`), nil)
+	_ = tx.InsertVertex("typeA", "idB", []byte(`{"b":3,"c":4}`), nil)
+	_ = tx.InsertVertex("typeA", "idC", nil, nil)
+	_ = tx.InsertVertex("typeB", "idD", []byte(`{"d":5}`), nil)
+
+	k, err := tx.FindAttributeKeys("typeA")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(k) != 3 {
+		t.Fatalf("%v", k)
+	}
+}
+
+func testFindEdgeKeys(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idB", "typeC", "idC", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idB", "typeC", "idC", "keyB", 0, nil)
+
+	k, err := tx.FindEdgeKeys("typeA")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(k) != 2 {
+		t.Fatalf("%v", k)
+	}
+
+	// Keys remain known once their edges are deleted
+	_ = tx.DeleteEdges("typeA", "idB", "keyB")
+
+	k, err = tx.FindEdgeKeys("typeA")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(k) != 2 {
+		t.Fatalf("%v", k)
+	}
+}
+
+func testDeclareEdgeKey(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 0, nil)
+
+	_, err := tx.FindEdgeKey("typeA", "keyB")
+	if err != graph.ErrNoRows {
+		t.Fatalf("err = %v, want %v", err, graph.ErrNoRows)
+	}
+
+	// Keys known by their edges are undeclared
+	c, err := tx.FindEdgeKey("typeA", "keyA")
+	if err != nil {
+		t.Fatal(err)
+	} else if c != "" {
+		t.Fatalf("c = %q, want undeclared", c)
+	}
+
+	err = tx.DeclareEdgeKey("typeA", "keyA", graph.ToOne)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first declaration holds
+	err = tx.DeclareEdgeKey("typeA", "keyA", graph.ToMany)
+	if err != graph.ErrConflict {
+		t.Fatalf("err = %v, want %v", err, graph.ErrConflict)
+	}
+
+	_ = tx.InsertEdge("typeA", "idA", "typeA", "idA", "keyA", 0, nil)
+
+	c, err = tx.FindEdgeKey("typeA", "keyA")
+	if err != nil {
+		t.Fatal(err)
+	} else if c != graph.ToOne {
+		t.Fatalf("c = %q, want %q", c, graph.ToOne)
+	}
+}
+
+func testFindRelatedAttributeKeys(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"a":1}`), nil)
+	_ = tx.InsertVertex("typeB", "idB", []byte(`{"b":2,"c":3}`), nil)
+	_ = tx.InsertVertex("typeC", "idC", []byte(`{"c":4,"d":5}`), nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 1, nil)
+
+	k, err := tx.FindRelatedAttributeKeys("typeA", "idA", "keyA")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(k) != 3 {
+		t.Fatalf("%v", k)
+	}
+}
+
+func testCountRelatedVertices(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 0, nil)
+
+	e, err := tx.CountRelatedVertices("typeA", "idA", "keyA", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if e != 2 {
+		t.Fatalf("%v", e)
+	}
+}
+
+func testFindEdges(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertVertex("typeC", "idC", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "keyA", 0, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeC", "idC", "keyA", 0, nil)
+
+	e, err := tx.FindEdges("typeA", "idA", "keyA", nil, nil, graph.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(e) != 2 {
+		t.Fatalf("%v", e)
+	}
+}
+
+func testEdge(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+
+	_, err := tx.FindEdge("typeA", "idA", "typeB", "idB", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTimestamps(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	time.Sleep(time.Millisecond)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+	time.Sleep(time.Millisecond)
+	_ = tx.UpdateVertex("typeA", "idA", 0, []byte(`{"a":"b"}`), nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeA", "idB", "key", 0, nil)
+
+	a, err := tx.FindVertex("typeA", "idA")
+	if err != nil {
+		t.Fatal(err)
+	} else if a.Created.IsZero() || !a.Updated.After(a.Created) {
+		t.Fatalf("Got created %v and updated %v, want updated after created", a.Created, a.Updated)
+	}
+
+	b, err := tx.FindVertex("typeA", "idB")
+	if err != nil {
+		t.Fatal(err)
+	} else if !b.Created.After(a.Created) || !b.Updated.Equal(b.Created) {
+		t.Fatalf("Got created %v and updated %v, want created after %v", b.Created, b.Updated, a.Created)
+	}
+
+	e, err := tx.FindEdge("typeA", "idA", "typeA", "idB", "key")
+	if err != nil {
+		t.Fatal(err)
+	} else if e.Created.Before(a.Updated) {
+		t.Fatalf("Got created %v, want after %v", e.Created, a.Updated)
+	}
+
+	tests := []struct {
+		filters []graph.Filter
+		sort    []graph.SortKey
+		want    []string
+	}{
+		{nil, []graph.SortKey{{Path: graph.CreatedPath, Descending: true}}, []string{"idB", "idA"}},
+		{nil, []graph.SortKey{{Path: graph.UpdatedPath, Descending: true}}, []string{"idA", "idB"}},
+		{
+			[]graph.Filter{{Path: graph.CreatedPath, Operator: graph.GreaterThan, Values: []interface{}{a.Created.Format(graph.TimeFormat)}}},
+			nil,
+			[]string{"idB"},
+		},
+	}
+
+	for _, test := range tests {
+
+		v, err := tx.FindVertices("typeA", test.filters, test.sort, graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, vertex := range v {
+			got = append(got, vertex.Identifier)
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%v %v: got %v, want %v", test.filters, test.sort, got, test.want)
+		}
+	}
+}
+
+func testHistory(t *testing.T, g graph.Graph) {
+
+	ctx := graph.WithAudit(context.Background(), graph.Audit{Actor: "alice", RequestID: "req"})
+
+	tx, _ := g.Transaction(ctx, false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"a":"b"}`), nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.UpdateVertex("typeA", "idA", 0, []byte(`{"a":"c"}`), nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+	_ = tx.InsertEdge("typeB", "idB", "typeA", "idA", "key", 0, nil)
+	_ = tx.DeleteVertex("typeA", "idA", 0)
+
+	tests := []struct {
+		vertexID string
+		want     []string
+	}{
+		{"idA", []string{"insert ", "update ", "insert key", "delete key", "delete "}},
+		{"idB", []string{"insert ", "insert key", "delete key"}},
+	}
+
+	for _, test := range tests {
+
+		vertexType := "type" + test.vertexID[2:]
+
+		count, err := tx.CountChanges(vertexType, test.vertexID)
+		if err != nil {
+			t.Fatal(err)
+		} else if count != int64(len(test.want)) {
+			t.Errorf("%s: got count %d, want %d", test.vertexID, count, len(test.want))
+		}
+
+		changes, err := tx.FindChanges(vertexType, test.vertexID, graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, change := range changes {
+			got = append(got, string(change.Operation)+" "+change.Key)
+			if change.Actor != "alice" || change.RequestID != "req" || change.Time.IsZero() {
+				t.Errorf("%s: got %+v, want attribution", test.vertexID, change)
+			}
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: got %v, want %v", test.vertexID, got, test.want)
+		}
+	}
+
+	// Before and after
+	changes, _ := tx.FindChanges("typeA", "idA", graph.Page{Limit: 1, Offset: 1})
+	if len(changes) != 1 {
+		t.Fatalf("Got %d changes, want 1", len(changes))
+	} else if !strings.Contains(string(changes[0].Before), `{"a":"b"}`) || !strings.Contains(string(changes[0].After), `{"a":"c"}`) {
+		t.Errorf("Got %s and %s, want before and after update", changes[0].Before, changes[0].After)
+	}
+
+	// Paged by cursor
+	page, _ := tx.FindChanges("typeA", "idA", graph.Page{Limit: 2})
+	next, err := tx.FindChanges("typeA", "idA", graph.Page{Limit: 2, After: page[1].Cursor})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(next) != 2 || next[0].ID != changes[0].ID+1 {
+		t.Errorf("Got %+v, want page after %d", next, page[1].ID)
+	}
+}
+
+func testTombstones(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	defer tx.Close()
+
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+
+	// Tombstoned at a stale revision
+	if err := tx.TombstoneVertex("typeB", "idB", 2); err != graph.ErrStaleRevision {
+		t.Fatalf("Got %v, want %v", err, graph.ErrStaleRevision)
+	}
+
+	if err := tx.TombstoneVertex("typeB", "idB", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hidden, with its edges
+	if _, err := tx.FindVertex("typeB", "idB"); err != graph.ErrNoRows {
+		t.Errorf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+	if i, _ := tx.CountVertices("typeB", nil); i != 0 {
+		t.Errorf("Got %d vertices, want 0", i)
+	}
+	if e, _ := tx.FindEdges("typeA", "idA", "key", nil, nil, graph.Page{Limit: 10}); len(e) != 0 {
+		t.Errorf("Got %d edges, want 0", len(e))
+	}
+	if err := tx.TombstoneVertex("typeB", "idB", 0); err != graph.ErrNoRows {
+		t.Errorf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+
+	// Restored, with its edges
+	if err := tx.RestoreVertex("typeB", "idB"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := tx.FindVertex("typeB", "idB"); err != nil {
+		t.Fatal(err)
+	} else if v.Revision != 3 {
+		t.Errorf("Got revision %d, want 3", v.Revision)
+	}
+	if e, _ := tx.FindEdges("typeA", "idA", "key", nil, nil, graph.Page{Limit: 10}); len(e) != 1 {
+		t.Errorf("Got %d edges, want 1", len(e))
+	}
+	if err := tx.RestoreVertex("typeB", "idB"); err != graph.ErrNoRows {
+		t.Errorf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+
+	// Purged only once older than the time given
+	_ = tx.TombstoneVertex("typeB", "idB", 3)
+
+	if i, err := tx.PurgeVertices(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if i != 0 {
+		t.Errorf("Got %d purged, want 0", i)
+	}
+
+	if i, err := tx.PurgeVertices(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if i != 1 {
+		t.Errorf("Got %d purged, want 1", i)
+	}
+	if err := tx.RestoreVertex("typeB", "idB"); err != graph.ErrNoRows {
+		t.Errorf("Got %v, want %v", err, graph.ErrNoRows)
+	}
+
+	changes, _ := tx.FindChanges("typeB", "idB", graph.Page{Limit: 10})
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, string(change.Operation))
+	}
+
+	want := []string{"insert", "tombstone", "restore", "tombstone", "delete"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func testFindChangesAfter(t *testing.T, g graph.Graph) {
+
+	tx, _ := g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeB", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeB", "idB", "key", 0, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	// Rolled back
+	tx, _ = g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idC", nil, nil)
+	tx.Close()
+
+	tx, _ = g.Transaction(context.Background(), true)
+	defer tx.Close()
+
+	if id, err := tx.FindLastChangeID(); err != nil {
+		t.Fatal(err)
+	} else if id != 3 {
+		t.Errorf("Got %d, want 3", id)
+	}
+
+	tests := []struct {
+		id    int64
+		types []string
+		limit int64
+		want  []int64
+	}{
+		{0, nil, 10, []int64{1, 2, 3}},
+		{1, nil, 10, []int64{2, 3}},
+		{0, nil, 2, []int64{1, 2}},
+		{0, []string{"typeA"}, 10, []int64{1, 3}},
+		{0, []string{"typeB", "typeC"}, 10, []int64{2}},
+		{3, nil, 10, nil},
+	}
+
+	for _, test := range tests {
+
+		changes, err := tx.FindChangesAfter(test.id, test.types, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []int64
+		for _, change := range changes {
+			got = append(got, change.ID)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%d %v: got %v, want %v", test.id, test.types, got, test.want)
+		}
+	}
+}
+
+func testOutbox(t *testing.T, g graph.Graph) {
+
+	// Claims within a transaction of their own, to be committed
+	claim := func(consumer string, lease time.Duration) (graph.Claim, error) {
+		tx, _ := g.Transaction(context.Background(), false)
+		defer tx.Close()
+		c, err := tx.ClaimMessages(consumer, 10, lease)
+		if err == nil {
+			err = tx.Commit()
+		}
+		return c, err
+	}
+
+	ack := func(c graph.Claim) error {
+		tx, _ := g.Transaction(context.Background(), false)
+		defer tx.Close()
+		err := tx.AckMessages(c)
+		if err == nil {
+			err = tx.Commit()
+		}
+		return err
+	}
+
+	purge := func(createdBefore time.Time) (int64, error) {
+		tx, _ := g.Transaction(context.Background(), false)
+		defer tx.Close()
+		n, err := tx.PurgeMessages(createdBefore)
+		if err == nil {
+			err = tx.Commit()
+		}
+		return n, err
+	}
+
+	// Written without a consumer, and purged while there is none
+	tx, _ := g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idZ", nil, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	if n, err := purge(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("Got %d messages purged, want 0 created since", n)
+	}
+
+	if n, err := purge(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("Got %d messages purged, want 1", n)
+	}
+
+	tx, _ = g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	// Registered from the first message then in the outbox
+	registered, err := claim("consumerA", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(registered.Messages) != 1 || registered.Messages[0].VertexID != "idA" {
+		t.Fatalf("Got %+v, want insert of idA", registered.Messages)
+	}
+
+	if err := ack(registered); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not purged once there is a consumer
+	tx, _ = g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idY", nil, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	if n, err := purge(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("Got %d messages purged, want 0 with a consumer", n)
+	}
+
+	if c, err := claim("consumerA", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if err := ack(c); err != nil || len(c.Messages) != 1 {
+		t.Fatalf("Got %+v (%v), want insert of idY", c.Messages, err)
+	}
+
+	// Rolled back
+	tx, _ = g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+	tx.Close()
+
+	tx, _ = g.Transaction(context.Background(), false)
+	_ = tx.InsertVertex("typeA", "idC", nil, nil)
+	_ = tx.UpdateVertex("typeA", "idC", 0, []byte(`{"a":"b"}`), nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	first, err := claim("consumerA", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(first.Messages) != 2 || first.Messages[0].VertexID != "idC" || first.Messages[1].Operation != graph.Update {
+		t.Fatalf("Got %+v, want insert and update of idC", first.Messages)
+	}
+
+	if _, err := claim("consumerA", time.Minute); err != graph.ErrClaimed {
+		t.Errorf("Got %v, want %v", err, graph.ErrClaimed)
+	}
+
+	if err := ack(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := claim("consumerA", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if len(c.Messages) != 0 {
+		t.Errorf("Got %d messages, want 0 once acked", len(c.Messages))
+	}
+
+	// Claimed again once expired
+	tx, _ = g.Transaction(context.Background(), false)
+	if err := tx.DeleteVertex("typeA", "idC", 0); err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Commit()
+	tx.Close()
+
+	expired, err := claim("consumerA", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := claim("consumerA", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(second.Messages) != 1 || second.Messages[0].ID != expired.Messages[0].ID {
+		t.Fatalf("Got %+v, want messages of expired claim", second.Messages)
+	}
+
+	if err := ack(expired); err != graph.ErrClaimExpired {
+		t.Errorf("Got %v, want %v", err, graph.ErrClaimExpired)
+	}
+
+	if err := ack(second); err != nil {
+		t.Error(err)
+	}
+}
+
+func testClaimDueDeliveries(t *testing.T, g graph.Graph) {
+
+	at := time.Now()
+
+	// Claims within a transaction of their own, to be committed
+	claim := func(due time.Time) ([]graph.Delivery, error) {
+		tx, _ := g.Transaction(context.Background(), false)
+		defer tx.Close()
+		d, err := tx.ClaimDueDeliveries(due, due.Add(time.Minute), 10)
+		if err == nil {
+			err = tx.Commit()
+		}
+		return d, err
+	}
+
+	tx, _ := g.Transaction(context.Background(), false)
+	for i, next := range []time.Time{at, at.Add(time.Hour)} {
+		err := tx.InsertDelivery(graph.Delivery{
+			SubscriptionType: "webhooks",
+			SubscriptionID:   "idA",
+			ChangeID:         int64(i + 1),
+			Event:            "typeA.insert",
+			Payload:          []byte(`{}`),
+			State:            graph.Pending,
+			NextAttempt:      next,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tx.Commit()
+	tx.Close()
+
+	first, err := claim(at)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(first) != 1 || first[0].ChangeID != 1 {
+		t.Fatalf("Got %+v, want delivery of change 1", first)
+	}
+
+	// Not due again until the lease ends
+	if d, err := claim(at); err != nil {
+		t.Fatal(err)
+	} else if len(d) != 0 {
+		t.Errorf("Got %d deliveries, want 0 while claimed", len(d))
+	}
+
+	if d, err := claim(at.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	} else if len(d) != 1 || d[0].ID != first[0].ID {
+		t.Errorf("Got %+v, want delivery of expired claim", d)
+	}
+}
@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Operation is the kind of mutation recorded by a Change.
+type Operation string
+
+const (
+	Insert Operation = "insert"
+	Update Operation = "update"
+	Delete Operation = "delete"
+
+	// Of vertices, deleted softly and restored
+	Tombstone Operation = "tombstone"
+	Restore   Operation = "restore"
+)
+
+// Change is an entry of the history of a vertex: a mutation of the vertex,
+// or of an edge from it, as recorded by the transaction making it.
+// Before and After are the states of the vertex or edge, in JSON, of which
+// Before is nil for an insert and After nil for a delete.  Changes to an
+// edge have the key of the edge.
+type Change struct {
+	ID         int64
+	VertexType string
+	VertexID   string
+	Key        string
+	Operation  Operation
+	Actor      string
+	RequestID  string
+	Time       time.Time
+	Before     []byte
+	After      []byte
+	Cursor     string // Position within a page, if found by FindChanges
+}
+
+// Audit is the attribution of the changes made within a transaction.
+type Audit struct {
+	Actor     string
+	RequestID string
+}
+
+type auditKey struct{}
+
+// WithAudit returns a copy of ctx carrying a, for attribution of changes by
+// transactions begun with it.
+func WithAudit(ctx context.Context, a Audit) context.Context {
+	return context.WithValue(ctx, auditKey{}, a)
+}
+
+// AuditFromContext returns the Audit carried by ctx, if any, else the zero
+// Audit.
+func AuditFromContext(ctx context.Context) Audit {
+	a, _ := ctx.Value(auditKey{}).(Audit)
+	return a
+}
+
+// MarshalVertex returns the state of v as recorded by a Change.
+func MarshalVertex(v Vertex) ([]byte, error) {
+	return json.Marshal(struct {
+		Attributes json.RawMessage `json:"attributes"`
+		Meta       json.RawMessage `json:"meta"`
+		Revision   int64           `json:"revision"`
+	}{
+		Attributes: rawJSON(v.Attributes),
+		Meta:       rawJSON(v.Meta),
+		Revision:   v.Revision,
+	})
+}
+
+// MarshalEdge returns the state of e as recorded by a Change.
+func MarshalEdge(e Edge) ([]byte, error) {
+	return json.Marshal(struct {
+		From Identifier      `json:"from"`
+		To   Identifier      `json:"to"`
+		Key  string          `json:"key"`
+		Meta json.RawMessage `json:"meta"`
+	}{
+		From: Identifier{e.From.Type, e.From.Identifier},
+		To:   Identifier{e.To.Type, e.To.Identifier},
+		Key:  e.Key,
+		Meta: rawJSON(e.Meta),
+	})
+}
+
+// Identifier identifies a vertex within the state of an edge.
+type Identifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Returns b as raw JSON, or null if b is not valid JSON (e.g., empty).
+func rawJSON(b []byte) json.RawMessage {
+
+	if !json.Valid(b) {
+		return json.RawMessage("null")
+	}
+
+	return json.RawMessage(b)
+}
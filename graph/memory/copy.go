@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"sort"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) DumpVertices(fn func(graph.Vertex) error) error {
+
+	var (
+		vertices []graph.Vertex
+		rowids   []int64
+	)
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	for k, v := range tx.vertices {
+		vertex := tx.vertex(k, v)
+		vertex.Tombstoned = v.deleted
+		vertices = append(vertices, vertex)
+		rowids = append(rowids, v.rowid)
+	}
+
+	sort.Sort(verticesByRowid{vertices, rowids})
+
+	for _, vertex := range vertices {
+		err := fn(vertex)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) DumpEdges(fn func(graph.Edge) error) error {
+
+	var (
+		edges  []graph.Edge
+		rowids []int64
+	)
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	for k, e := range tx.edges {
+		edges = append(edges, graph.Edge{
+			From:     graph.Vertex{Type: k.From.Type, Identifier: k.From.ID},
+			To:       graph.Vertex{Type: k.To.Type, Identifier: k.To.ID},
+			Key:      k.Key,
+			Meta:     clone(e.meta),
+			Position: e.position,
+			Created:  e.created,
+			Updated:  e.updated,
+		})
+		rowids = append(rowids, e.rowid)
+	}
+
+	sort.Sort(byRowid{edges, rowids})
+
+	for _, edge := range edges {
+		err := fn(edge)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) DumpChanges(fn func(graph.Change) error) error {
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	for _, change := range tx.history {
+		err := fn(copyChange(change))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) LoadVertex(v graph.Vertex) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := vertexKey{v.Type, v.Identifier}
+
+	if _, ok := tx.vertices[k]; ok {
+		return graph.ErrConflict
+	}
+
+	tx.rowid++
+	tx.writeVertices()[k] = vertex{
+		rowid:      tx.rowid,
+		attributes: clone(v.Attributes),
+		meta:       clone(v.Meta),
+		revision:   v.Revision,
+		created:    v.Created,
+		updated:    v.Updated,
+		deleted:    v.Tombstoned,
+	}
+
+	if _, ok := tx.vertexTypes[v.Type]; !ok {
+		tx.writeVertexTypes()[v.Type] = struct{}{}
+	}
+
+	return nil
+}
+
+func (tx *transaction) LoadEdge(e graph.Edge) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := edgeKey{vertexKey{e.From.Type, e.From.Identifier}, e.Key, vertexKey{e.To.Type, e.To.Identifier}}
+
+	// Tombstoned or not, as either may be
+	_, fromOK := tx.vertices[k.From]
+	_, toOK := tx.vertices[k.To]
+	if !fromOK || !toOK {
+		return graph.ErrNoRows
+	} else if _, ok := tx.edges[k]; ok {
+		return graph.ErrConflict
+	}
+
+	tx.rowid++
+	tx.writeEdges()[k] = edge{
+		rowid:    tx.rowid,
+		position: e.Position,
+		meta:     clone(e.Meta),
+		created:  e.Created,
+		updated:  e.Updated,
+	}
+
+	if _, ok := tx.edgeKeys[typeKey{e.From.Type, e.Key}]; !ok {
+		tx.writeEdgeKeys()[typeKey{e.From.Type, e.Key}] = cardinality{}
+	}
+
+	return nil
+}
+
+func (tx *transaction) LoadChange(change graph.Change) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	// Changes are held in order of ID
+	if change.ID <= tx.changeID {
+		return graph.ErrConflict
+	}
+
+	change.Cursor = ""
+	tx.changeID = change.ID
+	tx.history = append(tx.history, copyChange(change))
+
+	return nil
+}
+
+// verticesByRowid sorts vertices by their rowids.
+type verticesByRowid struct {
+	vertices []graph.Vertex
+	rowids   []int64
+}
+
+func (s verticesByRowid) Len() int           { return len(s.vertices) }
+func (s verticesByRowid) Less(i, j int) bool { return s.rowids[i] < s.rowids[j] }
+func (s verticesByRowid) Swap(i, j int) {
+	s.vertices[i], s.vertices[j] = s.vertices[j], s.vertices[i]
+	s.rowids[i], s.rowids[j] = s.rowids[j], s.rowids[i]
+}
@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) InsertDelivery(delivery graph.Delivery) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	for _, d := range tx.deliveries {
+		if d.SubscriptionType == delivery.SubscriptionType && d.SubscriptionID == delivery.SubscriptionID && d.ChangeID == delivery.ChangeID {
+			return nil
+		}
+	}
+
+	created := now()
+
+	due := created
+	if !delivery.NextAttempt.IsZero() {
+		due = delivery.NextAttempt.UTC().Truncate(time.Microsecond)
+	}
+
+	tx.deliveryID++
+	tx.writeDeliveries()[tx.deliveryID] = graph.Delivery{
+		ID:               tx.deliveryID,
+		SubscriptionType: delivery.SubscriptionType,
+		SubscriptionID:   delivery.SubscriptionID,
+		ChangeID:         delivery.ChangeID,
+		Event:            delivery.Event,
+		Payload:          clone(delivery.Payload),
+		State:            graph.Pending,
+		NextAttempt:      due,
+		Created:          created,
+		Updated:          created,
+	}
+
+	return nil
+}
+
+func (tx *transaction) UpdateDelivery(delivery graph.Delivery) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	d, ok := tx.deliveries[delivery.ID]
+	if !ok {
+		return graph.ErrNoRows
+	}
+
+	d.State = delivery.State
+	d.Attempts = delivery.Attempts
+	d.NextAttempt = delivery.NextAttempt.UTC().Truncate(time.Microsecond)
+	d.Updated = now()
+	tx.writeDeliveries()[d.ID] = d
+
+	return nil
+}
+
+func (tx *transaction) InsertDeliveryAttempt(attempt graph.DeliveryAttempt) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	attempt.Time = attempt.Time.UTC().Truncate(time.Microsecond)
+	tx.attempts = append(tx.attempts, attempt)
+
+	return nil
+}
+
+func (tx *transaction) FindDueDeliveries(due time.Time, limit int64) ([]graph.Delivery, error) {
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	due = due.UTC().Truncate(time.Microsecond)
+
+	deliveries := tx.findDeliveries(func(d graph.Delivery) bool {
+		return d.State == graph.Pending && !d.NextAttempt.After(due)
+	})
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+
+	return pageDeliveries(deliveries, limit, 0), nil
+}
+
+func (tx *transaction) ClaimDueDeliveries(due, until time.Time, limit int64) ([]graph.Delivery, error) {
+
+	if err := tx.writable(); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := tx.FindDueDeliveries(due, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range deliveries {
+		d := tx.deliveries[delivery.ID]
+		d.NextAttempt = until.UTC().Truncate(time.Microsecond)
+		d.Updated = now()
+		tx.writeDeliveries()[d.ID] = d
+	}
+
+	return deliveries, nil
+}
+
+func (tx *transaction) FindDeliveries(subscriptionType, subscriptionID string, page graph.Page) ([]graph.Delivery, error) {
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	deliveries := tx.findDeliveries(func(d graph.Delivery) bool {
+		return d.SubscriptionType == subscriptionType && d.SubscriptionID == subscriptionID
+	})
+
+	return pageDeliveries(deliveries, page.Limit, page.Offset), nil
+}
+
+func (tx *transaction) FindDeliveryAttempts(deliveryID int64) ([]graph.DeliveryAttempt, error) {
+
+	var attempts []graph.DeliveryAttempt
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	for _, attempt := range tx.attempts {
+		if attempt.DeliveryID == deliveryID {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts, nil
+}
+
+// Returns the deliveries matching match, in order of ID.
+func (tx *transaction) findDeliveries(match func(graph.Delivery) bool) []graph.Delivery {
+
+	var deliveries []graph.Delivery
+
+	for _, d := range tx.deliveries {
+		if match(d) {
+			d.Payload = clone(d.Payload)
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+
+	return deliveries
+}
+
+// Returns deliveries from offset, up to limit, as by LIMIT and OFFSET.
+func pageDeliveries(deliveries []graph.Delivery, limit, offset int64) []graph.Delivery {
+
+	i, j := bounds(len(deliveries), limit, offset)
+	if i == j {
+		return nil
+	}
+
+	return deliveries[i:j]
+}
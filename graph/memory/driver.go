@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+var (
+	errClosed   = errors.New("graph is closed")
+	errReadOnly = errors.New("transaction is read-only")
+	errTxDone   = errors.New("transaction has already been committed or rolled back")
+)
+
+// New returns an empty graph, held in memory, as *graph.Graph.  It is lost
+// once the process exits, and so is for tests and ephemeral servers.
+//
+// Each transaction reads a snapshot of the graph, as committed when it
+// began.  Transactions that write are serialized, as with SQLite: one
+// begins once that before it is committed or rolled back.  Its writes are
+// made to copies of the parts of the snapshot written, which replace the
+// graph if committed and are discarded if not.
+func New() graph.Graph {
+
+	var g graph.Graph
+
+	g = &memory{
+		state:  newState(),
+		writer: make(chan struct{}, 1),
+	}
+
+	return g
+}
+
+type memory struct {
+	mu     sync.Mutex // guards state and closed
+	state  *state     // as last committed, and never written
+	closed bool
+	writer chan struct{} // held by the transaction that writes, if any
+}
+
+func (g *memory) Close() error {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+
+	return nil
+}
+
+func (g *memory) Transaction(ctx context.Context, readOnly bool) (graph.Tx, error) {
+
+	var tx graph.Tx
+
+	tx, err := g.newTransaction(ctx, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Returns the committed state, or errClosed.
+func (g *memory) snapshot() (*state, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, errClosed
+	}
+
+	return g.state, nil
+}
+
+type transaction struct {
+	*state
+	graph    *memory
+	readOnly bool
+	done     bool
+	owned    owned // parts of state copied, and so written, by the transaction
+	Audit    graph.Audit
+}
+
+func (g *memory) newTransaction(ctx context.Context, readOnly bool) (*transaction, error) {
+
+	if !readOnly {
+		select {
+		case g.writer <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	s, err := g.snapshot()
+	if err != nil {
+		if !readOnly {
+			<-g.writer
+		}
+		return nil, err
+	}
+
+	tx := transaction{
+		state:    s,
+		graph:    g,
+		readOnly: readOnly,
+		Audit:    graph.AuditFromContext(ctx),
+	}
+	if !readOnly {
+		c := *s
+		tx.state = &c
+	}
+
+	return &tx, nil
+}
+
+func (tx *transaction) Commit() error {
+
+	if tx.done {
+		return errTxDone
+	}
+
+	tx.done = true
+
+	if tx.readOnly {
+		return nil
+	}
+
+	defer func() { <-tx.graph.writer }()
+
+	tx.graph.mu.Lock()
+	defer tx.graph.mu.Unlock()
+
+	if tx.graph.closed {
+		return errClosed
+	}
+
+	tx.graph.state = tx.state
+
+	return nil
+}
+
+func (tx *transaction) Close() error {
+
+	if tx.done {
+		return nil
+	}
+
+	tx.done = true
+
+	if !tx.readOnly {
+		<-tx.graph.writer
+	}
+
+	return nil
+}
+
+// Returns an error unless the transaction is open.
+func (tx *transaction) readable() error {
+
+	if tx.done {
+		return errTxDone
+	}
+
+	return nil
+}
+
+// Returns an error unless the transaction is open and may write.
+func (tx *transaction) writable() error {
+
+	if tx.done {
+		return errTxDone
+	} else if tx.readOnly {
+		return errReadOnly
+	}
+
+	return nil
+}
+
+// Returns the time of a write, for the times at which vertices and edges
+// were created and updated, as precise as TimeFormat.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Returns a copy of b, or an empty slice if b is nil, as a column holding
+// b is read.
+func clone(b []byte) []byte {
+	return append([]byte{}, b...)
+}
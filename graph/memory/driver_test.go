@@ -0,0 +1,188 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func TestNew(t *testing.T) {
+
+	g := New()
+
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Transaction(context.Background(), true); err == nil {
+		t.Error("Began transaction on closed graph, want error")
+	}
+}
+
+func TestTransation(t *testing.T) {
+
+	g := New()
+	defer g.Close()
+
+	tx, err := g.Transaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if err := tx.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+
+	g := New()
+	defer g.Close()
+
+	ctx := context.Background()
+
+	tx, _ := g.Transaction(ctx, false)
+	_ = tx.InsertVertex("typeA", "idA", []byte(`{"n":1}`), nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	// Snapshot, as committed before the write
+	reader, _ := g.Transaction(ctx, true)
+	defer reader.Close()
+
+	writer, _ := g.Transaction(ctx, false)
+	_ = writer.UpdateVertex("typeA", "idA", 0, []byte(`{"n":2}`), nil)
+	_ = writer.InsertVertex("typeA", "idB", nil, nil)
+	_ = writer.InsertEdge("typeA", "idA", "typeA", "idB", "key", 0, nil)
+
+	if vertex, err := writer.FindVertex("typeA", "idA"); err != nil {
+		t.Fatal(err)
+	} else if string(vertex.Attributes) != `{"n":2}` {
+		t.Errorf("Writer got %s, want its write", vertex.Attributes)
+	}
+
+	// Uncommitted
+	if vertex, err := reader.FindVertex("typeA", "idA"); err != nil {
+		t.Fatal(err)
+	} else if string(vertex.Attributes) != `{"n":1}` || vertex.Revision != 1 {
+		t.Errorf("Reader got %s of revision %d, want that committed", vertex.Attributes, vertex.Revision)
+	}
+	if _, err := reader.FindVertex("typeA", "idB"); err != graph.ErrNoRows {
+		t.Errorf("Reader got %v for vertex inserted, want ErrNoRows", err)
+	}
+
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	// Committed after the snapshot
+	if count, err := reader.CountVertices("typeA", nil); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Errorf("Reader got %d vertices, want 1", count)
+	}
+	if id, err := reader.FindLastChangeID(); err != nil {
+		t.Fatal(err)
+	} else if id != 1 {
+		t.Errorf("Reader got last change %d, want 1", id)
+	}
+
+	// Committed before the snapshot
+	after, _ := g.Transaction(ctx, true)
+	defer after.Close()
+
+	if count, err := after.CountRelatedVertices("typeA", "idA", "key", nil); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Errorf("Got %d related vertices, want 1", count)
+	}
+
+	// Read only
+	if err := after.InsertVertex("typeA", "idC", nil, nil); err == nil {
+		t.Error("Inserted vertex in read-only transaction, want error")
+	}
+}
+
+func TestRollback(t *testing.T) {
+
+	g := New()
+	defer g.Close()
+
+	ctx := context.Background()
+
+	tx, _ := g.Transaction(ctx, false)
+	_ = tx.InsertVertex("typeA", "idA", nil, nil)
+	_ = tx.InsertVertex("typeA", "idB", nil, nil)
+	_ = tx.InsertEdge("typeA", "idA", "typeA", "idB", "key", 0, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	// Rolled back
+	tx, _ = g.Transaction(ctx, false)
+	_ = tx.DeleteVertex("typeA", "idB", 0)
+	_ = tx.InsertVertex("typeB", "idC", nil, nil)
+	_ = tx.DeclareEdgeKey("typeA", "key", graph.ToOne)
+	if _, err := tx.ClaimMessages("consumer", 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tx.Close()
+
+	if err := tx.Commit(); err == nil {
+		t.Error("Committed closed transaction, want error")
+	}
+
+	tx, _ = g.Transaction(ctx, true)
+	defer tx.Close()
+
+	if _, err := tx.FindEdge("typeA", "idA", "typeA", "idB", "key"); err != nil {
+		t.Errorf("Got %v for edge deleted and rolled back, want edge", err)
+	}
+	if err := tx.FindVertexType("typeB"); err != graph.ErrNoRows {
+		t.Errorf("Got %v for type inserted and rolled back, want ErrNoRows", err)
+	}
+	if cardinality, err := tx.FindEdgeKey("typeA", "key"); err != nil {
+		t.Fatal(err)
+	} else if cardinality != "" {
+		t.Errorf("Got cardinality %q, declared and rolled back, want none", cardinality)
+	}
+	if id, err := tx.FindLastChangeID(); err != nil {
+		t.Fatal(err)
+	} else if id != 3 {
+		t.Errorf("Got last change %d, want 3", id)
+	}
+}
+
+func TestWriters(t *testing.T) {
+
+	g := New()
+	defer g.Close()
+
+	writer, _ := g.Transaction(context.Background(), false)
+
+	// Another writer waits for the first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := g.Transaction(ctx, false); err != context.DeadlineExceeded {
+		t.Errorf("Got %v while another writes, want context.DeadlineExceeded", err)
+	}
+
+	_ = writer.InsertVertex("typeA", "idA", nil, nil)
+	_ = writer.Commit()
+	writer.Close()
+
+	tx, err := g.Transaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Close()
+
+	if err := tx.InsertVertex("typeA", "idA", nil, nil); err != graph.ErrConflict {
+		t.Errorf("Got %v for vertex committed by another, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,240 @@
+package backend
+
+import (
+	"sort"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Record a change to the vertex of vertexType and vertexID, or to the edge
+// keyed by key from it, from the state before to the state after, either of
+// which may be nil, in the history and the outbox.
+func (tx *transaction) record(vertexType, vertexID, key string, op graph.Operation, before, after []byte) {
+
+	change := graph.Change{
+		VertexType: vertexType,
+		VertexID:   vertexID,
+		Key:        key,
+		Operation:  op,
+		Actor:      tx.Audit.Actor,
+		RequestID:  tx.Audit.RequestID,
+		Time:       now(),
+		Before:     before,
+		After:      after,
+	}
+
+	tx.changeID++
+	change.ID = tx.changeID
+	tx.history = append(tx.history, change)
+
+	tx.messageID++
+	change.ID = tx.messageID
+	tx.outbox = append(tx.outbox, change)
+}
+
+// Record a change to a vertex, from before to after, either of which may be
+// nil.
+func (tx *transaction) recordVertex(op graph.Operation, before, after *graph.Vertex) error {
+
+	var (
+		v      *graph.Vertex
+		states [2][]byte
+		err    error
+	)
+
+	for i, vertex := range []*graph.Vertex{before, after} {
+		if vertex == nil {
+			continue
+		}
+		v = vertex
+		states[i], err = graph.MarshalVertex(*vertex)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx.record(v.Type, v.Identifier, "", op, states[0], states[1])
+
+	return nil
+}
+
+// Record a change to an edge, from before to after, either of which may be
+// nil, in the history of the vertex from which it is.
+func (tx *transaction) recordEdge(op graph.Operation, before, after *graph.Edge) error {
+
+	var (
+		e      *graph.Edge
+		states [2][]byte
+		err    error
+	)
+
+	for i, edge := range []*graph.Edge{before, after} {
+		if edge == nil {
+			continue
+		}
+		e = edge
+		states[i], err = graph.MarshalEdge(*edge)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx.record(e.From.Type, e.From.Identifier, e.Key, op, states[0], states[1])
+
+	return nil
+}
+
+// Returns the edges from and to the vertex of k, whether or not either
+// vertex is tombstoned, in the order in which they were inserted.
+func (tx *transaction) findIncidentEdges(k vertexKey) []graph.Edge {
+
+	var (
+		edges  []graph.Edge
+		rowids []int64
+	)
+
+	for ek, e := range tx.edges {
+		if ek.From == k || ek.To == k {
+			edges = append(edges, tx.edge(ek, e))
+			rowids = append(rowids, e.rowid)
+		}
+	}
+
+	sort.Sort(byRowid{edges, rowids})
+
+	return edges
+}
+
+// byRowid sorts edges by their rowids.
+type byRowid struct {
+	edges  []graph.Edge
+	rowids []int64
+}
+
+func (s byRowid) Len() int           { return len(s.edges) }
+func (s byRowid) Less(i, j int) bool { return s.rowids[i] < s.rowids[j] }
+func (s byRowid) Swap(i, j int) {
+	s.edges[i], s.edges[j] = s.edges[j], s.edges[i]
+	s.rowids[i], s.rowids[j] = s.rowids[j], s.rowids[i]
+}
+
+func (tx *transaction) CountChanges(vertexType, vertexID string) (int64, error) {
+
+	var count int64
+
+	if err := tx.readable(); err != nil {
+		return count, err
+	}
+
+	for _, change := range tx.history {
+		if change.VertexType == vertexType && change.VertexID == vertexID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (tx *transaction) FindChanges(vertexType, vertexID string, page graph.Page) ([]graph.Change, error) {
+
+	var (
+		changes []graph.Change
+		terms   [][]interface{}
+	)
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	keyset, err := newKeyset(nil, page, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range tx.history {
+		if change.VertexType == vertexType && change.VertexID == vertexID {
+			changes = append(changes, copyChange(change))
+			terms = append(terms, []interface{}{bind(change.ID)})
+		}
+	}
+
+	var found []graph.Change
+
+	for _, i := range keyset.page(terms, page) {
+		changes[i].Cursor, err = keyset.cursor(terms[i])
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, changes[i])
+	}
+
+	if keyset.Reverse {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+
+	return found, nil
+}
+
+func (tx *transaction) FindChangesAfter(id int64, vertexTypes []string, limit int64) ([]graph.Change, error) {
+
+	var changes []graph.Change
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	// Changes are appended in order of ID
+	i := sort.Search(len(tx.history), func(i int) bool {
+		return tx.history[i].ID > id
+	})
+
+	for _, change := range tx.history[i:] {
+		if limit >= 0 && int64(len(changes)) >= limit {
+			break
+		}
+		if len(vertexTypes) == 0 || contains(vertexTypes, change.VertexType) {
+			changes = append(changes, copyChange(change))
+		}
+	}
+
+	return changes, nil
+}
+
+func (tx *transaction) FindLastChangeID() (int64, error) {
+
+	if err := tx.readable(); err != nil {
+		return 0, err
+	}
+
+	if len(tx.history) == 0 {
+		return 0, nil
+	}
+
+	return tx.history[len(tx.history)-1].ID, nil
+}
+
+// Returns a copy of change, as read.
+func copyChange(change graph.Change) graph.Change {
+
+	if change.Before != nil {
+		change.Before = clone(change.Before)
+	}
+	if change.After != nil {
+		change.After = clone(change.After)
+	}
+
+	return change
+}
+
+func contains(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
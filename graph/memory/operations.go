@@ -0,0 +1,647 @@
+package backend
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) InsertEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := edgeKey{vertexKey{fromVertexType, fromVertexID}, key, vertexKey{toVertexType, toVertexID}}
+
+	_, fromOK := tx.findVertex(k.From)
+	_, toOK := tx.findVertex(k.To)
+	if !fromOK || !toOK {
+		return graph.ErrNoRows
+	} else if _, ok := tx.edges[k]; ok {
+		return graph.ErrConflict
+	}
+
+	created := now()
+
+	tx.rowid++
+	tx.writeEdges()[k] = edge{
+		rowid:    tx.rowid,
+		position: position,
+		meta:     clone(meta),
+		created:  created,
+		updated:  created,
+	}
+
+	if _, ok := tx.edgeKeys[typeKey{fromVertexType, key}]; !ok {
+		tx.writeEdgeKeys()[typeKey{fromVertexType, key}] = cardinality{}
+	}
+
+	edge, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordEdge(graph.Insert, nil, &edge)
+}
+
+func (tx *transaction) InsertVertex(vertexType, vertexID string, attributes, meta []byte) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := vertexKey{vertexType, vertexID}
+
+	// Tombstoned vertices are held, and so conflict, until purged
+	if _, ok := tx.vertices[k]; ok {
+		return graph.ErrConflict
+	}
+
+	created := now()
+
+	tx.rowid++
+	tx.writeVertices()[k] = vertex{
+		rowid:      tx.rowid,
+		attributes: clone(attributes),
+		meta:       clone(meta),
+		revision:   1,
+		created:    created,
+		updated:    created,
+	}
+
+	if _, ok := tx.vertexTypes[vertexType]; !ok {
+		tx.writeVertexTypes()[vertexType] = struct{}{}
+	}
+
+	vertex, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Insert, nil, &vertex)
+}
+
+func (tx *transaction) DeleteVertex(vertexType, vertexID string, revision int64) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	} else if revision != 0 && revision != before.Revision {
+		return graph.ErrStaleRevision
+	}
+
+	// Edges from and to the vertex are deleted with it
+	edges := tx.deleteVertex(vertexKey{vertexType, vertexID})
+
+	for i := range edges {
+		err = tx.recordEdge(graph.Delete, &edges[i], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.recordVertex(graph.Delete, &before, nil)
+}
+
+// Deletes the vertex of k and the edges from and to it, returning the
+// edges, in the order in which they were inserted.
+func (tx *transaction) deleteVertex(k vertexKey) []graph.Edge {
+
+	edges := tx.findIncidentEdges(k)
+
+	for _, e := range edges {
+		delete(tx.writeEdges(), edgeKey{
+			vertexKey{e.From.Type, e.From.Identifier},
+			e.Key,
+			vertexKey{e.To.Type, e.To.Identifier},
+		})
+	}
+
+	delete(tx.writeVertices(), k)
+
+	return edges
+}
+
+func (tx *transaction) UpdateEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	before, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	k := edgeKey{vertexKey{fromVertexType, fromVertexID}, key, vertexKey{toVertexType, toVertexID}}
+
+	e := tx.edges[k]
+	e.position = position
+	e.meta = clone(meta)
+	e.updated = now()
+	tx.writeEdges()[k] = e
+
+	after, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordEdge(graph.Update, &before, &after)
+}
+
+func (tx *transaction) UpdateVertex(vertexType, vertexID string, revision int64, attributes, meta []byte) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	} else if revision != 0 && revision != before.Revision {
+		return graph.ErrStaleRevision
+	}
+
+	k := vertexKey{vertexType, vertexID}
+
+	v := tx.vertices[k]
+	v.attributes, v.meta = clone(attributes), clone(meta)
+	v.revision++
+	v.updated = now()
+	tx.writeVertices()[k] = v
+
+	after, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Update, &before, &after)
+}
+
+func (tx *transaction) DeleteEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	before, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	delete(tx.writeEdges(), edgeKey{vertexKey{fromVertexType, fromVertexID}, key, vertexKey{toVertexType, toVertexID}})
+
+	return tx.recordEdge(graph.Delete, &before, nil)
+}
+
+func (tx *transaction) DeleteEdges(fromVertexType, fromVertexID, key string) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	from := vertexKey{fromVertexType, fromVertexID}
+
+	for _, e := range tx.findIncidentEdges(from) {
+
+		k := edgeKey{
+			vertexKey{e.From.Type, e.From.Identifier},
+			e.Key,
+			vertexKey{e.To.Type, e.To.Identifier},
+		}
+		if k.From != from || k.Key != key {
+			continue
+		}
+
+		delete(tx.writeEdges(), k)
+
+		err := tx.recordEdge(graph.Delete, &e, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) CountVertices(vertexType string, filters []graph.Filter) (int64, error) {
+
+	var count int64
+
+	err := tx.scanVertices(vertexType, filters, func(vertexKey, vertex) {
+		count++
+	})
+
+	return count, err
+}
+
+func (tx *transaction) FindVertices(vertexType string, filters []graph.Filter, sort []graph.SortKey, page graph.Page) ([]graph.Vertex, error) {
+
+	var (
+		vertices []graph.Vertex
+		terms    [][]interface{}
+	)
+
+	keyset, err := newKeyset(sort, page, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.scanVertices(vertexType, filters, func(k vertexKey, v vertex) {
+		vertex := tx.vertex(k, v)
+		vertex.Revision = 0
+		vertices = append(vertices, vertex)
+		terms = append(terms, keyset.terms(k, v, k.ID, v.rowid))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := keyset.page(terms, page)
+	found := make([]graph.Vertex, 0, len(indexes))
+
+	for _, i := range indexes {
+		vertices[i].Cursor, err = keyset.cursor(terms[i])
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, vertices[i])
+	}
+
+	if keyset.Reverse {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found, nil
+}
+
+// Calls fn with each vertex of vertexType, not tombstoned, matching
+// filters, in no order.
+func (tx *transaction) scanVertices(vertexType string, filters []graph.Filter, fn func(vertexKey, vertex)) error {
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	match, err := conjunction(filters)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range tx.vertices {
+		if k.Type == vertexType && v.deleted.IsZero() && match(k, v) {
+			fn(k, v)
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindVertexType(vertexType string) error {
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	if _, ok := tx.vertexTypes[vertexType]; !ok {
+		return graph.ErrNoRows
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindVertexTypes() ([]string, error) {
+
+	var types []string
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	for vertexType := range tx.vertexTypes {
+		types = append(types, vertexType)
+	}
+
+	sort.Strings(types)
+
+	return types, nil
+}
+
+func (tx *transaction) FindVertex(vertexType, vertexID string) (graph.Vertex, error) {
+
+	if err := tx.readable(); err != nil {
+		return graph.Vertex{}, err
+	}
+
+	k := vertexKey{vertexType, vertexID}
+
+	v, ok := tx.findVertex(k)
+	if !ok {
+		return graph.Vertex{}, graph.ErrNoRows
+	}
+
+	return tx.vertex(k, v), nil
+}
+
+// Returns the vertex of k, unless missing or tombstoned.
+func (tx *transaction) findVertex(k vertexKey) (vertex, bool) {
+
+	v, ok := tx.vertices[k]
+	if !ok || !v.deleted.IsZero() {
+		return vertex{}, false
+	}
+
+	return v, true
+}
+
+// Returns the vertex of k and v, as found.
+func (tx *transaction) vertex(k vertexKey, v vertex) graph.Vertex {
+	return graph.Vertex{
+		Type:       k.Type,
+		Identifier: k.ID,
+		Attributes: clone(v.attributes),
+		Meta:       clone(v.meta),
+		Revision:   v.revision,
+		Created:    v.created,
+		Updated:    v.updated,
+	}
+}
+
+func (tx *transaction) FindDistinctEdgeKeys(fromVertexType, fromVertexID string) ([]string, error) {
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	from := vertexKey{fromVertexType, fromVertexID}
+
+	if _, ok := tx.findVertex(from); !ok {
+		return nil, nil
+	}
+
+	keys := make(map[string]struct{})
+	for k := range tx.edges {
+		if k.From == from {
+			keys[k.Key] = struct{}{}
+		}
+	}
+
+	return sortedKeys(keys), nil
+}
+
+func (tx *transaction) FindAttributeKeys(vertexType string) ([]string, error) {
+
+	keys := make(map[string]struct{})
+
+	err := tx.scanVertices(vertexType, nil, func(k vertexKey, v vertex) {
+		attributeKeys(v.attributes, keys)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(keys), nil
+}
+
+func (tx *transaction) DeclareEdgeKey(fromVertexType, key string, c graph.Cardinality) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := typeKey{fromVertexType, key}
+
+	if existing := tx.edgeKeys[k]; !existing.declared {
+		tx.writeEdgeKeys()[k] = cardinality{c, c != ""}
+	}
+
+	declared, err := tx.FindEdgeKey(fromVertexType, key)
+	if err != nil {
+		return err
+	} else if declared != c {
+		return graph.ErrConflict
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindEdgeKey(fromVertexType, key string) (graph.Cardinality, error) {
+
+	if err := tx.readable(); err != nil {
+		return "", err
+	}
+
+	c, ok := tx.edgeKeys[typeKey{fromVertexType, key}]
+	if !ok {
+		return "", graph.ErrNoRows
+	}
+
+	return c.Cardinality, nil
+}
+
+func (tx *transaction) FindEdgeKeys(fromVertexType string) ([]string, error) {
+
+	if err := tx.readable(); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{})
+	for k := range tx.edgeKeys {
+		if k.Type == fromVertexType {
+			keys[k.Key] = struct{}{}
+		}
+	}
+
+	return sortedKeys(keys), nil
+}
+
+func (tx *transaction) CountRelatedVertices(fromVertexType, fromVertexID, key string, filters []graph.Filter) (int64, error) {
+
+	var count int64
+
+	err := tx.scanEdges(vertexKey{fromVertexType, fromVertexID}, key, filters, func(edgeKey, edge) {
+		count++
+	})
+
+	return count, err
+}
+
+func (tx *transaction) FindRelatedAttributeKeys(fromVertexType, fromVertexID, key string) ([]string, error) {
+
+	keys := make(map[string]struct{})
+
+	err := tx.scanEdges(vertexKey{fromVertexType, fromVertexID}, key, nil, func(k edgeKey, e edge) {
+		attributeKeys(tx.vertices[k.To].attributes, keys)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(keys), nil
+}
+
+func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, filters []graph.Filter, sort []graph.SortKey, page graph.Page) ([]graph.Edge, error) {
+
+	var (
+		edges []graph.Edge
+		terms [][]interface{}
+	)
+
+	keyset, err := newKeyset(sort, page, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.scanEdges(vertexKey{fromVertexType, fromVertexID}, key, filters, func(k edgeKey, e edge) {
+		edge := tx.edge(k, e)
+		edge.Position = e.position
+		edges = append(edges, edge)
+		terms = append(terms, keyset.terms(k.To, tx.vertices[k.To], e.position, e.rowid))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := keyset.page(terms, page)
+	found := make([]graph.Edge, 0, len(indexes))
+
+	for _, i := range indexes {
+		edges[i].Cursor, err = keyset.cursor(terms[i])
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, edges[i])
+	}
+
+	if keyset.Reverse {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found, nil
+}
+
+// Calls fn with each edge keyed by key from the vertex of from, neither it
+// nor the vertex to which it is tombstoned, of which that vertex matches
+// filters, in no order.
+func (tx *transaction) scanEdges(from vertexKey, key string, filters []graph.Filter, fn func(edgeKey, edge)) error {
+
+	if err := tx.readable(); err != nil {
+		return err
+	}
+
+	match, err := conjunction(filters)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := tx.findVertex(from); !ok {
+		return nil
+	}
+
+	for k, e := range tx.edges {
+		if k.From != from || k.Key != key {
+			continue
+		}
+		if to, ok := tx.findVertex(k.To); ok && match(k.To, to) {
+			fn(k, e)
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) (graph.Edge, error) {
+
+	if err := tx.readable(); err != nil {
+		return graph.Edge{}, err
+	}
+
+	k := edgeKey{vertexKey{fromVertexType, fromVertexID}, key, vertexKey{toVertexType, toVertexID}}
+
+	e, ok := tx.edges[k]
+	if !ok {
+		return graph.Edge{}, graph.ErrNoRows
+	}
+
+	_, fromOK := tx.findVertex(k.From)
+	_, toOK := tx.findVertex(k.To)
+	if !fromOK || !toOK {
+		return graph.Edge{}, graph.ErrNoRows
+	}
+
+	edge := tx.edge(k, e)
+	edge.Position = e.position
+
+	return edge, nil
+}
+
+// Returns the edge of k and e, as found, but for its position.
+func (tx *transaction) edge(k edgeKey, e edge) graph.Edge {
+
+	from, to := tx.vertices[k.From], tx.vertices[k.To]
+
+	return graph.Edge{
+		From: graph.Vertex{
+			Type:       k.From.Type,
+			Identifier: k.From.ID,
+			Attributes: clone(from.attributes),
+			Meta:       clone(from.meta),
+		},
+		To: graph.Vertex{
+			Type:       k.To.Type,
+			Identifier: k.To.ID,
+			Attributes: clone(to.attributes),
+			Meta:       clone(to.meta),
+		},
+		Key:     k.Key,
+		Meta:    clone(e.meta),
+		Created: e.created,
+		Updated: e.updated,
+	}
+}
+
+// Adds the keys of attributes, if an object, to keys.
+func attributeKeys(attributes []byte, keys map[string]struct{}) {
+
+	var object map[string]json.RawMessage
+
+	if json.Unmarshal(attributes, &object) != nil {
+		return
+	}
+
+	for key := range object {
+		keys[key] = struct{}{}
+	}
+}
+
+// Returns the keys of set, sorted, or nil if none.
+func sortedKeys(set map[string]struct{}) []string {
+
+	var keys []string
+
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,15 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+	"github.com/wamuir/go-jsonapi-server/graph/graphtest"
+)
+
+func TestOperations(t *testing.T) {
+
+	graphtest.Run(t, func() graph.Graph {
+		return New()
+	})
+}
@@ -0,0 +1,113 @@
+package backend
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) ClaimMessages(consumer string, limit int64, lease time.Duration) (graph.Claim, error) {
+
+	claim := graph.Claim{Consumer: consumer}
+
+	if err := tx.writable(); err != nil {
+		return claim, err
+	}
+
+	c, ok := tx.consumers[consumer]
+	if !ok {
+		tx.writeConsumers()[consumer] = c
+	}
+
+	at := time.Now()
+
+	if c.claim != "" && c.until.After(at) {
+		return claim, graph.ErrClaimed
+	}
+
+	// Messages are appended in order of ID
+	i := sort.Search(len(tx.outbox), func(i int) bool {
+		return tx.outbox[i].ID > c.position
+	})
+
+	for _, message := range tx.outbox[i:] {
+		if limit >= 0 && int64(len(claim.Messages)) >= limit {
+			break
+		}
+		claim.Messages = append(claim.Messages, copyChange(message))
+	}
+
+	if len(claim.Messages) == 0 {
+		return claim, nil
+	}
+
+	claim.Token, claim.Expires = xid.New().String(), at.Add(lease)
+
+	c.claim, c.until = claim.Token, claim.Expires.UTC().Truncate(time.Microsecond)
+	tx.writeConsumers()[consumer] = c
+
+	return claim, nil
+}
+
+func (tx *transaction) AckMessages(claim graph.Claim) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	if len(claim.Messages) == 0 {
+		return nil
+	}
+
+	c, ok := tx.consumers[claim.Consumer]
+	if !ok || c.claim == "" || c.claim != claim.Token {
+		return graph.ErrClaimExpired
+	}
+
+	c.position = claim.Messages[len(claim.Messages)-1].ID
+	c.claim, c.until = "", time.Time{}
+	tx.writeConsumers()[claim.Consumer] = c
+
+	// Messages acked by every consumer are deleted
+	position := c.position
+	for _, c := range tx.consumers {
+		if c.position < position {
+			position = c.position
+		}
+	}
+
+	i := sort.Search(len(tx.outbox), func(i int) bool {
+		return tx.outbox[i].ID > position
+	})
+	tx.outbox = tx.outbox[i:]
+
+	return nil
+}
+
+func (tx *transaction) PurgeMessages(createdBefore time.Time) (int64, error) {
+
+	if err := tx.writable(); err != nil {
+		return 0, err
+	}
+
+	if len(tx.consumers) > 0 {
+		return 0, nil
+	}
+
+	createdBefore = createdBefore.UTC().Truncate(time.Microsecond)
+
+	var outbox []graph.Change
+
+	for _, message := range tx.outbox {
+		if !message.Time.Before(createdBefore) {
+			outbox = append(outbox, message)
+		}
+	}
+
+	count := int64(len(tx.outbox) - len(outbox))
+	tx.outbox = outbox
+
+	return count, nil
+}
@@ -0,0 +1,449 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Filters and sort keys are evaluated as by the SQLite backend, of which
+// values are NULL (nil), numeric (float64) or text (string).  A value at a
+// path within attributes is that of json_extract: a bool is numeric, as 1
+// or 0, an object or array is text, as compact JSON, and null, a missing
+// value or invalid attributes are NULL.
+
+// Returns the value at path within the attributes of the vertex of k, or
+// its identifier or times for the paths id, CreatedPath and UpdatedPath.
+func attribute(k vertexKey, v vertex, path []string) interface{} {
+
+	if len(path) == 1 && path[0] == "id" {
+		return k.ID
+	}
+
+	switch {
+	case equalPaths(path, graph.CreatedPath):
+		return v.created.Format(graph.TimeFormat)
+	case equalPaths(path, graph.UpdatedPath):
+		return v.updated.Format(graph.TimeFormat)
+	}
+
+	raw := json.RawMessage(v.attributes)
+	if !json.Valid(raw) {
+		return nil
+	}
+
+	for _, p := range path {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return nil
+		}
+		var ok bool
+		if raw, ok = object[p]; !ok {
+			return nil
+		}
+	}
+
+	var value interface{}
+
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if d.Decode(&value) != nil {
+		return nil
+	}
+
+	switch value := value.(type) {
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case bool:
+		if value {
+			return float64(1)
+		}
+		return float64(0)
+	case string:
+		return value
+	case nil:
+		return nil
+	}
+
+	var b bytes.Buffer
+	if json.Compact(&b, raw) != nil {
+		return nil
+	}
+
+	return b.String()
+}
+
+func equalPaths(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the value of a filter or cursor as bound to a query.
+func bind(v interface{}) interface{} {
+
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return float64(1)
+		}
+		return float64(0)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+
+	return v
+}
+
+// Compares a and b, of which NULL is last, then numeric, then text.
+func compare(a, b interface{}) int {
+
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case float64:
+			return 0
+		case string:
+			return 1
+		}
+		return 2
+	}
+
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch a := a.(type) {
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+
+	return 0
+}
+
+// Returns v as text, as cast by SQLite for LIKE.
+func text(v interface{}) string {
+
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Reports whether s matches pattern, as by LIKE: % matches any text, _ any
+// character, and ASCII letters match either case.
+func like(s, pattern string) bool {
+
+	if pattern == "" {
+		return s == ""
+	}
+
+	r, n := utf8.DecodeRuneInString(pattern)
+
+	switch r {
+	case '%':
+		for i := 0; ; {
+			if like(s[i:], pattern[n:]) {
+				return true
+			} else if i == len(s) {
+				return false
+			}
+			_, m := utf8.DecodeRuneInString(s[i:])
+			i += m
+		}
+	case '_':
+		if s == "" {
+			return false
+		}
+		_, m := utf8.DecodeRuneInString(s)
+		return like(s[m:], pattern[n:])
+	}
+
+	if s == "" {
+		return false
+	}
+
+	c, m := utf8.DecodeRuneInString(s)
+	if c != r && !(r < utf8.RuneSelf && c < utf8.RuneSelf && strings.EqualFold(string(c), string(r))) {
+		return false
+	}
+
+	return like(s[m:], pattern[n:])
+}
+
+// Returns the condition of f on the vertex of k, or an error for f as the
+// SQLite backend would not render.
+func filter(f graph.Filter) (func(k vertexKey, v vertex) bool, error) {
+
+	if len(f.Path) == 0 {
+		return nil, fmt.Errorf("filter has empty path")
+	}
+
+	switch f.Operator {
+	case graph.Null:
+		return func(k vertexKey, v vertex) bool {
+			return attribute(k, v, f.Path) == nil
+		}, nil
+	case graph.NotNull:
+		return func(k vertexKey, v vertex) bool {
+			return attribute(k, v, f.Path) != nil
+		}, nil
+	}
+
+	if len(f.Values) == 0 {
+		return nil, fmt.Errorf("filter %s has no values", f.Operator)
+	}
+
+	values := make([]interface{}, len(f.Values))
+	for i, value := range f.Values {
+		values[i] = bind(value)
+	}
+
+	// The comparison of a value that is not NULL with the first of values
+	var test func(a, b interface{}) bool
+
+	switch f.Operator {
+	case graph.Equal:
+		test = func(a, b interface{}) bool { return compare(a, b) == 0 }
+	case graph.NotEqual:
+		return func(k vertexKey, v vertex) bool {
+			return compare(attribute(k, v, f.Path), values[0]) != 0
+		}, nil
+	case graph.LessThan:
+		test = func(a, b interface{}) bool { return compare(a, b) < 0 }
+	case graph.LessThanOrEqual:
+		test = func(a, b interface{}) bool { return compare(a, b) <= 0 }
+	case graph.GreaterThan:
+		test = func(a, b interface{}) bool { return compare(a, b) > 0 }
+	case graph.GreaterThanOrEqual:
+		test = func(a, b interface{}) bool { return compare(a, b) >= 0 }
+	case graph.Like:
+		test = func(a, b interface{}) bool { return like(text(a), text(b)) }
+	case graph.In:
+		return func(k vertexKey, v vertex) bool {
+			a := attribute(k, v, f.Path)
+			for _, b := range values {
+				if a != nil && b != nil && compare(a, b) == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported filter operator: %s", f.Operator)
+	}
+
+	return func(k vertexKey, v vertex) bool {
+		a := attribute(k, v, f.Path)
+		return a != nil && values[0] != nil && test(a, values[0])
+	}, nil
+}
+
+// Returns the conjunction of the conditions of fs.
+func conjunction(fs []graph.Filter) (func(k vertexKey, v vertex) bool, error) {
+
+	conditions := make([]func(k vertexKey, v vertex) bool, len(fs))
+	for i, f := range fs {
+		condition, err := filter(f)
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = condition
+	}
+
+	return func(k vertexKey, v vertex) bool {
+		for _, condition := range conditions {
+			if !condition(k, v) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// keyset is the order of a query: by each of Sort on a vertex, then by
+// ties, which together identify a row.  With the Values of a cursor, the
+// query seeks the rows following the cursor, or preceding it if Reverse, in
+// which case the order is also reversed.
+type keyset struct {
+	Sort    []graph.SortKey
+	Ties    int
+	Values  []interface{}
+	Reverse bool
+}
+
+// Returns the keyset for sort and page, with ties terms after those of
+// sort, decoding the cursor of page, if any.
+func newKeyset(sort []graph.SortKey, page graph.Page, ties int) (keyset, error) {
+
+	k := keyset{
+		Sort: sort,
+		Ties: ties,
+	}
+
+	for _, key := range sort {
+		if len(key.Path) == 0 {
+			return k, fmt.Errorf("sort key has empty path")
+		}
+	}
+
+	cursor := page.After
+	if page.Before != "" {
+		if page.After != "" {
+			return k, graph.ErrInvalidCursor
+		}
+		cursor, k.Reverse = page.Before, true
+	}
+
+	if cursor == "" {
+		return k, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, graph.ErrInvalidCursor
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	err = d.Decode(&k.Values)
+	if err != nil || len(k.Values) != len(sort)+ties {
+		return k, graph.ErrInvalidCursor
+	}
+
+	for i, v := range k.Values {
+		switch v := v.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return k, graph.ErrInvalidCursor
+			}
+			k.Values[i] = f
+		case string, nil:
+		default:
+			return k, graph.ErrInvalidCursor
+		}
+	}
+
+	return k, nil
+}
+
+// Returns the terms of k for the vertex of vk, then ties.
+func (k keyset) terms(vk vertexKey, v vertex, ties ...interface{}) []interface{} {
+
+	terms := make([]interface{}, 0, len(k.Sort)+len(ties))
+	for _, key := range k.Sort {
+		terms = append(terms, attribute(vk, v, key.Path))
+	}
+	for _, tie := range ties {
+		terms = append(terms, bind(tie))
+	}
+
+	return terms
+}
+
+// Returns the cursor for a row, from its terms.
+func (k keyset) cursor(terms []interface{}) (string, error) {
+
+	b, err := json.Marshal(terms)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Reports whether the ith term of k is in descending order.
+func (k keyset) descending(i int) bool {
+
+	if i < len(k.Sort) {
+		return k.Sort[i].Descending != k.Reverse
+	}
+
+	return k.Reverse
+}
+
+// Compares terms a and b in the ordering of k.  Rows without a value for a
+// term sort last in ascending order and first in descending order.
+func (k keyset) compare(a, b []interface{}) int {
+
+	for i := range a {
+		if c := compare(a[i], b[i]); c != 0 {
+			if k.descending(i) {
+				return -c
+			}
+			return c
+		}
+	}
+
+	return 0
+}
+
+// Sorts the rows of terms, selects those following the cursor of k, then
+// those of page, and returns their indexes.
+func (k keyset) page(terms [][]interface{}, page graph.Page) []int {
+
+	var indexes []int
+
+	for i := range terms {
+		if k.Values == nil || k.compare(terms[i], k.Values) > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return k.compare(terms[indexes[i]], terms[indexes[j]]) < 0
+	})
+
+	i, j := bounds(len(indexes), page.Limit, page.Offset)
+
+	return indexes[i:j]
+}
+
+// Returns the bounds of the rows from offset, up to limit, or all if limit
+// is negative, of n rows, as by LIMIT and OFFSET.
+func bounds(n int, limit, offset int64) (int, int) {
+
+	if offset > int64(n) {
+		offset = int64(n)
+	} else if offset < 0 {
+		offset = 0
+	}
+
+	end := int64(n)
+	if limit >= 0 && limit < end-offset {
+		end = offset + limit
+	}
+
+	return int(offset), int(end)
+}
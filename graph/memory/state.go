@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// state is the graph as of a transaction.  A state, once committed, is
+// never written: a transaction that writes holds a shallow copy of the
+// state committed, and copies each map before its first write to it.
+// The slices are only appended to, and as transactions that write are
+// serialized, those appended by one never overwrite those read by another.
+type state struct {
+	vertices    map[vertexKey]vertex
+	edges       map[edgeKey]edge
+	vertexTypes map[string]struct{}
+	edgeKeys    map[typeKey]cardinality
+	history     []graph.Change
+	outbox      []graph.Change
+	consumers   map[string]consumer
+	deliveries  map[int64]graph.Delivery
+	attempts    []graph.DeliveryAttempt
+
+	// The last rowid of vertices and edges, of history and outbox, and of
+	// deliveries, which, as those of SQLite with AUTOINCREMENT, are not
+	// reused
+	rowid, changeID, messageID, deliveryID int64
+}
+
+func newState() *state {
+	return &state{
+		vertices:    make(map[vertexKey]vertex),
+		edges:       make(map[edgeKey]edge),
+		vertexTypes: make(map[string]struct{}),
+		edgeKeys:    make(map[typeKey]cardinality),
+		consumers:   make(map[string]consumer),
+		deliveries:  make(map[int64]graph.Delivery),
+	}
+}
+
+type vertexKey struct {
+	Type, ID string
+}
+
+type vertex struct {
+	rowid      int64
+	attributes []byte
+	meta       []byte
+	revision   int64
+	created    time.Time
+	updated    time.Time
+	deleted    time.Time // Zero unless tombstoned
+}
+
+type edgeKey struct {
+	From vertexKey
+	Key  string
+	To   vertexKey
+}
+
+type edge struct {
+	rowid    int64
+	position int
+	meta     []byte
+	created  time.Time
+	updated  time.Time
+}
+
+type typeKey struct {
+	Type, Key string
+}
+
+// The cardinality of an edge key, if declared.
+type cardinality struct {
+	graph.Cardinality
+	declared bool
+}
+
+type consumer struct {
+	position int64
+	claim    string // Empty unless claimed
+	until    time.Time
+}
+
+// owned records the maps of a state that a transaction has copied.
+type owned struct {
+	vertices, edges, vertexTypes, edgeKeys, consumers, deliveries bool
+}
+
+// The following return the maps of the state of tx, for writing, each
+// copied before its first write within tx.
+
+func (tx *transaction) writeVertices() map[vertexKey]vertex {
+
+	if !tx.owned.vertices {
+		m := make(map[vertexKey]vertex, len(tx.vertices))
+		for k, v := range tx.vertices {
+			m[k] = v
+		}
+		tx.vertices, tx.owned.vertices = m, true
+	}
+
+	return tx.vertices
+}
+
+func (tx *transaction) writeEdges() map[edgeKey]edge {
+
+	if !tx.owned.edges {
+		m := make(map[edgeKey]edge, len(tx.edges))
+		for k, v := range tx.edges {
+			m[k] = v
+		}
+		tx.edges, tx.owned.edges = m, true
+	}
+
+	return tx.edges
+}
+
+func (tx *transaction) writeVertexTypes() map[string]struct{} {
+
+	if !tx.owned.vertexTypes {
+		m := make(map[string]struct{}, len(tx.vertexTypes))
+		for k, v := range tx.vertexTypes {
+			m[k] = v
+		}
+		tx.vertexTypes, tx.owned.vertexTypes = m, true
+	}
+
+	return tx.vertexTypes
+}
+
+func (tx *transaction) writeEdgeKeys() map[typeKey]cardinality {
+
+	if !tx.owned.edgeKeys {
+		m := make(map[typeKey]cardinality, len(tx.edgeKeys))
+		for k, v := range tx.edgeKeys {
+			m[k] = v
+		}
+		tx.edgeKeys, tx.owned.edgeKeys = m, true
+	}
+
+	return tx.edgeKeys
+}
+
+func (tx *transaction) writeConsumers() map[string]consumer {
+
+	if !tx.owned.consumers {
+		m := make(map[string]consumer, len(tx.consumers))
+		for k, v := range tx.consumers {
+			m[k] = v
+		}
+		tx.consumers, tx.owned.consumers = m, true
+	}
+
+	return tx.consumers
+}
+
+func (tx *transaction) writeDeliveries() map[int64]graph.Delivery {
+
+	if !tx.owned.deliveries {
+		m := make(map[int64]graph.Delivery, len(tx.deliveries))
+		for k, v := range tx.deliveries {
+			m[k] = v
+		}
+		tx.deliveries, tx.owned.deliveries = m, true
+	}
+
+	return tx.deliveries
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) TombstoneVertex(vertexType, vertexID string, revision int64) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	} else if revision != 0 && revision != before.Revision {
+		return graph.ErrStaleRevision
+	}
+
+	k := vertexKey{vertexType, vertexID}
+
+	v := tx.vertices[k]
+	v.deleted = now()
+	v.revision++
+	tx.writeVertices()[k] = v
+
+	return tx.recordVertex(graph.Tombstone, &before, nil)
+}
+
+func (tx *transaction) RestoreVertex(vertexType, vertexID string) error {
+
+	if err := tx.writable(); err != nil {
+		return err
+	}
+
+	k := vertexKey{vertexType, vertexID}
+
+	v, ok := tx.vertices[k]
+	if !ok || v.deleted.IsZero() {
+		return graph.ErrNoRows
+	}
+
+	v.deleted = time.Time{}
+	v.revision++
+	v.updated = now()
+	tx.writeVertices()[k] = v
+
+	after, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Restore, nil, &after)
+}
+
+func (tx *transaction) PurgeVertices(tombstonedBefore time.Time) (int64, error) {
+
+	var (
+		count    int64
+		vertices []vertexKey
+	)
+
+	if err := tx.writable(); err != nil {
+		return count, err
+	}
+
+	for k, v := range tx.vertices {
+		if !v.deleted.IsZero() && v.deleted.Before(tombstonedBefore) {
+			vertices = append(vertices, k)
+		}
+	}
+
+	sort.Slice(vertices, func(i, j int) bool {
+		return tx.vertices[vertices[i]].rowid < tx.vertices[vertices[j]].rowid
+	})
+
+	for _, k := range vertices {
+
+		vertex := tx.vertex(k, tx.vertices[k])
+
+		// Edges from and to the vertex are deleted with it
+		edges := tx.deleteVertex(k)
+
+		for j := range edges {
+			err := tx.recordEdge(graph.Delete, &edges[j], nil)
+			if err != nil {
+				return count, err
+			}
+		}
+
+		err := tx.recordVertex(graph.Delete, &vertex, nil)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
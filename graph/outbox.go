@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ClaimMessages fails with ErrClaimed while another claim of the
+	// consumer is held
+	ErrClaimed = errors.New("outbox is claimed by consumer")
+
+	// AckMessages fails with ErrClaimExpired if the claim expired and
+	// another was made since
+	ErrClaimExpired = errors.New("claim of outbox has expired")
+)
+
+// Outbox is the outbox of a graph: each Change made by a transaction, also
+// written as a message to the outbox within the transaction, so that it is
+// published if and only if the transaction commits.
+//
+// Each consumer, by name, is given the messages in order, once each: a
+// claim holds the messages following those last acked by the consumer, and
+// no other claim by the consumer is made until it is acked or expires.  A
+// consumer is registered by its first claim, from the first message then in
+// the outbox.  Messages acked by every consumer are deleted, and, while no
+// consumer is registered, PurgeMessages deletes those created before a
+// time, so that the outbox of a graph without consumers is bounded by the
+// retention of its caller.
+//
+// A consumer whose effects are written to the graph, in the transaction in
+// which the claim is acked, processes each message exactly once.  Other
+// consumers process each at least once, more only if a claim expires.
+type Outbox interface {
+	AckMessages(claim Claim) error
+	ClaimMessages(consumer string, limit int64, lease time.Duration) (Claim, error)
+	PurgeMessages(createdBefore time.Time) (int64, error)
+}
+
+// Claim is a claim by Consumer of Messages, held until Expires.  A claim
+// of no messages is not held.
+type Claim struct {
+	Consumer string
+	Token    string
+	Expires  time.Time
+	Messages []Change
+}
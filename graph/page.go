@@ -0,0 +1,12 @@
+package graph
+
+// Page selects part of a sorted result: at most Limit vertices (or edges),
+// being those following the cursor After or preceding the cursor Before,
+// or otherwise those from Offset.  Cursors are opaque and are taken from
+// Vertex.Cursor or Edge.Cursor in a result with the same sort.
+type Page struct {
+	Limit  int64
+	Offset int64
+	After  string
+	Before string
+}
@@ -0,0 +1,209 @@
+package backend
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) InsertDelivery(delivery graph.Delivery) error {
+
+	created := now()
+
+	due := created
+	if !delivery.NextAttempt.IsZero() {
+		due = delivery.NextAttempt.UTC().Format(graph.TimeFormat)
+	}
+
+	_, err := tx.Prepared["InsertDelivery"].Exec(
+		delivery.SubscriptionType,
+		delivery.SubscriptionID,
+		delivery.ChangeID,
+		delivery.Event,
+		string(delivery.Payload),
+		string(graph.Pending),
+		due,
+		created,
+		created,
+	)
+
+	return err
+}
+
+func (tx *transaction) UpdateDelivery(delivery graph.Delivery) error {
+
+	result, err := tx.Prepared["UpdateDelivery"].Exec(
+		string(delivery.State),
+		delivery.Attempts,
+		delivery.NextAttempt.UTC().Format(graph.TimeFormat),
+		now(),
+		delivery.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrNoRows
+	}
+
+	return nil
+}
+
+func (tx *transaction) InsertDeliveryAttempt(attempt graph.DeliveryAttempt) error {
+
+	var statusCode interface{}
+
+	if attempt.StatusCode != 0 {
+		statusCode = attempt.StatusCode
+	}
+
+	_, err := tx.Prepared["InsertDeliveryAttempt"].Exec(
+		attempt.DeliveryID,
+		attempt.Time.UTC().Format(graph.TimeFormat),
+		statusCode,
+		nullString(attempt.Error),
+	)
+
+	return err
+}
+
+func (tx *transaction) FindDueDeliveries(due time.Time, limit int64) ([]graph.Delivery, error) {
+
+	rows, err := tx.Prepared["FindDueDeliveries"].Query(
+		due.UTC().Format(graph.TimeFormat),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+func (tx *transaction) ClaimDueDeliveries(due, until time.Time, limit int64) ([]graph.Delivery, error) {
+
+	var claimed []graph.Delivery
+
+	deliveries, err := tx.FindDueDeliveries(due, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range deliveries {
+
+		result, err := tx.Prepared["ClaimDelivery"].Exec(
+			until.UTC().Format(graph.TimeFormat),
+			now(),
+			delivery.ID,
+			due.UTC().Format(graph.TimeFormat),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Not claimed if claimed by another since found
+		count, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		} else if count == 0 {
+			continue
+		}
+
+		claimed = append(claimed, delivery)
+	}
+
+	return claimed, nil
+}
+
+func (tx *transaction) FindDeliveries(subscriptionType, subscriptionID string, page graph.Page) ([]graph.Delivery, error) {
+
+	rows, err := tx.Prepared["FindDeliveries"].Query(
+		subscriptionType,
+		subscriptionID,
+		page.Limit,
+		page.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+func (tx *transaction) FindDeliveryAttempts(deliveryID int64) ([]graph.DeliveryAttempt, error) {
+
+	var attempts []graph.DeliveryAttempt
+
+	rows, err := tx.Prepared["FindDeliveryAttempts"].Query(
+		deliveryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			attempt    graph.DeliveryAttempt
+			statusCode sql.NullInt64
+			e          sql.NullString
+		)
+
+		err = rows.Scan(
+			&attempt.DeliveryID,
+			timestamp{&attempt.Time},
+			&statusCode,
+			&e,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		attempt.StatusCode, attempt.Error = int(statusCode.Int64), e.String
+
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// Returns the deliveries of rows, which are closed.
+func scanDeliveries(rows *sql.Rows) ([]graph.Delivery, error) {
+
+	var deliveries []graph.Delivery
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var delivery graph.Delivery
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionType,
+			&delivery.SubscriptionID,
+			&delivery.ChangeID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.State,
+			&delivery.Attempts,
+			timestamp{&delivery.NextAttempt},
+			timestamp{&delivery.Created},
+			timestamp{&delivery.Updated},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
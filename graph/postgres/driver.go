@@ -11,17 +11,20 @@ import (
 	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
+//go:embed migrations/*.sql
+//go:embed queries/*.sql
 //go:embed schema/*.sql
 //go:embed statements/*.sql
 var fs embed.FS
 
 // Connect opens a connection to a SQLite3 database and returns a graph, as
 // *graph.Graph.  Argument `dsn` (data source name) is connection string.
+// The schema of the database is migrated to the last migration, if before.
 func Connect(dsn string) (graph.Graph, error) {
 
 	var g graph.Graph
 
-	g, err := newConnection(dsn)
+	g, err := newConnection(dsn, true)
 	if err != nil {
 		return nil, err
 	}
@@ -29,13 +32,58 @@ func Connect(dsn string) (graph.Graph, error) {
 	return g, nil
 }
 
+// Open is as Connect, but fails with graph.ErrSchemaOlder, rather than
+// migrating, if the schema of the database is before the last migration.
+func Open(dsn string) (graph.Graph, error) {
+
+	var g graph.Graph
+
+	g, err := newConnection(dsn, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Migrate migrates the schema of the PostgreSQL database of dsn to the last
+// migration, returning the versions of the schema before and after.
+func Migrate(dsn string) (int, int, error) {
+
+	conn, err := open(dsn)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	return conn.migrate(true)
+}
+
 type connection struct {
 	*sql.DB
 	closer func() error
 }
 
-// Returns a connection object.
-func newConnection(dsn string) (*connection, error) {
+// Returns a connection object, of which the schema is migrated, if migrate,
+// and verified.
+func newConnection(dsn string, migrate bool) (*connection, error) {
+
+	conn, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = conn.migrate(migrate)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Returns a connection object, without regard to its schema.
+func open(dsn string) (*connection, error) {
 
 	var conn connection
 
@@ -53,11 +101,6 @@ func newConnection(dsn string) (*connection, error) {
 
 	conn = connection{db, db.Close}
 
-	err = conn.setup()
-	if err != nil {
-		return nil, err
-	}
-
 	return &conn, nil
 }
 
@@ -77,44 +120,10 @@ func (conn connection) Transaction(ctx context.Context, readOnly bool) (graph.Tx
 	return tx, nil
 }
 
-func (conn connection) setup() error {
-
-	tx, err := conn.newTransaction(context.TODO(), false, false)
-	if err != nil {
-		return err
-	}
-
-	keys := []string{
-		"CreateTableVertices.sql",
-		"CreateIndexVertices.sql",
-		"CreateTableEdges.sql",
-		"CreateIndexEdges.sql",
-		"CreateIndexEdgesFk.sql",
-	}
-
-	for _, k := range keys {
-		p := filepath.Join("schema", k)
-		data, err := fs.ReadFile(p)
-		if err != nil {
-			return err
-		}
-
-		if _, err := tx.Exec(string(data)); err != nil {
-			return err
-		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 type transaction struct {
 	*sql.Tx
 	Prepared map[string]*sql.Stmt
+	Audit    graph.Audit
 }
 
 func (conn connection) newTransaction(ctx context.Context, prepare, readOnly bool) (*transaction, error) {
@@ -132,21 +141,57 @@ func (conn connection) newTransaction(ctx context.Context, prepare, readOnly boo
 	tx := transaction{
 		Tx:       t,
 		Prepared: make(map[string]*sql.Stmt),
+		Audit:    graph.AuditFromContext(ctx),
 	}
 	if prepare {
 		keys := []string{
-			"CountRelatedVertices.sql",
-			"CountVertices.sql",
+			"AckConsumer.sql",
+			"ClaimConsumer.sql",
+			"ClaimDelivery.sql",
+			"CountChanges.sql",
 			"DeleteEdge.sql",
+			"DeleteEdges.sql",
 			"DeleteVertex.sql",
+			"DumpChanges.sql",
+			"DumpEdges.sql",
+			"DumpVertices.sql",
+			"FindAttributeKeys.sql",
+			"FindConsumer.sql",
+			"FindDeliveries.sql",
+			"FindDeliveryAttempts.sql",
 			"FindDistinctEdgeKeys.sql",
+			"FindDueDeliveries.sql",
 			"FindEdge.sql",
-			"FindEdges.sql",
+			"FindEdgeKey.sql",
+			"FindEdgeKeys.sql",
+			"FindIncidentEdges.sql",
+			"FindLastChangeID.sql",
+			"FindMessages.sql",
+			"FindRelatedAttributeKeys.sql",
+			"FindTombstones.sql",
 			"FindVertex.sql",
-			"FindVertices.sql",
-			"FindVerticesNewest.sql",
+			"FindVertexType.sql",
+			"FindVertexTypes.sql",
+			"InsertChange.sql",
+			"InsertConsumer.sql",
+			"InsertDelivery.sql",
+			"InsertDeliveryAttempt.sql",
 			"InsertEdge.sql",
+			"InsertEdgeKey.sql",
+			"InsertMessage.sql",
 			"InsertVertex.sql",
+			"InsertVertexType.sql",
+			"LoadChange.sql",
+			"LoadEdge.sql",
+			"LoadVertex.sql",
+			"PruneMessages.sql",
+			"PurgeMessages.sql",
+			"PurgeVertex.sql",
+			"RestoreVertex.sql",
+			"TombstoneVertex.sql",
+			"UpdateDelivery.sql",
+			"UpdateEdge.sql",
+			"UpdateVertex.sql",
 		}
 		for _, k := range keys {
 			p := filepath.Join("statements", k)
@@ -0,0 +1,255 @@
+package backend
+
+import (
+	"database/sql"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Record a change to the vertex of vertexType and vertexID, or to the edge
+// keyed by key from it, from the state before to the state after, either of
+// which may be nil, in the history and the outbox.
+func (tx *transaction) record(vertexType, vertexID, key string, op graph.Operation, before, after []byte) error {
+
+	args := []interface{}{
+		vertexType,
+		vertexID,
+		nullString(key),
+		string(op),
+		nullString(tx.Audit.Actor),
+		nullString(tx.Audit.RequestID),
+		now(),
+		nullBytes(before),
+		nullBytes(after),
+	}
+
+	_, err := tx.Prepared["InsertChange"].Exec(args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Prepared["InsertMessage"].Exec(args...)
+
+	return err
+}
+
+// Record a change to a vertex, from before to after, either of which may be
+// nil.
+func (tx *transaction) recordVertex(op graph.Operation, before, after *graph.Vertex) error {
+
+	var (
+		v      *graph.Vertex
+		states [2][]byte
+		err    error
+	)
+
+	for i, vertex := range []*graph.Vertex{before, after} {
+		if vertex == nil {
+			continue
+		}
+		v = vertex
+		states[i], err = graph.MarshalVertex(*vertex)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.record(v.Type, v.Identifier, "", op, states[0], states[1])
+}
+
+// Record a change to an edge, from before to after, either of which may be
+// nil, in the history of the vertex from which it is.
+func (tx *transaction) recordEdge(op graph.Operation, before, after *graph.Edge) error {
+
+	var (
+		e      *graph.Edge
+		states [2][]byte
+		err    error
+	)
+
+	for i, edge := range []*graph.Edge{before, after} {
+		if edge == nil {
+			continue
+		}
+		e = edge
+		states[i], err = graph.MarshalEdge(*edge)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.record(e.From.Type, e.From.Identifier, e.Key, op, states[0], states[1])
+}
+
+// Returns the edges from and to the vertex of vertexType and vertexID.
+func (tx *transaction) findIncidentEdges(vertexType, vertexID string) ([]graph.Edge, error) {
+
+	var edges []graph.Edge
+
+	rows, err := tx.Prepared["FindIncidentEdges"].Query(
+		vertexType,
+		vertexID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var edge graph.Edge
+
+		err = rows.Scan(
+			&edge.From.Type,
+			&edge.From.Identifier,
+			&edge.From.Attributes,
+			&edge.From.Meta,
+			&edge.To.Type,
+			&edge.To.Identifier,
+			&edge.To.Attributes,
+			&edge.To.Meta,
+			&edge.Key,
+			&edge.Meta,
+			timestamp{&edge.Created},
+			timestamp{&edge.Updated},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		edges = append(edges, edge)
+	}
+
+	return edges, rows.Err()
+}
+
+func (tx *transaction) CountChanges(vertexType, vertexID string) (int64, error) {
+
+	var count int64
+
+	row := tx.Prepared["CountChanges"].QueryRow(
+		vertexType,
+		vertexID,
+	)
+
+	err := row.Scan(&count)
+	if err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+func (tx *transaction) FindChanges(vertexType, vertexID string, page graph.Page) ([]graph.Change, error) {
+
+	var changes []graph.Change
+
+	keyset, err := newKeyset("history", nil, page, "history.rowid")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.query("FindChanges", map[string]interface{}{
+		"Type":   vertexType,
+		"ID":     vertexID,
+		"Keyset": keyset,
+		"Page":   page,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			change                graph.Change
+			key, actor, requestID sql.NullString
+			values                = make([]interface{}, 1)
+		)
+
+		err = rows.Scan(
+			&change.ID,
+			&change.VertexType,
+			&change.VertexID,
+			&key,
+			&change.Operation,
+			&actor,
+			&requestID,
+			timestamp{&change.Time},
+			&change.Before,
+			&change.After,
+			&values[0],
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		change.Key, change.Actor, change.RequestID = key.String, actor.String, requestID.String
+
+		change.Cursor, err = keyset.cursor(values)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	if keyset.Reverse {
+		for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+			changes[i], changes[j] = changes[j], changes[i]
+		}
+	}
+
+	return changes, rows.Err()
+}
+
+func (tx *transaction) FindChangesAfter(id int64, vertexTypes []string, limit int64) ([]graph.Change, error) {
+
+	rows, err := tx.query("FindChangesAfter", map[string]interface{}{
+		"ID":    id,
+		"Types": vertexTypes,
+		"Limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scanChanges(rows)
+}
+
+func (tx *transaction) FindLastChangeID() (int64, error) {
+
+	var id int64
+
+	row := tx.Prepared["FindLastChangeID"].QueryRow()
+
+	err := row.Scan(&id)
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// Returns s, or NULL if empty.
+func nullString(s string) interface{} {
+
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// Returns b as a string, or NULL if nil.
+func nullBytes(b []byte) interface{} {
+
+	if b == nil {
+		return nil
+	}
+
+	return string(b)
+}
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS vertex_types (
+    rowid SERIAL PRIMARY KEY,
+    type TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS edge_keys (
+    rowid SERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    key TEXT NOT NULL,
+    UNIQUE (type, key)
+);
+
+INSERT INTO vertex_types(type)
+SELECT DISTINCT type
+  FROM vertices
+    ON CONFLICT DO NOTHING;
+
+INSERT INTO edge_keys(type, key)
+SELECT DISTINCT vertices.type,
+       edges.key
+  FROM edges
+ INNER JOIN vertices
+    ON (edges.from_rowid=vertices.rowid)
+    ON CONFLICT DO NOTHING;
@@ -0,0 +1,11 @@
+ALTER TABLE vertices
+  ADD COLUMN IF NOT EXISTS created_at TEXT;
+
+ALTER TABLE vertices
+  ADD COLUMN IF NOT EXISTS updated_at TEXT;
+
+ALTER TABLE edges
+  ADD COLUMN IF NOT EXISTS created_at TEXT;
+
+ALTER TABLE edges
+  ADD COLUMN IF NOT EXISTS updated_at TEXT;
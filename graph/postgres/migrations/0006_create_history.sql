@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS history (
+    rowid BIGSERIAL PRIMARY KEY,
+    vertex_type TEXT NOT NULL,
+    vertex_id TEXT NOT NULL,
+    key TEXT,
+    operation TEXT NOT NULL,
+    actor TEXT,
+    request_id TEXT,
+    created_at TEXT NOT NULL,
+    before_state TEXT,
+    after_state TEXT
+);
+
+CREATE INDEX IF NOT EXISTS history_idx1
+    ON history (vertex_type, vertex_id, rowid);
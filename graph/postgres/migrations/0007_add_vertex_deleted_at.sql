@@ -0,0 +1,2 @@
+ALTER TABLE vertices
+  ADD COLUMN IF NOT EXISTS deleted_at TEXT;
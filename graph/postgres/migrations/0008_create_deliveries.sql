@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS deliveries (
+    rowid BIGSERIAL PRIMARY KEY,
+    subscription_type TEXT NOT NULL,
+    subscription_id TEXT NOT NULL,
+    change_id BIGINT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    state TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    UNIQUE(subscription_type, subscription_id, change_id)
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_idx1
+    ON deliveries (state, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+    rowid BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL,
+    attempted_at TEXT NOT NULL,
+    status_code INTEGER,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS delivery_attempts_idx1
+    ON delivery_attempts (delivery_id, rowid);
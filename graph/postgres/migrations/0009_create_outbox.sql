@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    rowid BIGSERIAL PRIMARY KEY,
+    vertex_type TEXT NOT NULL,
+    vertex_id TEXT NOT NULL,
+    key TEXT,
+    operation TEXT NOT NULL,
+    actor TEXT,
+    request_id TEXT,
+    created_at TEXT NOT NULL,
+    before_state TEXT,
+    after_state TEXT
+);
+
+CREATE TABLE IF NOT EXISTS outbox_consumers (
+    name TEXT PRIMARY KEY,
+    position BIGINT NOT NULL,
+    claim TEXT,
+    claimed_until TEXT
+);
+
+DROP TABLE IF EXISTS cursors;
@@ -0,0 +1,14 @@
+-- Attributes and meta, held as text before, are converted in place, with
+-- any not valid JSON (e.g., empty) converted to NULL
+CREATE OR REPLACE FUNCTION pg_temp.jsonb_or_null(t TEXT) RETURNS jsonb AS $$ BEGIN RETURN t::jsonb; EXCEPTION WHEN others THEN RETURN NULL; END $$ LANGUAGE plpgsql IMMUTABLE;
+
+ALTER TABLE vertices
+  ALTER COLUMN attributes TYPE jsonb USING pg_temp.jsonb_or_null(attributes),
+  ALTER COLUMN meta TYPE jsonb USING pg_temp.jsonb_or_null(meta);
+
+ALTER TABLE edges
+  ALTER COLUMN meta TYPE jsonb USING pg_temp.jsonb_or_null(meta);
+
+-- For filters on attributes for equality, by containment
+CREATE INDEX IF NOT EXISTS vertices_idx2
+    ON vertices USING GIN (attributes jsonb_path_ops);
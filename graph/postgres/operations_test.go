@@ -0,0 +1,35 @@
+package backend
+
+import (
+	"os"
+	"testing"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+	"github.com/wamuir/go-jsonapi-server/graph/graphtest"
+)
+
+// The tables of a graph, emptied before each test
+const truncate = `TRUNCATE vertices, edges, vertex_types, edge_keys, history, deliveries, delivery_attempts, outbox, outbox_consumers RESTART IDENTITY`
+
+func TestOperations(t *testing.T) {
+
+	// A database that may be emptied, as that of POSTGRES_DSN
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+
+	g, err := Connect(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+
+	graphtest.Run(t, func() graph.Graph {
+		g, _ := Connect(dsn)
+		if conn, ok := g.(*connection); ok {
+			_, _ = conn.Exec(truncate)
+		}
+		return g
+	})
+}
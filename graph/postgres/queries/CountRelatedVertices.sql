@@ -0,0 +1,8 @@
+SELECT COUNT(*)
+  FROM edges
+ INNER JOIN vertices from_vertex
+    ON (edges.from_rowid=from_vertex.rowid)
+ INNER JOIN vertices to_vertex
+    ON (edges.to_rowid=to_vertex.rowid)
+ WHERE (from_vertex.type={{bind .Type}} AND from_vertex.id={{bind .ID}} AND edges.key={{bind .Key}} AND from_vertex.deleted_at IS NULL AND to_vertex.deleted_at IS NULL){{range .Filters}}
+   AND {{filter "to_vertex" .}}{{end}}
@@ -0,0 +1,17 @@
+SELECT history.rowid,
+       history.vertex_type,
+       history.vertex_id,
+       history.key,
+       history.operation,
+       history.actor,
+       history.request_id,
+       history.created_at,
+       history.before_state,
+       history.after_state,
+       {{columns .Keyset}}
+  FROM history
+ WHERE (history.vertex_type={{bind .Type}} AND history.vertex_id={{bind .ID}}){{with seek .Keyset}}
+   AND {{.}}{{end}}
+ ORDER BY {{order .Keyset}}
+ LIMIT {{bind .Page.Limit}}
+OFFSET {{bind .Page.Offset}}
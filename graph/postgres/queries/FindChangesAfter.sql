@@ -0,0 +1,16 @@
+SELECT history.rowid,
+       history.vertex_type,
+       history.vertex_id,
+       history.key,
+       history.operation,
+       history.actor,
+       history.request_id,
+       history.created_at,
+       history.before_state,
+       history.after_state
+  FROM history
+ WHERE (history.rowid>{{bind .ID}})
+   AND (age(history.xmin) > age((txid_snapshot_xmin(txid_current_snapshot()) % 4294967296)::text::xid)){{with .Types}}
+   AND (history.vertex_type IN ({{range $i, $t := .}}{{if $i}}, {{end}}{{bind $t}}{{end}})){{end}}
+ ORDER BY history.rowid
+ LIMIT {{bind .Limit}}
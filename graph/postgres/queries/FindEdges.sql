@@ -0,0 +1,25 @@
+SELECT from_vertex.type,
+       from_vertex.id,
+       from_vertex.attributes,
+       from_vertex.meta,
+       to_vertex.type,
+       to_vertex.id,
+       to_vertex.attributes,
+       to_vertex.meta,
+       edges.key,
+       edges.meta,
+       edges.position,
+       edges.created_at,
+       edges.updated_at,
+       {{columns .Keyset}}
+  FROM edges
+ INNER JOIN vertices from_vertex
+    ON (edges.from_rowid=from_vertex.rowid)
+ INNER JOIN vertices to_vertex
+    ON (edges.to_rowid=to_vertex.rowid)
+ WHERE (from_vertex.type={{bind .Type}} AND from_vertex.id={{bind .ID}} AND edges.key={{bind .Key}} AND from_vertex.deleted_at IS NULL AND to_vertex.deleted_at IS NULL){{range .Filters}}
+   AND {{filter "to_vertex" .}}{{end}}{{with seek .Keyset}}
+   AND {{.}}{{end}}
+ ORDER BY {{order .Keyset}}
+ LIMIT {{bind .Page.Limit}}
+OFFSET {{bind .Page.Offset}}
@@ -0,0 +1,14 @@
+SELECT vertices.type,
+       vertices.id,
+       vertices.attributes,
+       vertices.meta,
+       vertices.created_at,
+       vertices.updated_at,
+       {{columns .Keyset}}
+  FROM vertices
+ WHERE (vertices.type={{bind .Type}} AND vertices.deleted_at IS NULL){{range .Filters}}
+   AND {{filter "vertices" .}}{{end}}{{with seek .Keyset}}
+   AND {{.}}{{end}}
+ ORDER BY {{order .Keyset}}
+ LIMIT {{bind .Page.Limit}}
+OFFSET {{bind .Page.Offset}}
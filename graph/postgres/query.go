@@ -0,0 +1,454 @@
+package backend
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lib/pq"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Queries are statements that vary with their arguments (e.g., filters) and
+// so are rendered from a template for each use, rather than prepared once.
+// Within a query, {{bind x}} renders a placeholder for argument x and
+// {{filter alias f}} renders the condition for filter f on the vertices
+// aliased as alias.  For a keyset k, {{columns k}} renders the columns from
+// which to make cursors, {{seek k}} the condition for rows following the
+// cursor and {{order k}} the ordering.
+var queries = template.Must(
+	template.New("queries").Funcs(new(query).funcs()).ParseFS(fs, "queries/*.sql"),
+)
+
+// query accumulates arguments, in order, as a template is rendered.
+type query struct {
+	args []interface{}
+}
+
+func (q *query) funcs() template.FuncMap {
+	return template.FuncMap{
+		"bind":    q.bind,
+		"columns": q.columns,
+		"filter":  q.filter,
+		"order":   q.order,
+		"seek":    q.seek,
+	}
+}
+
+func (q *query) bind(arg interface{}) string {
+	q.args = append(q.args, arg)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// Returns an expression for the value at path within the attributes of the
+// vertices aliased as alias, or NULL for vertices without a value there.
+// The paths id, CreatedPath and UpdatedPath are of columns instead.  The
+// expression is jsonb, or text if asText.
+func (q *query) attribute(alias string, path []string, asText bool) string {
+
+	if len(path) == 1 && path[0] == "id" {
+		if asText {
+			return alias + ".id"
+		}
+		return fmt.Sprintf("to_jsonb(%s.id)", alias)
+	}
+
+	if column, ok := timeColumn(path); ok {
+		if asText {
+			return alias + "." + column
+		}
+		return fmt.Sprintf("to_jsonb(%s.%s)", alias, column)
+	}
+
+	op := "#>"
+	if asText {
+		op = "#>>"
+	}
+
+	return fmt.Sprintf("(%s.attributes %s %s::text[])", alias, op, q.bind(pq.Array(path)))
+}
+
+// Returns a condition that the attributes of the vertices aliased as alias
+// contain v at path, which the GIN index of attributes serves, or an empty
+// string if path is of a column or may index an array.  Containment is met
+// by each vertex of which the value at path equals v, and by others where v
+// is an object or array, so it narrows a condition of equality, rather than
+// replacing it.
+func (q *query) contains(alias string, path []string, v interface{}) (string, error) {
+
+	if len(path) == 1 && path[0] == "id" {
+		return "", nil
+	} else if _, ok := timeColumn(path); ok {
+		return "", nil
+	}
+
+	for _, key := range path {
+		if _, err := strconv.Atoi(key); err == nil {
+			return "", nil
+		}
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		v = map[string]interface{}{path[i]: v}
+	}
+
+	value, err := q.value(v)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s.attributes @> %s", alias, value), nil
+}
+
+// Returns the column of the time at path, if path is CreatedPath or
+// UpdatedPath.
+func timeColumn(path []string) (string, bool) {
+
+	switch {
+	case equalPaths(path, graph.CreatedPath):
+		return "created_at", true
+	case equalPaths(path, graph.UpdatedPath):
+		return "updated_at", true
+	}
+
+	return "", false
+}
+
+func equalPaths(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Binds v as a jsonb value.
+func (q *query) value(v interface{}) (string, error) {
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return q.bind(string(b)) + "::jsonb", nil
+}
+
+func (q *query) filter(alias string, f graph.Filter) (string, error) {
+
+	if len(f.Path) == 0 {
+		return "", fmt.Errorf("filter has empty path")
+	}
+
+	switch f.Operator {
+	case graph.Null:
+		expr := q.attribute(alias, f.Path, false)
+		return fmt.Sprintf("(%[1]s IS NULL OR %[1]s = 'null'::jsonb)", expr), nil
+	case graph.NotNull:
+		expr := q.attribute(alias, f.Path, false)
+		return fmt.Sprintf("(%[1]s IS NOT NULL AND %[1]s <> 'null'::jsonb)", expr), nil
+	}
+
+	if len(f.Values) == 0 {
+		return "", fmt.Errorf("filter %s has no values", f.Operator)
+	}
+
+	var op string
+
+	switch f.Operator {
+	case graph.Equal:
+		op = "="
+	case graph.NotEqual:
+		op = "IS DISTINCT FROM"
+	case graph.LessThan:
+		op = "<"
+	case graph.LessThanOrEqual:
+		op = "<="
+	case graph.GreaterThan:
+		op = ">"
+	case graph.GreaterThanOrEqual:
+		op = ">="
+	case graph.Like:
+		expr := q.attribute(alias, f.Path, true)
+		return fmt.Sprintf("(%s LIKE %s)", expr, q.bind(fmt.Sprint(f.Values[0]))), nil
+	case graph.In:
+		expr := q.attribute(alias, f.Path, false)
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			values[i] = string(b)
+		}
+		return fmt.Sprintf("(%s = ANY(%s::jsonb[]))", expr, q.bind(pq.Array(values))), nil
+	default:
+		return "", fmt.Errorf("unsupported filter operator: %s", f.Operator)
+	}
+
+	expr := q.attribute(alias, f.Path, false)
+
+	value, err := q.value(f.Values[0])
+	if err != nil {
+		return "", err
+	}
+
+	if f.Operator == graph.Equal {
+		contains, err := q.contains(alias, f.Path, f.Values[0])
+		if err != nil {
+			return "", err
+		} else if contains != "" {
+			return fmt.Sprintf("(%s AND %s %s %s)", contains, expr, op, value), nil
+		}
+	}
+
+	return fmt.Sprintf("(%s %s %s)", expr, op, value), nil
+}
+
+// keyset is the order of a query: by each of Sort on the vertices aliased
+// as Alias, then by each of the columns Ties, which together must identify
+// a row.  With the Values of a cursor, the query seeks the rows following
+// the cursor, or preceding it if Reverse, in which case the order is also
+// reversed.  Values for Sort are jsonb text, or nil for NULL.
+type keyset struct {
+	Alias   string
+	Sort    []graph.SortKey
+	Ties    []string
+	Values  []interface{}
+	Reverse bool
+}
+
+// Returns the keyset for sort and page, decoding the cursor of page, if any.
+func newKeyset(alias string, sort []graph.SortKey, page graph.Page, ties ...string) (keyset, error) {
+
+	k := keyset{
+		Alias: alias,
+		Sort:  sort,
+		Ties:  ties,
+	}
+
+	for _, key := range sort {
+		if len(key.Path) == 0 {
+			return k, fmt.Errorf("sort key has empty path")
+		}
+	}
+
+	cursor := page.After
+	if page.Before != "" {
+		if page.After != "" {
+			return k, graph.ErrInvalidCursor
+		}
+		cursor, k.Reverse = page.Before, true
+	}
+
+	if cursor == "" {
+		return k, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, graph.ErrInvalidCursor
+	}
+
+	var raw []json.RawMessage
+
+	err = json.Unmarshal(b, &raw)
+	if err != nil || len(raw) != len(sort)+len(ties) {
+		return k, graph.ErrInvalidCursor
+	}
+
+	k.Values = make([]interface{}, len(raw))
+
+	for i, r := range raw {
+
+		if string(r) == "null" {
+			continue
+		}
+
+		if i < len(sort) {
+			k.Values[i] = string(r)
+			continue
+		}
+
+		d := json.NewDecoder(bytes.NewReader(r))
+		d.UseNumber()
+
+		err := d.Decode(&k.Values[i])
+		if err != nil {
+			return k, graph.ErrInvalidCursor
+		}
+
+		switch v := k.Values[i].(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return k, graph.ErrInvalidCursor
+			}
+			k.Values[i] = n
+		case string:
+		default:
+			return k, graph.ErrInvalidCursor
+		}
+	}
+
+	return k, nil
+}
+
+// Returns the cursor for a row, from the values of the columns of k.
+func (k keyset) cursor(values []interface{}) (string, error) {
+
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			if i < len(k.Sort) {
+				values[i] = json.RawMessage(b)
+			} else {
+				values[i] = string(b)
+			}
+		}
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Reports whether the ith term of k is in descending order.
+func (k keyset) descending(i int) bool {
+
+	if i < len(k.Sort) {
+		return k.Sort[i].Descending != k.Reverse
+	}
+
+	return k.Reverse
+}
+
+// Returns an expression for the ith term of k.  JSON null is taken as NULL,
+// so that vertices with a null attribute sort as those without.
+func (q *query) term(k keyset, i int) string {
+
+	if i < len(k.Sort) {
+		return fmt.Sprintf("NULLIF(%s, 'null'::jsonb)", q.attribute(k.Alias, k.Sort[i].Path, false))
+	}
+
+	return k.Ties[i-len(k.Sort)]
+}
+
+// Returns a placeholder for v, being the value of the ith term of k.
+func (q *query) termValue(k keyset, i int, v interface{}) string {
+
+	if i < len(k.Sort) {
+		return q.bind(v) + "::jsonb"
+	}
+
+	return q.bind(v)
+}
+
+// Returns the terms of k as a list of columns, from which to make cursors.
+func (q *query) columns(k keyset) string {
+
+	columns := make([]string, len(k.Sort)+len(k.Ties))
+	for i := range columns {
+		columns[i] = q.term(k, i)
+	}
+
+	return strings.Join(columns, ",\n       ")
+}
+
+// Returns the ordering of k.  Rows without a value for a term sort last in
+// ascending order and first in descending order.
+func (q *query) order(k keyset) string {
+
+	terms := make([]string, len(k.Sort)+len(k.Ties))
+	for i := range terms {
+		if expr := q.term(k, i); k.descending(i) {
+			terms[i] = expr + " DESC NULLS FIRST"
+		} else {
+			terms[i] = expr + " ASC NULLS LAST"
+		}
+	}
+
+	return strings.Join(terms, ", ")
+}
+
+// Returns the condition for rows following the cursor of k in the ordering
+// of k, or an empty string for k without a cursor.  A row follows where it
+// is equal on each of the first i terms and follows on the next.
+func (q *query) seek(k keyset) string {
+
+	if k.Values == nil {
+		return ""
+	}
+
+	var disjuncts []string
+
+	for i, v := range k.Values {
+
+		// Nothing follows NULL in ascending order
+		if v == nil && !k.descending(i) {
+			continue
+		}
+
+		conjuncts := make([]string, 0, i+1)
+
+		for j, w := range k.Values[:i] {
+			if expr := q.term(k, j); w == nil {
+				conjuncts = append(conjuncts, expr+" IS NULL")
+			} else {
+				conjuncts = append(conjuncts, expr+" = "+q.termValue(k, j, w))
+			}
+		}
+
+		expr, desc := q.term(k, i), k.descending(i)
+		switch {
+		case desc && v == nil:
+			conjuncts = append(conjuncts, expr+" IS NOT NULL")
+		case desc:
+			conjuncts = append(conjuncts, expr+" < "+q.termValue(k, i, v))
+		default:
+			conjuncts = append(conjuncts, "COALESCE("+expr+" > "+q.termValue(k, i, v)+", TRUE)")
+		}
+
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+
+	if len(disjuncts) == 0 {
+		return "FALSE"
+	}
+
+	return "(" + strings.Join(disjuncts, " OR ") + ")"
+}
+
+// Render the query keyed by name with data and run it within tx.
+func (tx *transaction) query(name string, data interface{}) (*sql.Rows, error) {
+
+	var (
+		b strings.Builder
+		q query
+	)
+
+	t, err := queries.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.Funcs(q.funcs()).ExecuteTemplate(&b, name+".sql", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Query(b.String(), q.args...)
+}
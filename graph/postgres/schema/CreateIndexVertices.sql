@@ -1,2 +0,0 @@
-CREATE UNIQUE INDEX IF NOT EXISTS vertices_idx1
-    ON vertices (type, id)
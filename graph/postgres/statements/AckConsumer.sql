@@ -0,0 +1,5 @@
+UPDATE outbox_consumers
+   SET position=$1,
+       claim=NULL,
+       claimed_until=NULL
+ WHERE (outbox_consumers.name=$2 AND outbox_consumers.claim=$3)
@@ -0,0 +1,4 @@
+UPDATE outbox_consumers
+   SET claim=$1,
+       claimed_until=$2
+ WHERE (outbox_consumers.name=$3)
@@ -0,0 +1,4 @@
+UPDATE deliveries
+   SET next_attempt_at=$1,
+       updated_at=$2
+ WHERE (deliveries.rowid=$3 AND deliveries.state='pending' AND deliveries.next_attempt_at<=$4)
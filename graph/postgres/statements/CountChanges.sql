@@ -0,0 +1,3 @@
+SELECT COUNT(*)
+  FROM history
+ WHERE (history.vertex_type=$1 AND history.vertex_id=$2)
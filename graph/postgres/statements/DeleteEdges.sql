@@ -0,0 +1,9 @@
+DELETE
+  FROM edges
+  WHERE from_rowid IN (
+	SELECT rowid
+	FROM vertices
+	WHERE type=$1
+	AND id=$2
+ )
+ AND key=$3
@@ -1,3 +1,4 @@
 DELETE
   FROM vertices
  WHERE (vertices.type=$1 AND vertices.id=$2)
+   AND ($3::BIGINT=0 OR vertices.revision=$3)
@@ -0,0 +1,15 @@
+SELECT from_vertex.type,
+       from_vertex.id,
+       to_vertex.type,
+       to_vertex.id,
+       edges.key,
+       edges.meta,
+       edges.position,
+       edges.created_at,
+       edges.updated_at
+  FROM edges
+ INNER JOIN vertices from_vertex
+    ON (edges.from_rowid=from_vertex.rowid)
+ INNER JOIN vertices to_vertex
+    ON (edges.to_rowid=to_vertex.rowid)
+ ORDER BY edges.rowid
@@ -0,0 +1,3 @@
+SELECT DISTINCT jsonb_object_keys(CASE WHEN jsonb_typeof(vertices.attributes)='object' THEN vertices.attributes ELSE '{}' END)
+  FROM vertices
+ WHERE (vertices.type=$1 AND vertices.deleted_at IS NULL)
@@ -0,0 +1,6 @@
+SELECT outbox_consumers.position,
+       outbox_consumers.claim,
+       outbox_consumers.claimed_until
+  FROM outbox_consumers
+ WHERE (outbox_consumers.name=$1)
+   FOR UPDATE
@@ -0,0 +1,16 @@
+SELECT deliveries.rowid,
+       deliveries.subscription_type,
+       deliveries.subscription_id,
+       deliveries.change_id,
+       deliveries.event,
+       deliveries.payload,
+       deliveries.state,
+       deliveries.attempts,
+       deliveries.next_attempt_at,
+       deliveries.created_at,
+       deliveries.updated_at
+  FROM deliveries
+ WHERE (deliveries.subscription_type=$1 AND deliveries.subscription_id=$2)
+ ORDER BY deliveries.rowid
+ LIMIT $3
+OFFSET $4
@@ -0,0 +1,7 @@
+SELECT delivery_attempts.delivery_id,
+       delivery_attempts.attempted_at,
+       delivery_attempts.status_code,
+       delivery_attempts.error
+  FROM delivery_attempts
+ WHERE (delivery_attempts.delivery_id=$1)
+ ORDER BY delivery_attempts.rowid
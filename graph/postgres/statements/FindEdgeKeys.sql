@@ -0,0 +1,4 @@
+SELECT key
+  FROM edge_keys
+ WHERE (type=$1)
+ ORDER BY key
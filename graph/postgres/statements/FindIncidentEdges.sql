@@ -7,13 +7,14 @@ SELECT from_vertex.type,
        to_vertex.attributes,
        to_vertex.meta,
        edges.key,
-       edges.meta
+       edges.meta,
+       edges.created_at,
+       edges.updated_at
   FROM edges
  INNER JOIN vertices from_vertex
     ON (edges.from_rowid=from_vertex.rowid)
  INNER JOIN vertices to_vertex
     ON (edges.to_rowid=to_vertex.rowid)
- WHERE (from_vertex.type=? AND from_vertex.id=? AND edges.key=?)
- ORDER BY edges.position ASC
- LIMIT ?
-OFFSET ?
+ WHERE (from_vertex.type=$1 AND from_vertex.id=$2)
+    OR (to_vertex.type=$1 AND to_vertex.id=$2)
+ ORDER BY edges.rowid
@@ -0,0 +1,3 @@
+SELECT COALESCE(MAX(history.rowid), 0)
+  FROM history
+ WHERE (age(history.xmin) > age((txid_snapshot_xmin(txid_current_snapshot()) % 4294967296)::text::xid))
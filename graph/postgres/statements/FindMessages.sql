@@ -0,0 +1,15 @@
+SELECT outbox.rowid,
+       outbox.vertex_type,
+       outbox.vertex_id,
+       outbox.key,
+       outbox.operation,
+       outbox.actor,
+       outbox.request_id,
+       outbox.created_at,
+       outbox.before_state,
+       outbox.after_state
+  FROM outbox
+ WHERE (outbox.rowid>$1)
+   AND (age(outbox.xmin) > age((txid_snapshot_xmin(txid_current_snapshot()) % 4294967296)::text::xid))
+ ORDER BY outbox.rowid
+ LIMIT $2
@@ -0,0 +1,7 @@
+SELECT DISTINCT jsonb_object_keys(CASE WHEN jsonb_typeof(to_vertex.attributes)='object' THEN to_vertex.attributes ELSE '{}' END)
+  FROM edges
+ INNER JOIN vertices from_vertex
+    ON (edges.from_rowid=from_vertex.rowid)
+ INNER JOIN vertices to_vertex
+    ON (edges.to_rowid=to_vertex.rowid)
+ WHERE (from_vertex.type=$1 AND from_vertex.id=$2 AND edges.key=$3 AND from_vertex.deleted_at IS NULL AND to_vertex.deleted_at IS NULL)
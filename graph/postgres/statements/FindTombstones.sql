@@ -0,0 +1,10 @@
+SELECT vertices.type,
+       vertices.id,
+       vertices.attributes,
+       vertices.meta,
+       vertices.revision,
+       vertices.created_at,
+       vertices.updated_at
+  FROM vertices
+ WHERE (vertices.deleted_at IS NOT NULL AND vertices.deleted_at<$1)
+ ORDER BY vertices.rowid
@@ -0,0 +1,3 @@
+SELECT type
+  FROM vertex_types
+ ORDER BY type
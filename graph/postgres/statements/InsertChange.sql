@@ -0,0 +1,2 @@
+INSERT INTO history(vertex_type, vertex_id, key, operation, actor, request_id, created_at, before_state, after_state)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
@@ -0,0 +1,3 @@
+INSERT INTO outbox_consumers(name, position)
+VALUES($1, 0)
+    ON CONFLICT(name) DO NOTHING
@@ -0,0 +1,3 @@
+INSERT INTO deliveries(subscription_type, subscription_id, change_id, event, payload, state, attempts, next_attempt_at, created_at, updated_at)
+VALUES($1, $2, $3, $4, $5, $6, 0, $7, $8, $9)
+    ON CONFLICT(subscription_type, subscription_id, change_id) DO NOTHING
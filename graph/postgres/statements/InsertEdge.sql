@@ -1,9 +1,11 @@
-INSERT INTO edges(from_rowid, to_rowid, key, position, meta)
+INSERT INTO edges(from_rowid, to_rowid, key, position, meta, created_at, updated_at)
 SELECT a.rowid,
        b.rowid,
        $1,
        $2,
-       $3
+       $3::jsonb,
+       $8,
+       $8
   FROM vertices a,
        vertices b
- WHERE (a.type=$4 AND a.id=$5 AND b.type=$6 AND b.id=$7)
+ WHERE (a.type=$4 AND a.id=$5 AND b.type=$6 AND b.id=$7 AND a.deleted_at IS NULL AND b.deleted_at IS NULL)
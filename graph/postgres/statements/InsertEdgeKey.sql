@@ -0,0 +1,4 @@
+INSERT INTO edge_keys(type, key, cardinality)
+VALUES($1, $2, $3)
+    ON CONFLICT (type, key) DO UPDATE
+   SET cardinality=COALESCE(edge_keys.cardinality, excluded.cardinality)
@@ -1,2 +1,2 @@
-INSERT INTO vertices(type, id, attributes, meta)
-VALUES($1, $2, $3, $4)
+INSERT INTO vertices(type, id, attributes, meta, created_at, updated_at)
+VALUES($1, $2, $3::jsonb, $4::jsonb, $5, $5)
@@ -0,0 +1,3 @@
+INSERT INTO vertex_types(type)
+VALUES($1)
+    ON CONFLICT DO NOTHING
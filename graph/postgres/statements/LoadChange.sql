@@ -0,0 +1,9 @@
+-- The sequence of rowid is advanced past that given, as the next change
+-- recorded would otherwise conflict with it
+WITH change AS (
+    INSERT INTO history(rowid, vertex_type, vertex_id, key, operation, actor, request_id, created_at, before_state, after_state)
+    VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    RETURNING rowid
+)
+SELECT setval(pg_get_serial_sequence('history', 'rowid'), change.rowid)
+  FROM change
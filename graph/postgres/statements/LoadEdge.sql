@@ -0,0 +1,11 @@
+INSERT INTO edges(from_rowid, to_rowid, key, position, meta, created_at, updated_at)
+SELECT a.rowid,
+       b.rowid,
+       $1,
+       $2,
+       $3::jsonb,
+       $4,
+       $5
+  FROM vertices a,
+       vertices b
+ WHERE (a.type=$6 AND a.id=$7 AND b.type=$8 AND b.id=$9)
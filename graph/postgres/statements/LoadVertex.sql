@@ -0,0 +1,2 @@
+INSERT INTO vertices(type, id, attributes, meta, revision, created_at, updated_at, deleted_at)
+VALUES($1, $2, $3::jsonb, $4::jsonb, $5, $6, $7, $8)
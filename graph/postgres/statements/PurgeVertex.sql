@@ -0,0 +1,3 @@
+DELETE
+  FROM vertices
+ WHERE (vertices.type=$1 AND vertices.id=$2 AND vertices.deleted_at IS NOT NULL)
@@ -0,0 +1,5 @@
+UPDATE vertices
+   SET deleted_at=NULL,
+       revision=vertices.revision+1,
+       updated_at=$1
+ WHERE (vertices.type=$2 AND vertices.id=$3 AND vertices.deleted_at IS NOT NULL)
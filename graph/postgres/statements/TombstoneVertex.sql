@@ -0,0 +1,5 @@
+UPDATE vertices
+   SET deleted_at=$1,
+       revision=vertices.revision+1
+ WHERE (vertices.type=$2 AND vertices.id=$3 AND vertices.deleted_at IS NULL)
+   AND ($4::BIGINT=0 OR vertices.revision=$4)
@@ -0,0 +1,6 @@
+UPDATE deliveries
+   SET state=$1,
+       attempts=$2,
+       next_attempt_at=$3,
+       updated_at=$4
+ WHERE (deliveries.rowid=$5)
@@ -0,0 +1,19 @@
+UPDATE edges
+   SET position=$1,
+       meta=$2::jsonb,
+       updated_at=$3
+ WHERE from_rowid IN (
+	SELECT rowid
+	FROM vertices
+	WHERE type=$4
+	AND id=$5
+	AND deleted_at IS NULL
+ )
+ AND to_rowid IN (
+	SELECT rowid
+	FROM vertices
+	WHERE type=$6
+	AND id=$7
+	AND deleted_at IS NULL
+ )
+ AND key=$8
@@ -0,0 +1,7 @@
+UPDATE vertices
+   SET attributes=$1::jsonb,
+       meta=$2::jsonb,
+       revision=vertices.revision+1,
+       updated_at=$6
+ WHERE (vertices.type=$3 AND vertices.id=$4 AND vertices.deleted_at IS NULL)
+   AND ($5::BIGINT=0 OR vertices.revision=$5)
@@ -0,0 +1,12 @@
+package graph
+
+// SortKey orders vertices by the attribute at Path from the root of the
+// attributes object, by identifier when Path is ["id"], or by the time of
+// creation or last update when Path is CreatedPath or UpdatedPath.
+// Vertices are sorted in ascending order unless Descending.  Vertices
+// without the attribute sort last in ascending order and first in
+// descending order.
+type SortKey struct {
+	Path       []string
+	Descending bool
+}
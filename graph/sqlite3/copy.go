@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) DumpVertices(fn func(graph.Vertex) error) error {
+
+	rows, err := tx.Prepared["DumpVertices"].Query()
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var vertex graph.Vertex
+
+		err = rows.Scan(
+			&vertex.Type,
+			&vertex.Identifier,
+			&vertex.Attributes,
+			&vertex.Meta,
+			&vertex.Revision,
+			timestamp{&vertex.Created},
+			timestamp{&vertex.Updated},
+			timestamp{&vertex.Tombstoned},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = fn(vertex)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (tx *transaction) DumpEdges(fn func(graph.Edge) error) error {
+
+	rows, err := tx.Prepared["DumpEdges"].Query()
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			edge     graph.Edge
+			position sql.NullInt64
+		)
+
+		err = rows.Scan(
+			&edge.From.Type,
+			&edge.From.Identifier,
+			&edge.To.Type,
+			&edge.To.Identifier,
+			&edge.Key,
+			&edge.Meta,
+			&position,
+			timestamp{&edge.Created},
+			timestamp{&edge.Updated},
+		)
+		if err != nil {
+			return err
+		}
+
+		edge.Position = int(position.Int64)
+
+		err = fn(edge)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (tx *transaction) DumpChanges(fn func(graph.Change) error) error {
+
+	rows, err := tx.Prepared["DumpChanges"].Query()
+	if err != nil {
+		return err
+	}
+
+	return eachChange(rows, fn)
+}
+
+func (tx *transaction) LoadVertex(vertex graph.Vertex) error {
+
+	_, err := tx.Prepared["LoadVertex"].Exec(
+		vertex.Type,
+		vertex.Identifier,
+		string(vertex.Attributes),
+		string(vertex.Meta),
+		vertex.Revision,
+		nullTime(vertex.Created),
+		nullTime(vertex.Updated),
+		nullTime(vertex.Tombstoned),
+	)
+	sqliteErr, ok := err.(sqlite3.Error)
+	if ok && sqliteErr.Code == sqlite3.ErrConstraint {
+		return graph.ErrConflict
+	} else if err != nil {
+		return err
+	}
+
+	_, err = tx.Prepared["InsertVertexType"].Exec(
+		vertex.Type,
+	)
+
+	return err
+}
+
+func (tx *transaction) LoadEdge(edge graph.Edge) error {
+
+	result, err := tx.Prepared["LoadEdge"].Exec(
+		edge.Key,
+		edge.Position,
+		string(edge.Meta),
+		nullTime(edge.Created),
+		nullTime(edge.Updated),
+		edge.From.Type,
+		edge.From.Identifier,
+		edge.To.Type,
+		edge.To.Identifier,
+	)
+	sqliteErr, ok := err.(sqlite3.Error)
+	if ok && sqliteErr.Code == sqlite3.ErrConstraint {
+		return graph.ErrConflict
+	} else if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrNoRows
+	}
+
+	_, err = tx.Prepared["InsertEdgeKey"].Exec(
+		edge.From.Type,
+		edge.Key,
+		nil,
+	)
+
+	return err
+}
+
+func (tx *transaction) LoadChange(change graph.Change) error {
+
+	_, err := tx.Prepared["LoadChange"].Exec(
+		change.ID,
+		change.VertexType,
+		change.VertexID,
+		nullString(change.Key),
+		string(change.Operation),
+		nullString(change.Actor),
+		nullString(change.RequestID),
+		change.Time.UTC().Format(graph.TimeFormat),
+		nullBytes(change.Before),
+		nullBytes(change.After),
+	)
+	sqliteErr, ok := err.(sqlite3.Error)
+	if ok && sqliteErr.Code == sqlite3.ErrConstraint {
+		return graph.ErrConflict
+	}
+
+	return err
+}
+
+// Returns t in TimeFormat, or NULL if zero.
+func nullTime(t time.Time) interface{} {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(graph.TimeFormat)
+}
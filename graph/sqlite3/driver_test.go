@@ -3,6 +3,9 @@ package backend
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
 func TestConnect(t *testing.T) {
@@ -33,3 +36,244 @@ func TestTransation(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMigrate(t *testing.T) {
+
+	migrations, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// every migration, from none
+	from, to, err := Migrate("file:migrate-new?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if from != 0 || to != len(migrations) {
+		t.Errorf("Migrated from %d to %d, want from 0 to %d", from, to, len(migrations))
+	}
+}
+
+func TestMigrateOlder(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	dsn := "file:migrate-old?mode=memory&cache=shared"
+
+	// held open, so that the database outlasts other connections to it
+	conn, err := open(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer conn.Close()
+
+	// a database from before migrations were recorded, with the first
+	// migration and some, but not all, of those since
+	migrations, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, statement := range append(migrations[0].statements,
+		`ALTER TABLE vertices ADD COLUMN revision INTEGER NOT NULL DEFAULT 1`,
+		`CREATE TABLE cursors (name TEXT PRIMARY KEY, position INTEGER NOT NULL)`,
+		`INSERT INTO vertices(type, id, attributes, meta) VALUES('people', '1', '{"name":"Alice"}', NULL)`,
+		`INSERT INTO vertices(type, id, attributes, meta) VALUES('people', '2', '{"name":"Bob"}', NULL)`,
+		`INSERT INTO edges(from_rowid, to_rowid, key, position, meta) VALUES(1, 2, 'friends', 0, NULL)`,
+	) {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// not migrated
+	_, err = Open(dsn)
+	if err != graph.ErrSchemaOlder {
+		t.Errorf("Got error %v, want %v", err, graph.ErrSchemaOlder)
+	}
+
+	// migrated
+	from, to, err := Migrate(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if from != 0 || to != len(migrations) {
+		t.Errorf("Migrated from %d to %d, want from 0 to %d", from, to, len(migrations))
+	}
+
+	var applied int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Error(err)
+	} else if applied != len(migrations) {
+		t.Errorf("Got %d migrations applied, want %d", applied, len(migrations))
+	}
+
+	var cursors int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name='cursors'`).Scan(&cursors); err != nil {
+		t.Error(err)
+	} else if cursors != 0 {
+		t.Error("Got table cursors, want dropped")
+	}
+
+	// again, as already migrated
+	from, to, err = Migrate(dsn)
+	if err != nil {
+		t.Error(err)
+	} else if from != len(migrations) || to != len(migrations) {
+		t.Errorf("Migrated from %d to %d, want from and to %d", from, to, len(migrations))
+	}
+
+	g, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer g.Close()
+
+	tx, err := g.Transaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Close()
+
+	// data, with the columns and tables since
+	vertex, err := tx.FindVertex("people", "1")
+	if err != nil {
+		t.Error(err)
+	} else if string(vertex.Attributes) != `{"name":"Alice"}` || vertex.Revision != 1 {
+		t.Errorf("Got vertex %s at revision %d, want {\"name\":\"Alice\"} at 1", vertex.Attributes, vertex.Revision)
+	}
+
+	types, err := tx.FindVertexTypes()
+	if err != nil {
+		t.Error(err)
+	} else if len(types) != 1 || types[0] != "people" {
+		t.Errorf("Got vertex types %v, want [people]", types)
+	}
+
+	if err := tx.DeclareEdgeKey("people", "friends", graph.ToMany); err != nil {
+		t.Error(err)
+	}
+
+	if err := tx.TombstoneVertex("people", "2", 1); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := tx.ClaimMessages("test", 10, time.Minute); err != nil {
+		t.Error(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrateRevision(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	dsn := "file:migrate-revision?mode=memory&cache=shared"
+
+	conn, err := open(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer conn.Close()
+
+	// a database from before revisions were kept, as created by
+	// CREATE TABLE IF NOT EXISTS, without the column
+	migrations, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, statement := range append(migrations[0].statements,
+		`INSERT INTO vertices(type, id, attributes, meta) VALUES('people', '1', '{"name":"Alice"}', NULL)`,
+	) {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	/////////////////////////////////////// TESTS
+
+	if _, _, err := Migrate(dsn); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	g, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer g.Close()
+
+	tx, err := g.Transaction(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Close()
+
+	// revision added by ALTER TABLE, from one
+	if vertex, err := tx.FindVertex("people", "1"); err != nil {
+		t.Fatal(err)
+	} else if vertex.Revision != 1 {
+		t.Errorf("Got revision %d, want 1", vertex.Revision)
+	}
+
+	if err := tx.UpdateVertex("people", "1", 1, []byte(`{"name":"Carol"}`), nil); err != nil {
+		t.Error(err)
+	} else if vertex, err := tx.FindVertex("people", "1"); err != nil {
+		t.Error(err)
+	} else if vertex.Revision != 2 {
+		t.Errorf("Got revision %d, want 2", vertex.Revision)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+
+	dsn := "file:migrate-newer?mode=memory&cache=shared"
+
+	conn, err := open(dsn)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer conn.Close()
+
+	_, to, err := conn.migrate(true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	_, err = conn.Exec(`INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, 'future', '')`, to+1)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, connect := range []func(string) (graph.Graph, error){Connect, Open} {
+		if _, err = connect(dsn); err != graph.ErrSchemaNewer {
+			t.Errorf("Got error %v, want %v", err, graph.ErrSchemaNewer)
+		}
+	}
+
+	if _, _, err = Migrate(dsn); err != graph.ErrSchemaNewer {
+		t.Errorf("Got error %v, want %v", err, graph.ErrSchemaNewer)
+	}
+}
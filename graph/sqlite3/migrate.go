@@ -0,0 +1,164 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// A migration of the schema, from migrations/NNNN_name.sql, of statements
+// each ending with a semicolon at the end of a line.  Migrations are
+// numbered from 1, without gaps, and applied in order, each once, within a
+// transaction of its own, in which it is recorded in schema_migrations.
+// The version of the schema is that of the last migration applied.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// Returns the migrations, in order.
+func migrations() ([]migration, error) {
+
+	var migrations []migration
+
+	entries, err := fs.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+
+		parts := strings.SplitN(strings.TrimSuffix(entry.Name(), ".sql"), "_", 2)
+
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 || version != len(migrations)+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence", entry.Name())
+		}
+
+		data, err := fs.ReadFile(filepath.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := migration{version: version, name: parts[1]}
+		for _, statement := range strings.Split(string(data), ";\n") {
+			if statement = strings.TrimSpace(statement); statement != "" {
+				m.statements = append(m.statements, statement)
+			}
+		}
+
+		migrations = append(migrations, m)
+	}
+
+	return migrations, nil
+}
+
+// Migrates the schema to the version of the last migration, if migrate,
+// returning the versions before and after.  Fails with graph.ErrSchemaNewer
+// if the schema is of a later version, or, if not migrate, with
+// graph.ErrSchemaOlder if it is of an earlier one.
+func (conn connection) migrate(migrate bool) (int, int, error) {
+
+	migrations, err := migrations()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	from := -1
+
+	for {
+		tx, err := conn.newTransaction(context.TODO(), false, false)
+		if err != nil {
+			return from, from, err
+		}
+
+		version, err := tx.schemaVersion()
+		if err != nil {
+			tx.Close()
+			return from, from, err
+		} else if from < 0 {
+			from = version
+		}
+
+		switch {
+		case version > len(migrations):
+			tx.Close()
+			return from, version, graph.ErrSchemaNewer
+		case version == len(migrations):
+			err = tx.Commit()
+			tx.Close()
+			return from, version, err
+		case !migrate:
+			tx.Close()
+			return from, version, graph.ErrSchemaOlder
+		}
+
+		m := migrations[version]
+
+		err = tx.apply(m)
+		if err == nil {
+			err = tx.Commit()
+		}
+		tx.Close()
+		if err != nil {
+			return from, version, fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+		}
+	}
+}
+
+// Returns the version of the schema, or 0 if no migration was applied.
+func (tx *transaction) schemaVersion() (int, error) {
+
+	var version sql.NullInt64
+
+	_, err := tx.schema("CreateTableSchemaMigrations.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := fs.ReadFile(filepath.Join("schema", "FindSchemaVersion.sql"))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow(string(data)).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Applies and records m.  As SQLite has no ADD COLUMN IF NOT EXISTS, a
+// column that is already, as added to a database before its migrations
+// were recorded, is not an error.
+func (tx *transaction) apply(m migration) error {
+
+	for _, statement := range m.statements {
+		_, err := tx.Exec(statement)
+		if err != nil && !strings.HasPrefix(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	_, err := tx.schema("InsertSchemaMigration.sql", m.version, m.name, now())
+
+	return err
+}
+
+// Executes schema/name with args.
+func (tx *transaction) schema(name string, args ...interface{}) (sql.Result, error) {
+
+	data, err := fs.ReadFile(filepath.Join("schema", name))
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Exec(string(data), args...)
+}
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS vertices (
+    rowid INTEGER PRIMARY KEY,
+    type TEXT NOT NULL,
+    id TEXT NOT NULL,
+    attributes TEXT,
+    meta TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS vertices_idx1
+    ON vertices (type, id);
+
+CREATE TABLE IF NOT EXISTS edges (
+    rowid INTEGER PRIMARY KEY,
+    from_rowid INTEGER NOT NULL
+        REFERENCES vertices(rowid)
+	ON DELETE CASCADE,
+    to_rowid INTEGER NOT NULL
+        REFERENCES vertices(rowid)
+	ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    position INTEGER,
+    meta TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS edges_idx1
+    ON edges (from_rowid, key, to_rowid);
+
+CREATE INDEX IF NOT EXISTS edges_idx2
+    ON edges (to_rowid);
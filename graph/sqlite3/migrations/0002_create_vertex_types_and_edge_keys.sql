@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS vertex_types (
+    rowid INTEGER PRIMARY KEY,
+    type TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS edge_keys (
+    rowid INTEGER PRIMARY KEY,
+    type TEXT NOT NULL,
+    key TEXT NOT NULL,
+    UNIQUE (type, key)
+);
+
+INSERT OR IGNORE INTO vertex_types(type)
+SELECT DISTINCT type
+  FROM vertices;
+
+INSERT OR IGNORE INTO edge_keys(type, key)
+SELECT DISTINCT vertices.type,
+       edges.key
+  FROM edges
+ INNER JOIN vertices
+    ON (edges.from_rowid=vertices.rowid);
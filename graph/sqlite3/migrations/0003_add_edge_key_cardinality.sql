@@ -0,0 +1,2 @@
+ALTER TABLE edge_keys
+  ADD COLUMN cardinality TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE vertices
+  ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
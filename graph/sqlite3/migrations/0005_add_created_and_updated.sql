@@ -0,0 +1,11 @@
+ALTER TABLE vertices
+  ADD COLUMN created_at TEXT;
+
+ALTER TABLE vertices
+  ADD COLUMN updated_at TEXT;
+
+ALTER TABLE edges
+  ADD COLUMN created_at TEXT;
+
+ALTER TABLE edges
+  ADD COLUMN updated_at TEXT;
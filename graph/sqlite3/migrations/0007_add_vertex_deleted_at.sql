@@ -0,0 +1,2 @@
+ALTER TABLE vertices
+  ADD COLUMN deleted_at TEXT;
@@ -2,16 +2,23 @@ package backend
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
 	"github.com/mattn/go-sqlite3"
 	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
 func (tx *transaction) InsertEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error {
 
+	created := now()
+
 	result, err := tx.Prepared["InsertEdge"].Exec(
 		key,
 		position,
 		string(meta),
+		created,
+		created,
 		fromVertexType,
 		fromVertexID,
 		toVertexType,
@@ -31,16 +38,34 @@ func (tx *transaction) InsertEdge(fromVertexType, fromVertexID, toVertexType, to
 		return graph.ErrNoRows
 	}
 
-	return nil
+	_, err = tx.Prepared["InsertEdgeKey"].Exec(
+		fromVertexType,
+		key,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	edge, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordEdge(graph.Insert, nil, &edge)
 }
 
 func (tx *transaction) InsertVertex(vertexType, vertexID string, attributes, meta []byte) error {
 
+	created := now()
+
 	result, err := tx.Prepared["InsertVertex"].Exec(
 		vertexType,
 		vertexID,
 		string(attributes),
 		string(meta),
+		created,
+		created,
 	)
 	sqliteErr, ok := err.(sqlite3.Error)
 	if ok && sqliteErr.Code == sqlite3.ErrConstraint {
@@ -56,14 +81,77 @@ func (tx *transaction) InsertVertex(vertexType, vertexID string, attributes, met
 		return graph.ErrNoRows
 	}
 
-	return nil
+	_, err = tx.Prepared["InsertVertexType"].Exec(
+		vertexType,
+	)
+	if err != nil {
+		return err
+	}
+
+	vertex, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Insert, nil, &vertex)
 }
 
-func (tx *transaction) DeleteVertex(vertexType, vertexID string) error {
+func (tx *transaction) DeleteVertex(vertexType, vertexID string, revision int64) error {
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	// Edges from and to the vertex are deleted with it
+	edges, err := tx.findIncidentEdges(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
 
 	result, err := tx.Prepared["DeleteVertex"].Exec(
 		vertexType,
 		vertexID,
+		revision,
+		revision,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrStaleRevision
+	}
+
+	for i := range edges {
+		err = tx.recordEdge(graph.Delete, &edges[i], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.recordVertex(graph.Delete, &before, nil)
+}
+
+func (tx *transaction) UpdateEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string, position int, meta []byte) error {
+
+	before, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Prepared["UpdateEdge"].Exec(
+		position,
+		string(meta),
+		now(),
+		fromVertexType,
+		fromVertexID,
+		toVertexType,
+		toVertexID,
+		key,
 	)
 	if err != nil {
 		return err
@@ -76,11 +164,56 @@ func (tx *transaction) DeleteVertex(vertexType, vertexID string) error {
 		return graph.ErrNoRows
 	}
 
-	return nil
+	after, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordEdge(graph.Update, &before, &after)
+}
+
+func (tx *transaction) UpdateVertex(vertexType, vertexID string, revision int64, attributes, meta []byte) error {
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Prepared["UpdateVertex"].Exec(
+		string(attributes),
+		string(meta),
+		now(),
+		vertexType,
+		vertexID,
+		revision,
+		revision,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrStaleRevision
+	}
+
+	after, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Update, &before, &after)
 }
 
 func (tx *transaction) DeleteEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) error {
 
+	before, err := tx.FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key)
+	if err != nil {
+		return err
+	}
+
 	result, err := tx.Prepared["DeleteEdge"].Exec(
 		fromVertexType,
 		fromVertexID,
@@ -99,39 +232,77 @@ func (tx *transaction) DeleteEdge(fromVertexType, fromVertexID, toVertexType, to
 		return graph.ErrNoRows
 	}
 
-	return nil
+	return tx.recordEdge(graph.Delete, &before, nil)
 }
 
-func (tx *transaction) CountVertices(vertexType string) (int64, error) {
+func (tx *transaction) DeleteEdges(fromVertexType, fromVertexID, key string) error {
 
-	var count int64
+	incident, err := tx.findIncidentEdges(fromVertexType, fromVertexID)
+	if err != nil {
+		return err
+	}
 
-	result := tx.Prepared["CountVertices"].QueryRow(
-		vertexType,
+	_, err = tx.Prepared["DeleteEdges"].Exec(
+		fromVertexType,
+		fromVertexID,
+		key,
 	)
+	if err != nil {
+		return err
+	}
 
-	err := result.Scan(&count)
+	for i, edge := range incident {
+		if edge.From.Type != fromVertexType || edge.From.Identifier != fromVertexID || edge.Key != key {
+			continue
+		}
+		err = tx.recordEdge(graph.Delete, &incident[i], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *transaction) CountVertices(vertexType string, filters []graph.Filter) (int64, error) {
+
+	var count int64
+
+	rows, err := tx.query("CountVertices", map[string]interface{}{
+		"Type":    vertexType,
+		"Filters": filters,
+	})
 	if err != nil {
 		return count, err
 	}
 
-	return count, nil
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, rows.Err()
 }
 
-func (tx *transaction) FindVertices(vertexType string, limit, offset int64, sort string) ([]graph.Vertex, error) {
+func (tx *transaction) FindVertices(vertexType string, filters []graph.Filter, sort []graph.SortKey, page graph.Page) ([]graph.Vertex, error) {
 
 	var vertices []graph.Vertex
 
-	key := "FindVertices"
-	if sort == "newest" {
-		key = "FindVerticesNewest"
+	keyset, err := newKeyset("vertices", sort, page, "vertices.id", "vertices.rowid")
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := tx.Prepared[key].Query(
-		vertexType,
-		limit,
-		offset,
-	)
+	rows, err := tx.query("FindVertices", map[string]interface{}{
+		"Type":    vertexType,
+		"Filters": filters,
+		"Keyset":  keyset,
+		"Page":    page,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +313,25 @@ func (tx *transaction) FindVertices(vertexType string, limit, offset int64, sort
 
 		var vertex graph.Vertex
 
-		err := rows.Scan(
+		values := make([]interface{}, len(sort)+2)
+		dest := []interface{}{
 			&vertex.Type,
 			&vertex.Identifier,
 			&vertex.Attributes,
 			&vertex.Meta,
-		)
+			timestamp{&vertex.Created},
+			timestamp{&vertex.Updated},
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		vertex.Cursor, err = keyset.cursor(values)
 		if err != nil {
 			return nil, err
 		}
@@ -155,9 +339,56 @@ func (tx *transaction) FindVertices(vertexType string, limit, offset int64, sort
 		vertices = append(vertices, vertex)
 	}
 
+	if keyset.Reverse {
+		for i, j := 0, len(vertices)-1; i < j; i, j = i+1, j-1 {
+			vertices[i], vertices[j] = vertices[j], vertices[i]
+		}
+	}
+
 	return vertices, nil
 }
 
+func (tx *transaction) FindVertexType(vertexType string) error {
+
+	row := tx.Prepared["FindVertexType"].QueryRow(
+		vertexType,
+	)
+
+	err := row.Scan(&vertexType)
+	if err == sql.ErrNoRows {
+		return graph.ErrNoRows
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindVertexTypes() ([]string, error) {
+
+	var types []string
+
+	rows, err := tx.Prepared["FindVertexTypes"].Query()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var vertexType string
+
+		err = rows.Scan(&vertexType)
+		if err != nil {
+			return nil, err
+		}
+
+		types = append(types, vertexType)
+	}
+
+	return types, rows.Err()
+}
+
 func (tx *transaction) FindVertex(vertexType, vertexID string) (graph.Vertex, error) {
 
 	var vertex graph.Vertex
@@ -172,6 +403,9 @@ func (tx *transaction) FindVertex(vertexType, vertexID string) (graph.Vertex, er
 		&vertex.Identifier,
 		&vertex.Attributes,
 		&vertex.Meta,
+		&vertex.Revision,
+		timestamp{&vertex.Created},
+		timestamp{&vertex.Updated},
 	)
 	if err == sql.ErrNoRows {
 		return vertex, graph.ErrNoRows
@@ -210,34 +444,134 @@ func (tx *transaction) FindDistinctEdgeKeys(fromVertexType, fromVertexID string)
 	return keys, nil
 }
 
-func (tx *transaction) CountRelatedVertices(fromVertexType, fromVertexID, key string) (int64, error) {
+func (tx *transaction) FindAttributeKeys(vertexType string) ([]string, error) {
 
-	var count int64
+	var keys []string
+
+	rows, err := tx.Prepared["FindAttributeKeys"].Query(
+		vertexType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (tx *transaction) DeclareEdgeKey(fromVertexType, key string, cardinality graph.Cardinality) error {
 
-	result := tx.Prepared["CountRelatedVertices"].QueryRow(
+	_, err := tx.Prepared["InsertEdgeKey"].Exec(
+		fromVertexType,
+		key,
+		string(cardinality),
+	)
+	if err != nil {
+		return err
+	}
+
+	declared, err := tx.FindEdgeKey(fromVertexType, key)
+	if err != nil {
+		return err
+	} else if declared != cardinality {
+		return graph.ErrConflict
+	}
+
+	return nil
+}
+
+func (tx *transaction) FindEdgeKey(fromVertexType, key string) (graph.Cardinality, error) {
+
+	var cardinality sql.NullString
+
+	row := tx.Prepared["FindEdgeKey"].QueryRow(
 		fromVertexType,
-		fromVertexID,
 		key,
 	)
 
-	err := result.Scan(&count)
+	err := row.Scan(&cardinality)
+	if err == sql.ErrNoRows {
+		return "", graph.ErrNoRows
+	} else if err != nil {
+		return "", err
+	}
+
+	return graph.Cardinality(cardinality.String), nil
+}
+
+func (tx *transaction) FindEdgeKeys(fromVertexType string) ([]string, error) {
+
+	var keys []string
+
+	rows, err := tx.Prepared["FindEdgeKeys"].Query(
+		fromVertexType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (tx *transaction) CountRelatedVertices(fromVertexType, fromVertexID, key string, filters []graph.Filter) (int64, error) {
+
+	var count int64
+
+	rows, err := tx.query("CountRelatedVertices", map[string]interface{}{
+		"Type":    fromVertexType,
+		"ID":      fromVertexID,
+		"Key":     key,
+		"Filters": filters,
+	})
 	if err != nil {
 		return count, err
 	}
 
-	return count, nil
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, rows.Err()
 }
 
-func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, limit, offset int64) ([]graph.Edge, error) {
+func (tx *transaction) FindRelatedAttributeKeys(fromVertexType, fromVertexID, key string) ([]string, error) {
 
-	var edges []graph.Edge
+	var keys []string
 
-	rows, err := tx.Prepared["FindEdges"].Query(
+	rows, err := tx.Prepared["FindRelatedAttributeKeys"].Query(
 		fromVertexType,
 		fromVertexID,
 		key,
-		limit,
-		offset,
 	)
 	if err != nil {
 		return nil, err
@@ -246,10 +580,51 @@ func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, limit
 	defer rows.Close()
 
 	for rows.Next() {
+		var key string
+
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
 
-		var edge graph.Edge
+	return keys, nil
+}
+
+func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, filters []graph.Filter, sort []graph.SortKey, page graph.Page) ([]graph.Edge, error) {
+
+	var edges []graph.Edge
 
-		err = rows.Scan(
+	keyset, err := newKeyset("to_vertex", sort, page, "edges.position", "edges.rowid")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.query("FindEdges", map[string]interface{}{
+		"Type":    fromVertexType,
+		"ID":      fromVertexID,
+		"Key":     key,
+		"Filters": filters,
+		"Keyset":  keyset,
+		"Page":    page,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			edge     graph.Edge
+			position sql.NullInt64
+		)
+
+		values := make([]interface{}, len(sort)+2)
+		dest := []interface{}{
 			&edge.From.Type,
 			&edge.From.Identifier,
 			&edge.From.Attributes,
@@ -260,7 +635,22 @@ func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, limit
 			&edge.To.Meta,
 			&edge.Key,
 			&edge.Meta,
-		)
+			&position,
+			timestamp{&edge.Created},
+			timestamp{&edge.Updated},
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		edge.Position = int(position.Int64)
+
+		edge.Cursor, err = keyset.cursor(values)
 		if err != nil {
 			return nil, err
 		}
@@ -269,12 +659,21 @@ func (tx *transaction) FindEdges(fromVertexType, fromVertexID, key string, limit
 
 	}
 
+	if keyset.Reverse {
+		for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+			edges[i], edges[j] = edges[j], edges[i]
+		}
+	}
+
 	return edges, nil
 }
 
 func (tx *transaction) FindEdge(fromVertexType, fromVertexID, toVertexType, toVertexID, key string) (graph.Edge, error) {
 
-	var edge graph.Edge
+	var (
+		edge     graph.Edge
+		position sql.NullInt64
+	)
 
 	row := tx.Prepared["FindEdge"].QueryRow(
 		fromVertexType,
@@ -295,6 +694,9 @@ func (tx *transaction) FindEdge(fromVertexType, fromVertexID, toVertexType, toVe
 		&edge.To.Meta,
 		&edge.Key,
 		&edge.Meta,
+		&position,
+		timestamp{&edge.Created},
+		timestamp{&edge.Updated},
 	)
 	if err == sql.ErrNoRows {
 		return edge, graph.ErrNoRows
@@ -302,5 +704,45 @@ func (tx *transaction) FindEdge(fromVertexType, fromVertexID, toVertexType, toVe
 		return edge, err
 	}
 
+	edge.Position = int(position.Int64)
+
 	return edge, nil
 }
+
+// Returns the time of a write, for the times at which vertices and edges
+// were created and updated.
+func now() string {
+	return time.Now().UTC().Format(graph.TimeFormat)
+}
+
+// timestamp scans a time held in TimeFormat into t, leaving the zero time
+// for NULL.
+type timestamp struct {
+	t *time.Time
+}
+
+func (s timestamp) Scan(src interface{}) error {
+
+	var v string
+
+	switch src := src.(type) {
+	case nil:
+		*s.t = time.Time{}
+		return nil
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", src)
+	}
+
+	t, err := time.Parse(graph.TimeFormat, v)
+	if err != nil {
+		return err
+	}
+
+	*s.t = t
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) ClaimMessages(consumer string, limit int64, lease time.Duration) (graph.Claim, error) {
+
+	var (
+		claim    = graph.Claim{Consumer: consumer}
+		position int64
+		token    sql.NullString
+		until    time.Time
+	)
+
+	_, err := tx.Prepared["InsertConsumer"].Exec(
+		consumer,
+	)
+	if err != nil {
+		return claim, err
+	}
+
+	row := tx.Prepared["FindConsumer"].QueryRow(
+		consumer,
+	)
+
+	err = row.Scan(&position, &token, timestamp{&until})
+	if err != nil {
+		return claim, err
+	}
+
+	at := time.Now()
+
+	if token.Valid && until.After(at) {
+		return claim, graph.ErrClaimed
+	}
+
+	rows, err := tx.Prepared["FindMessages"].Query(
+		position,
+		limit,
+	)
+	if err != nil {
+		return claim, err
+	}
+
+	claim.Messages, err = scanChanges(rows)
+	if err != nil || len(claim.Messages) == 0 {
+		return claim, err
+	}
+
+	claim.Token, claim.Expires = xid.New().String(), at.Add(lease)
+
+	_, err = tx.Prepared["ClaimConsumer"].Exec(
+		claim.Token,
+		claim.Expires.UTC().Format(graph.TimeFormat),
+		consumer,
+	)
+	if err != nil {
+		return graph.Claim{Consumer: consumer}, err
+	}
+
+	return claim, nil
+}
+
+func (tx *transaction) AckMessages(claim graph.Claim) error {
+
+	if len(claim.Messages) == 0 {
+		return nil
+	}
+
+	result, err := tx.Prepared["AckConsumer"].Exec(
+		claim.Messages[len(claim.Messages)-1].ID,
+		claim.Consumer,
+		claim.Token,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrClaimExpired
+	}
+
+	_, err = tx.Prepared["PruneMessages"].Exec()
+
+	return err
+}
+
+func (tx *transaction) PurgeMessages(createdBefore time.Time) (int64, error) {
+
+	result, err := tx.Prepared["PurgeMessages"].Exec(
+		createdBefore.UTC().Format(graph.TimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Returns the changes of rows, which are closed.
+func scanChanges(rows *sql.Rows) ([]graph.Change, error) {
+
+	var changes []graph.Change
+
+	err := eachChange(rows, func(change graph.Change) error {
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Calls fn with each change of rows, in order, until fn returns an error,
+// and closes rows.
+func eachChange(rows *sql.Rows, fn func(graph.Change) error) error {
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var (
+			change                graph.Change
+			key, actor, requestID sql.NullString
+		)
+
+		err := rows.Scan(
+			&change.ID,
+			&change.VertexType,
+			&change.VertexID,
+			&key,
+			&change.Operation,
+			&actor,
+			&requestID,
+			timestamp{&change.Time},
+			&change.Before,
+			&change.After,
+		)
+		if err != nil {
+			return err
+		}
+
+		change.Key, change.Actor, change.RequestID = key.String, actor.String, requestID.String
+
+		err = fn(change)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,4 @@
+SELECT COUNT(*)
+  FROM vertices
+ WHERE (vertices.type={{bind .Type}} AND vertices.deleted_at IS NULL){{range .Filters}}
+   AND {{filter "vertices" .}}{{end}}
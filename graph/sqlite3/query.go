@@ -0,0 +1,356 @@
+package backend
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Queries are statements that vary with their arguments (e.g., filters) and
+// so are rendered from a template for each use, rather than prepared once.
+// Within a query, {{bind x}} renders a placeholder for argument x and
+// {{filter alias f}} renders the condition for filter f on the vertices
+// aliased as alias.  For a keyset k, {{columns k}} renders the columns from
+// which to make cursors, {{seek k}} the condition for rows following the
+// cursor and {{order k}} the ordering.
+var queries = template.Must(
+	template.New("queries").Funcs(new(query).funcs()).ParseFS(fs, "queries/*.sql"),
+)
+
+// query accumulates arguments, in order, as a template is rendered.
+type query struct {
+	args []interface{}
+}
+
+func (q *query) funcs() template.FuncMap {
+	return template.FuncMap{
+		"bind":    q.bind,
+		"columns": q.columns,
+		"filter":  q.filter,
+		"order":   q.order,
+		"seek":    q.seek,
+	}
+}
+
+func (q *query) bind(arg interface{}) string {
+	q.args = append(q.args, arg)
+	return "?"
+}
+
+// Returns an expression for the value at path within the attributes of the
+// vertices aliased as alias, or NULL for vertices without valid attributes.
+// The paths id, CreatedPath and UpdatedPath are of columns instead.
+func (q *query) attribute(alias string, path []string) string {
+
+	if len(path) == 1 && path[0] == "id" {
+		return alias + ".id"
+	}
+
+	if column, ok := timeColumn(path); ok {
+		return alias + "." + column
+	}
+
+	var b strings.Builder
+	b.WriteString("$")
+	for _, p := range path {
+		b.WriteString(`."`)
+		b.WriteString(strings.ReplaceAll(p, `"`, `\"`))
+		b.WriteString(`"`)
+	}
+
+	return fmt.Sprintf(
+		"json_extract(CASE WHEN json_valid(%[1]s.attributes) THEN %[1]s.attributes END, %[2]s)",
+		alias,
+		q.bind(b.String()),
+	)
+}
+
+// Returns the column of the time at path, if path is CreatedPath or
+// UpdatedPath.
+func timeColumn(path []string) (string, bool) {
+
+	switch {
+	case equalPaths(path, graph.CreatedPath):
+		return "created_at", true
+	case equalPaths(path, graph.UpdatedPath):
+		return "updated_at", true
+	}
+
+	return "", false
+}
+
+func equalPaths(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q *query) filter(alias string, f graph.Filter) (string, error) {
+
+	if len(f.Path) == 0 {
+		return "", fmt.Errorf("filter has empty path")
+	}
+
+	switch f.Operator {
+	case graph.Null:
+		return fmt.Sprintf("(%s IS NULL)", q.attribute(alias, f.Path)), nil
+	case graph.NotNull:
+		return fmt.Sprintf("(%s IS NOT NULL)", q.attribute(alias, f.Path)), nil
+	}
+
+	if len(f.Values) == 0 {
+		return "", fmt.Errorf("filter %s has no values", f.Operator)
+	}
+
+	var op string
+
+	switch f.Operator {
+	case graph.Equal:
+		op = "="
+	case graph.NotEqual:
+		op = "IS NOT"
+	case graph.LessThan:
+		op = "<"
+	case graph.LessThanOrEqual:
+		op = "<="
+	case graph.GreaterThan:
+		op = ">"
+	case graph.GreaterThanOrEqual:
+		op = ">="
+	case graph.Like:
+		op = "LIKE"
+	case graph.In:
+		expr := q.attribute(alias, f.Path)
+		placeholders := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			placeholders[i] = q.bind(v)
+		}
+		return fmt.Sprintf("(%s IN (%s))", expr, strings.Join(placeholders, ", ")), nil
+	default:
+		return "", fmt.Errorf("unsupported filter operator: %s", f.Operator)
+	}
+
+	expr := q.attribute(alias, f.Path)
+
+	return fmt.Sprintf("(%s %s %s)", expr, op, q.bind(f.Values[0])), nil
+}
+
+// keyset is the order of a query: by each of Sort on the vertices aliased
+// as Alias, then by each of the columns Ties, which together must identify
+// a row.  With the Values of a cursor, the query seeks the rows following
+// the cursor, or preceding it if Reverse, in which case the order is also
+// reversed.
+type keyset struct {
+	Alias   string
+	Sort    []graph.SortKey
+	Ties    []string
+	Values  []interface{}
+	Reverse bool
+}
+
+// Returns the keyset for sort and page, decoding the cursor of page, if any.
+func newKeyset(alias string, sort []graph.SortKey, page graph.Page, ties ...string) (keyset, error) {
+
+	k := keyset{
+		Alias: alias,
+		Sort:  sort,
+		Ties:  ties,
+	}
+
+	for _, key := range sort {
+		if len(key.Path) == 0 {
+			return k, fmt.Errorf("sort key has empty path")
+		}
+	}
+
+	cursor := page.After
+	if page.Before != "" {
+		if page.After != "" {
+			return k, graph.ErrInvalidCursor
+		}
+		cursor, k.Reverse = page.Before, true
+	}
+
+	if cursor == "" {
+		return k, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, graph.ErrInvalidCursor
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	err = d.Decode(&k.Values)
+	if err != nil || len(k.Values) != len(sort)+len(ties) {
+		return k, graph.ErrInvalidCursor
+	}
+
+	for i, v := range k.Values {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				k.Values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				k.Values[i] = f
+			} else {
+				return k, graph.ErrInvalidCursor
+			}
+		case string, nil:
+		default:
+			return k, graph.ErrInvalidCursor
+		}
+	}
+
+	return k, nil
+}
+
+// Returns the cursor for a row, from the values of the columns of k.
+func (k keyset) cursor(values []interface{}) (string, error) {
+
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Reports whether the ith term of k is in descending order.
+func (k keyset) descending(i int) bool {
+
+	if i < len(k.Sort) {
+		return k.Sort[i].Descending != k.Reverse
+	}
+
+	return k.Reverse
+}
+
+// Returns an expression for the ith term of k.
+func (q *query) term(k keyset, i int) string {
+
+	if i < len(k.Sort) {
+		return q.attribute(k.Alias, k.Sort[i].Path)
+	}
+
+	return k.Ties[i-len(k.Sort)]
+}
+
+// Returns the terms of k as a list of columns, from which to make cursors.
+func (q *query) columns(k keyset) string {
+
+	columns := make([]string, len(k.Sort)+len(k.Ties))
+	for i := range columns {
+		columns[i] = q.term(k, i)
+	}
+
+	return strings.Join(columns, ",\n       ")
+}
+
+// Returns the ordering of k.  Rows without a value for a term sort last in
+// ascending order and first in descending order.
+func (q *query) order(k keyset) string {
+
+	terms := make([]string, len(k.Sort)+len(k.Ties))
+	for i := range terms {
+		if expr := q.term(k, i); k.descending(i) {
+			terms[i] = expr + " DESC NULLS FIRST"
+		} else {
+			terms[i] = expr + " ASC NULLS LAST"
+		}
+	}
+
+	return strings.Join(terms, ", ")
+}
+
+// Returns the condition for rows following the cursor of k in the ordering
+// of k, or an empty string for k without a cursor.  A row follows where it
+// is equal on each of the first i terms and follows on the next.
+func (q *query) seek(k keyset) string {
+
+	if k.Values == nil {
+		return ""
+	}
+
+	var disjuncts []string
+
+	for i, v := range k.Values {
+
+		// Nothing follows NULL in ascending order
+		if v == nil && !k.descending(i) {
+			continue
+		}
+
+		conjuncts := make([]string, 0, i+1)
+
+		for j, w := range k.Values[:i] {
+			if expr := q.term(k, j); w == nil {
+				conjuncts = append(conjuncts, expr+" IS NULL")
+			} else {
+				conjuncts = append(conjuncts, expr+" = "+q.bind(w))
+			}
+		}
+
+		expr, desc := q.term(k, i), k.descending(i)
+		switch {
+		case desc && v == nil:
+			conjuncts = append(conjuncts, expr+" IS NOT NULL")
+		case desc:
+			conjuncts = append(conjuncts, expr+" < "+q.bind(v))
+		default:
+			conjuncts = append(conjuncts, "COALESCE("+expr+" > "+q.bind(v)+", 1)")
+		}
+
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+
+	if len(disjuncts) == 0 {
+		return "(0)"
+	}
+
+	return "(" + strings.Join(disjuncts, " OR ") + ")"
+}
+
+// Render the query keyed by name with data and run it within tx.
+func (tx *transaction) query(name string, data interface{}) (*sql.Rows, error) {
+
+	var (
+		b strings.Builder
+		q query
+	)
+
+	t, err := queries.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.Funcs(q.funcs()).ExecuteTemplate(&b, name+".sql", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Query(b.String(), q.args...)
+}
@@ -1,2 +0,0 @@
-CREATE INDEX IF NOT EXISTS edges_idx2
-    ON edges (to_rowid)
@@ -0,0 +1,5 @@
+UPDATE outbox_consumers
+   SET position=?,
+       claim=NULL,
+       claimed_until=NULL
+ WHERE (outbox_consumers.name=? AND outbox_consumers.claim=?)
@@ -0,0 +1,4 @@
+UPDATE outbox_consumers
+   SET claim=?,
+       claimed_until=?
+ WHERE (outbox_consumers.name=?)
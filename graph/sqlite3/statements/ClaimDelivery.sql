@@ -0,0 +1,4 @@
+UPDATE deliveries
+   SET next_attempt_at=?,
+       updated_at=?
+ WHERE (deliveries.rowid=? AND deliveries.state='pending' AND deliveries.next_attempt_at<=?)
@@ -0,0 +1,3 @@
+SELECT COUNT(*)
+  FROM history
+ WHERE (history.vertex_type=? AND history.vertex_id=?)
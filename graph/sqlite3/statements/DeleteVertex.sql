@@ -1,3 +1,4 @@
 DELETE
   FROM vertices
  WHERE (vertices.type=? AND vertices.id=?)
+   AND (?=0 OR vertices.revision=?)
@@ -0,0 +1,12 @@
+SELECT history.rowid,
+       history.vertex_type,
+       history.vertex_id,
+       history.key,
+       history.operation,
+       history.actor,
+       history.request_id,
+       history.created_at,
+       history.before_state,
+       history.after_state
+  FROM history
+ ORDER BY history.rowid
@@ -0,0 +1,10 @@
+SELECT vertices.type,
+       vertices.id,
+       vertices.attributes,
+       vertices.meta,
+       vertices.revision,
+       vertices.created_at,
+       vertices.updated_at,
+       vertices.deleted_at
+  FROM vertices
+ ORDER BY vertices.rowid
@@ -0,0 +1,4 @@
+SELECT DISTINCT attribute.key
+  FROM vertices,
+       json_each(CASE WHEN json_valid(vertices.attributes) THEN vertices.attributes ELSE '{}' END) attribute
+ WHERE (vertices.type=? AND attribute.key IS NOT NULL AND vertices.deleted_at IS NULL)
@@ -0,0 +1,5 @@
+SELECT outbox_consumers.position,
+       outbox_consumers.claim,
+       outbox_consumers.claimed_until
+  FROM outbox_consumers
+ WHERE (outbox_consumers.name=?)
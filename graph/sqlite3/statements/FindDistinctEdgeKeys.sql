@@ -2,4 +2,4 @@ SELECT DISTINCT key
   FROM edges
  INNER JOIN vertices
     ON (edges.from_rowid=vertices.rowid)
- WHERE (vertices.type=? AND vertices.id=?)
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NULL)
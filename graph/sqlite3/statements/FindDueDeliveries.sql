@@ -0,0 +1,15 @@
+SELECT deliveries.rowid,
+       deliveries.subscription_type,
+       deliveries.subscription_id,
+       deliveries.change_id,
+       deliveries.event,
+       deliveries.payload,
+       deliveries.state,
+       deliveries.attempts,
+       deliveries.next_attempt_at,
+       deliveries.created_at,
+       deliveries.updated_at
+  FROM deliveries
+ WHERE (deliveries.state='pending' AND deliveries.next_attempt_at<=?)
+ ORDER BY deliveries.next_attempt_at, deliveries.rowid
+ LIMIT ?
@@ -7,10 +7,13 @@ SELECT from_vertex.type,
        to_vertex.attributes,
        to_vertex.meta,
        edges.key,
-       edges.meta
+       edges.meta,
+       edges.position,
+       edges.created_at,
+       edges.updated_at
   FROM edges
  INNER JOIN vertices from_vertex
     ON (edges.from_rowid=from_vertex.rowid)
  INNER JOIN vertices to_vertex
     ON (edges.to_rowid=to_vertex.rowid)
- WHERE (from_vertex.type=? AND from_vertex.id=? AND edges.key=? AND to_vertex.type=? AND to_vertex.id=?)
+ WHERE (from_vertex.type=? AND from_vertex.id=? AND edges.key=? AND to_vertex.type=? AND to_vertex.id=? AND from_vertex.deleted_at IS NULL AND to_vertex.deleted_at IS NULL)
@@ -0,0 +1,3 @@
+SELECT cardinality
+  FROM edge_keys
+ WHERE (type=? AND key=?)
@@ -7,13 +7,14 @@ SELECT from_vertex.type,
        to_vertex.attributes,
        to_vertex.meta,
        edges.key,
-       edges.meta
+       edges.meta,
+       edges.created_at,
+       edges.updated_at
   FROM edges
  INNER JOIN vertices from_vertex
     ON (edges.from_rowid=from_vertex.rowid)
  INNER JOIN vertices to_vertex
     ON (edges.to_rowid=to_vertex.rowid)
- WHERE (from_vertex.type=$1 AND from_vertex.id=$2 AND edges.key=$3)
- ORDER BY edges.position ASC
- LIMIT $4
-OFFSET $5
+ WHERE (from_vertex.type=? AND from_vertex.id=?)
+    OR (to_vertex.type=? AND to_vertex.id=?)
+ ORDER BY edges.rowid
@@ -0,0 +1,2 @@
+SELECT COALESCE(MAX(history.rowid), 0)
+  FROM history
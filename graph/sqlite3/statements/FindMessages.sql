@@ -0,0 +1,14 @@
+SELECT outbox.rowid,
+       outbox.vertex_type,
+       outbox.vertex_id,
+       outbox.key,
+       outbox.operation,
+       outbox.actor,
+       outbox.request_id,
+       outbox.created_at,
+       outbox.before_state,
+       outbox.after_state
+  FROM outbox
+ WHERE (outbox.rowid>?)
+ ORDER BY outbox.rowid
+ LIMIT ?
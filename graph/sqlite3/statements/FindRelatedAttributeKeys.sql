@@ -0,0 +1,8 @@
+SELECT DISTINCT attribute.key
+  FROM edges
+ INNER JOIN vertices from_vertex
+    ON (edges.from_rowid=from_vertex.rowid)
+ INNER JOIN vertices to_vertex
+    ON (edges.to_rowid=to_vertex.rowid),
+       json_each(CASE WHEN json_valid(to_vertex.attributes) THEN to_vertex.attributes ELSE '{}' END) attribute
+ WHERE (from_vertex.type=? AND from_vertex.id=? AND edges.key=? AND attribute.key IS NOT NULL AND from_vertex.deleted_at IS NULL AND to_vertex.deleted_at IS NULL)
@@ -1,6 +1,9 @@
 SELECT vertices.type,
        vertices.id,
        vertices.attributes,
-       vertices.meta
+       vertices.meta,
+       vertices.revision,
+       vertices.created_at,
+       vertices.updated_at
   FROM vertices
- WHERE (vertices.type=? AND vertices.id=?)
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NULL)
@@ -0,0 +1,3 @@
+INSERT INTO deliveries(subscription_type, subscription_id, change_id, event, payload, state, attempts, next_attempt_at, created_at, updated_at)
+VALUES(?, ?, ?, ?, ?, ?, 0, ?, ?, ?)
+    ON CONFLICT(subscription_type, subscription_id, change_id) DO NOTHING
@@ -0,0 +1,2 @@
+INSERT INTO delivery_attempts(delivery_id, attempted_at, status_code, error)
+VALUES(?, ?, ?, ?)
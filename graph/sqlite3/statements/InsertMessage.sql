@@ -0,0 +1,2 @@
+INSERT INTO outbox(vertex_type, vertex_id, key, operation, actor, request_id, created_at, before_state, after_state)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
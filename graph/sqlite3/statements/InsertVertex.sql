@@ -1,2 +1,2 @@
-INSERT INTO vertices(type, id, attributes, meta)
-VALUES(?, ?, ?, ?)
+INSERT INTO vertices(type, id, attributes, meta, created_at, updated_at)
+VALUES(?, ?, ?, ?, ?, ?)
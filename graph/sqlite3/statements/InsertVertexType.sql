@@ -0,0 +1,2 @@
+INSERT OR IGNORE INTO vertex_types(type)
+VALUES(?)
@@ -0,0 +1,2 @@
+INSERT INTO vertices(type, id, attributes, meta, revision, created_at, updated_at, deleted_at)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?)
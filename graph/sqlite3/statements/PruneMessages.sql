@@ -0,0 +1,2 @@
+DELETE FROM outbox
+ WHERE outbox.rowid<=(SELECT MIN(outbox_consumers.position) FROM outbox_consumers)
@@ -0,0 +1,2 @@
+DELETE FROM outbox
+ WHERE (outbox.created_at<? AND NOT EXISTS (SELECT 1 FROM outbox_consumers))
@@ -0,0 +1,3 @@
+DELETE
+  FROM vertices
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NOT NULL)
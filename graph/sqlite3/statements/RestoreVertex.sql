@@ -0,0 +1,5 @@
+UPDATE vertices
+   SET deleted_at=NULL,
+       revision=vertices.revision+1,
+       updated_at=?
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NOT NULL)
@@ -0,0 +1,5 @@
+UPDATE vertices
+   SET deleted_at=?,
+       revision=vertices.revision+1
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NULL)
+   AND (?=0 OR vertices.revision=?)
@@ -0,0 +1,6 @@
+UPDATE deliveries
+   SET state=?,
+       attempts=?,
+       next_attempt_at=?,
+       updated_at=?
+ WHERE (deliveries.rowid=?)
@@ -0,0 +1,19 @@
+UPDATE edges
+   SET position=?,
+       meta=?,
+       updated_at=?
+ WHERE from_rowid IN (
+	SELECT rowid
+	FROM vertices
+	WHERE type=?
+	AND id=?
+	AND deleted_at IS NULL
+ )
+ AND to_rowid IN (
+	SELECT rowid
+	FROM vertices
+	WHERE type=?
+	AND id=?
+	AND deleted_at IS NULL
+ )
+ AND key=?
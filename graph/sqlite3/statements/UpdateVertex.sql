@@ -0,0 +1,7 @@
+UPDATE vertices
+   SET attributes=?,
+       meta=?,
+       revision=vertices.revision+1,
+       updated_at=?
+ WHERE (vertices.type=? AND vertices.id=? AND vertices.deleted_at IS NULL)
+   AND (?=0 OR vertices.revision=?)
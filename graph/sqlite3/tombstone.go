@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+func (tx *transaction) TombstoneVertex(vertexType, vertexID string, revision int64) error {
+
+	before, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Prepared["TombstoneVertex"].Exec(
+		now(),
+		vertexType,
+		vertexID,
+		revision,
+		revision,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrStaleRevision
+	}
+
+	return tx.recordVertex(graph.Tombstone, &before, nil)
+}
+
+func (tx *transaction) RestoreVertex(vertexType, vertexID string) error {
+
+	result, err := tx.Prepared["RestoreVertex"].Exec(
+		now(),
+		vertexType,
+		vertexID,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return graph.ErrNoRows
+	}
+
+	after, err := tx.FindVertex(vertexType, vertexID)
+	if err != nil {
+		return err
+	}
+
+	return tx.recordVertex(graph.Restore, nil, &after)
+}
+
+func (tx *transaction) PurgeVertices(tombstonedBefore time.Time) (int64, error) {
+
+	var count int64
+
+	rows, err := tx.Prepared["FindTombstones"].Query(
+		tombstonedBefore.UTC().Format(graph.TimeFormat),
+	)
+	if err != nil {
+		return count, err
+	}
+
+	var vertices []graph.Vertex
+
+	for rows.Next() {
+
+		var vertex graph.Vertex
+
+		err = rows.Scan(
+			&vertex.Type,
+			&vertex.Identifier,
+			&vertex.Attributes,
+			&vertex.Meta,
+			&vertex.Revision,
+			timestamp{&vertex.Created},
+			timestamp{&vertex.Updated},
+		)
+		if err != nil {
+			rows.Close()
+			return count, err
+		}
+
+		vertices = append(vertices, vertex)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return count, err
+	}
+
+	for i, vertex := range vertices {
+
+		// Edges from and to the vertex are deleted with it
+		edges, err := tx.findIncidentEdges(vertex.Type, vertex.Identifier)
+		if err != nil {
+			return count, err
+		}
+
+		_, err = tx.Prepared["PurgeVertex"].Exec(
+			vertex.Type,
+			vertex.Identifier,
+		)
+		if err != nil {
+			return count, err
+		}
+
+		for j := range edges {
+			err = tx.recordEdge(graph.Delete, &edges[j], nil)
+			if err != nil {
+				return count, err
+			}
+		}
+
+		err = tx.recordVertex(graph.Delete, &vertices[i], nil)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
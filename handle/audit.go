@@ -0,0 +1,28 @@
+package handle
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Audit is middleware attributing the changes made to the graph in serving a
+// request, as recorded in history, to the actor named by the ActorHeader of
+// the request, if any, and to the request ID set by middleware.RequestID.
+func (env *Environment) Audit(next http.Handler) http.Handler {
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+
+		var a graph.Audit
+
+		if env.ActorHeader != "" {
+			a.Actor = r.Header.Get(env.ActorHeader)
+		}
+		a.RequestID = middleware.GetReqID(r.Context())
+
+		next.ServeHTTP(w, r.WithContext(graph.WithAudit(r.Context(), a)))
+	}
+
+	return http.HandlerFunc(fn)
+}
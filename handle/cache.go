@@ -0,0 +1,93 @@
+package handle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-core"
+)
+
+// CachePolicy is the Cache-Control for successful responses to GET and HEAD,
+// keyed by route pattern (e.g., "/{type}/{id}/").  Routes not keyed take the
+// policy keyed by "*", and responses to routes of neither carry no
+// Cache-Control.
+type CachePolicy map[string]string
+
+// Returns the Cache-Control of the policy for the route of r.
+func (p CachePolicy) control(r *http.Request) string {
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if control, ok := p[rctx.RoutePattern()]; ok {
+			return control
+		}
+	}
+
+	return p["*"]
+}
+
+// Returns a weak entity tag for body, computed from its encoding.  The
+// timing in the meta object varies from response to response and is ignored,
+// so the tag is weak.
+func bodyETag(body *core.Document) (string, error) {
+
+	if took, ok := body.Meta["took"]; ok {
+		delete(body.Meta, "took")
+		defer func() { body.Meta["took"] = took }()
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Reports whether a response to r of header, with validators ETag and
+// Last-Modified, is not modified from the representation held by the client
+// (RFC 7232).  If-Modified-Since is evaluated only without If-None-Match.
+func notModified(r *http.Request, header http.Header) bool {
+
+	if values := r.Header.Values("If-None-Match"); len(values) > 0 {
+
+		etag := header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+
+		for _, value := range values {
+			for _, tag := range strings.Split(value, ",") {
+				tag = strings.TrimSpace(tag)
+				if tag == "*" || weakMatch(tag, etag) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// Reports whether entity tags a and b match by weak comparison.
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
@@ -0,0 +1,174 @@
+package handle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+// Maximum number of events found at each poll of the change feed, and the
+// interval between polls if not otherwise given.
+const (
+	eventBatch    = 100
+	eventInterval = time.Second
+)
+
+// Changes is a handler for requests to follow the change feed, as a stream
+// of Server-Sent Events, with possible method GET.  Events are those for
+// resources of any type, or of the types given by filter[type], following
+// the event with ID Last-Event-ID, if given, else following the last event.
+// The stream is polled at ChangesInterval and ends after ChangesTimeout, if
+// not zero, when clients reconnect, resuming from the last event received.
+func (env *Environment) HandleChanges(w http.ResponseWriter, r *http.Request) {
+
+	var response Response = NewResponse()
+
+	switch r.Method {
+
+	case "OPTIONS":
+
+		response.Header.Set("Allow", "OPTIONS, GET")
+		response.Header.Set("Access-Control-Allow-Methods", "OPTIONS, GET")
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
+		return
+
+	case "GET":
+
+		var types []string
+
+		for parameter, values := range r.URL.Query() {
+			if parameter != "filter[type]" {
+				e := core.MakeError(http.StatusBadRequest)
+				e.Code = "e07a3d"
+				e.Title = "Invalid query string"
+				e.Detail = fmt.Sprintf("Parameter not supported for changes: %s", parameter)
+				e.Source = &core.SourceObject{Parameter: parameter}
+				env.Fail(w, r, e)
+				return
+			}
+			for _, value := range values {
+				types = append(types, strings.Split(value, ",")...)
+			}
+		}
+
+		var id int64
+
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+			var err error
+			id, err = strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil || id < 0 {
+				e := core.MakeError(http.StatusBadRequest)
+				e.Code = "41d8c9"
+				e.Title = "Invalid header"
+				e.Detail = fmt.Sprintf("Last-Event-ID is not a sequence number: %s", lastEventID)
+				env.Fail(w, r, e)
+				return
+			}
+		} else {
+			var e *core.Error
+			id, e = model.GetLastEventID(r.Context(), env.Graph)
+			if e != nil {
+				env.Fail(w, r, e)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			e := core.MakeError(http.StatusInternalServerError)
+			e.Code = "9b3f60"
+			e.Title = "Encountered internal error while streaming response"
+			e.Detail = "Response writer does not support flushing"
+			env.Fail(w, r, e)
+			return
+		}
+
+		copyHeader(w.Header(), response.Header)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		env.streamEvents(w, r, flusher, types, id)
+		return
+
+	default:
+
+		// HTTP Method not allowed
+		e := core.MakeError(http.StatusMethodNotAllowed)
+		e.Code = "5c2e84"
+		env.Fail(w, r, e)
+		return
+
+	}
+}
+
+// Write the events following the event with ID id, as found at each poll,
+// until the request is done or the stream times out.
+func (env *Environment) streamEvents(w http.ResponseWriter, r *http.Request, flusher http.Flusher, types []string, id int64) {
+
+	var timeout <-chan time.Time
+
+	if env.ChangesTimeout > 0 {
+		timer := time.NewTimer(env.ChangesTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	interval := env.ChangesInterval
+	if interval <= 0 {
+		interval = eventInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		events, e := model.GetEvents(r.Context(), env.Graph, types, id, eventBatch)
+		if e != nil {
+			env.Stderr.Print(e.Detail)
+			return
+		}
+
+		for _, event := range events {
+
+			env.WriteOnly.redactDocument(event.Document)
+
+			data, err := json.Marshal(event.Document)
+			if err != nil {
+				env.Stderr.Print(err.Error())
+				return
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, data)
+			if err != nil {
+				return
+			}
+
+			id = event.ID
+		}
+
+		flusher.Flush()
+
+		// Poll again at once while events are backlogged
+		if len(events) == eventBatch {
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-server/config"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestHandleChanges(t *testing.T) {
+
+	var (
+		b   []byte
+		ctx *chi.Context
+		r   *http.Request
+		o   *http.Response
+		w   *httptest.ResponseRecorder
+	)
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		ChangesInterval: 10 * time.Millisecond,
+		ChangesTimeout:  50 * time.Millisecond,
+		Graph:           g,
+		Parameters:      config.Parameters,
+		Stderr:          log.New(devnull, "", 0),
+		Stdout:          log.New(devnull, "", 0),
+	}
+
+	// post resources, then patch the first
+	for _, resource := range []struct{ t, body string }{
+		{"foo", `{"data":{"type":"foo","id":"bar","attributes":{"a":"b"}}}`},
+		{"baz", `{"data":{"type":"baz","id":"qux","attributes":{"a":"b"}}}`},
+	} {
+		b = []byte(resource.body)
+		r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(b))
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx = chi.NewRouteContext()
+		ctx.URLParams.Add("type", resource.t)
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w = httptest.NewRecorder()
+		e.HandleCollection(w, r)
+		o = w.Result()
+		if o.StatusCode != http.StatusCreated {
+			t.Fatalf(
+				"o.StatusCode = %v, want %v",
+				o.StatusCode,
+				http.StatusCreated,
+			)
+		}
+	}
+
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":"c"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// request to change feed, returning the ids and names of its events
+	changes := func(target, lastEventID string) (*http.Response, []string) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", "text/event-stream")
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		e.HandleChanges(w, r)
+		o := w.Result()
+		body, _ := io.ReadAll(o.Body)
+		var events []string
+		for _, block := range strings.Split(string(body), "\n\n") {
+			var id, name, data string
+			for _, line := range strings.Split(block, "\n") {
+				switch {
+				case strings.HasPrefix(line, "id: "):
+					id = line[4:]
+				case strings.HasPrefix(line, "event: "):
+					name = line[7:]
+				case strings.HasPrefix(line, "data: "):
+					data = line[6:]
+				}
+			}
+			if id == "" {
+				continue
+			}
+			if !json.Valid([]byte(data)) {
+				t.Errorf("Got data %s, want JSON", data)
+			}
+			events = append(events, id+" "+name)
+		}
+		return o, events
+	}
+
+	/////////////////////////////////////// TESTS
+
+	tests := []struct {
+		target      string
+		lastEventID string
+		status      int
+		want        string
+	}{
+		{"/_changes", "0", http.StatusOK, "1 create,2 create,3 update"},
+		{"/_changes", "1", http.StatusOK, "2 create,3 update"},
+		{"/_changes?filter[type]=foo", "0", http.StatusOK, "1 create,3 update"},
+		{"/_changes?filter[type]=baz,qux", "0", http.StatusOK, "2 create"},
+		{"/_changes", "", http.StatusOK, ""},
+		{"/_changes", "thud", http.StatusBadRequest, ""},
+		{"/_changes?sort=id", "0", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		o, events := changes(test.target, test.lastEventID)
+		if o.StatusCode != test.status {
+			t.Errorf(
+				"o.StatusCode = %v, want %v",
+				o.StatusCode,
+				test.status,
+			)
+		} else if test.status == http.StatusOK && o.Header.Get("Content-Type") != "text/event-stream" {
+			t.Errorf("Got %s, want text/event-stream", o.Header.Get("Content-Type"))
+		}
+		if got := strings.Join(events, ","); got != test.want {
+			t.Errorf("%s (Last-Event-ID %s): got %s, want %s", test.target, test.lastEventID, got, test.want)
+		}
+	}
+
+	// add a member to a relationship, then remove it
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		b = []byte(`{"data":[{"type":"baz","id":"qux"}]}`)
+		r = httptest.NewRequest(method, "/foo/bar/relationships/quux", bytes.NewBuffer(b))
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx = chi.NewRouteContext()
+		ctx.URLParams.Add("type", "foo")
+		ctx.URLParams.Add("id", "bar")
+		ctx.URLParams.Add("relationship", "quux")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w = httptest.NewRecorder()
+		e.HandleRelationship(w, r)
+		o = w.Result()
+		if o.StatusCode != http.StatusNoContent {
+			t.Fatalf(
+				"%s: o.StatusCode = %v, want %v",
+				method,
+				o.StatusCode,
+				http.StatusNoContent,
+			)
+		}
+	}
+
+	if _, events := changes("/_changes", "3"); strings.Join(events, ",") != "4 add,5 remove" {
+		t.Errorf("Got %s, want 4 add,5 remove", strings.Join(events, ","))
+	}
+
+	// Unsupported method
+	r = httptest.NewRequest(http.MethodPost, "/_changes", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	w = httptest.NewRecorder()
+	e.HandleChanges(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusMethodNotAllowed,
+		)
+	}
+}
@@ -24,6 +24,12 @@ func (env *Environment) HandleCollection(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	e = env.WriteOnly.refuse(t, q)
+	if e != nil {
+		env.Fail(w, r, e)
+		return
+	}
+
 	switch r.Method {
 
 	case "OPTIONS":
@@ -63,25 +69,26 @@ func (env *Environment) HandleCollection(w http.ResponseWriter, r *http.Request)
 		}
 
 		// Parse request body
-		document, e := model.Decode(r.Body)
-		if e != nil {
-			env.Fail(w, r, e)
+		document, es := model.Decode(r.Body)
+		if es != nil {
+			env.Fail(w, r, es...)
 			return
 		}
 
 		// Post new resource
-		i, e := model.PostResource(r.Context(), env.Graph, t, document)
-		if e != nil {
-			env.Fail(w, r, e)
+		i, es := model.PostResource(r.Context(), env.Graph, env.Schemas, t, document)
+		if es != nil {
+			env.Fail(w, r, es...)
 			return
 		}
 
 		// Get the resource
-		document, e = model.GetResource(r.Context(), env.Graph, i.Type, i.Identifier, env.BaseURL, q)
+		document, v, e := model.GetResource(r.Context(), env.Graph, i.Type, i.Identifier, env.BaseURL, q)
 		if e != nil {
 			env.Fail(w, r, e)
 			return
 		}
+		response.Header.Set("ETag", v.ETag)
 
 		// Build link for the new resource
 		ref, err := url.Parse(
@@ -3,16 +3,19 @@ package handle
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/wamuir/go-jsonapi-server/config"
-	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+	"github.com/wamuir/go-jsonapi-server/model"
 )
 
 func TestHandleCollection(t *testing.T) {
@@ -28,11 +31,7 @@ func TestHandleCollection(t *testing.T) {
 	/////////////////////////////////////// SETUP
 
 	// graph
-	g, err := sqlite3.Connect("file::memory:?cache=shared")
-	if err != nil {
-		t.Fatal(err)
-		return
-	}
+	g := memory.New()
 	defer g.Close()
 
 	// open /dev/null for logging to nowhere
@@ -45,10 +44,11 @@ func TestHandleCollection(t *testing.T) {
 
 	// set up environment
 	e := &Environment{
-		Graph:      g,
-		Parameters: config.Parameters,
-		Stderr:     log.New(devnull, "", 0),
-		Stdout:     log.New(devnull, "", 0),
+		CachePolicy: config.CachePolicy,
+		Graph:       g,
+		Parameters:  config.Parameters,
+		Stderr:      log.New(devnull, "", 0),
+		Stdout:      log.New(devnull, "", 0),
 	}
 
 	// define a resource to be posted
@@ -158,6 +158,129 @@ func TestHandleCollection(t *testing.T) {
 			http.StatusOK,
 		)
 	}
+	if got := o.Header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control = %s, want %s", got, "no-cache")
+	}
+	etag := o.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("ETag = %s, want weak entity tag", etag)
+	}
+
+	// GET unmodified collection
+	r = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-None-Match", `"x", `+etag)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotModified {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotModified,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); len(b) != 0 {
+		t.Errorf("unexpected body for 304: %s", b)
+	}
+	if got := o.Header.Get("ETag"); got != etag {
+		t.Errorf("ETag = %s, want %s", got, etag)
+	}
+
+	// GET collection with stale entity tag
+	r = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-None-Match", `W/"x"`)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// GET filtered collection
+	r = httptest.NewRequest(http.MethodGet, "/foo/?filter[a]=b&page[limit]=1", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"bar"`)) || !bytes.Contains(b, []byte(`filter%5Ba%5D=b`)) {
+		t.Errorf("unexpected filtered collection: %s", b)
+	}
+
+	// GET collection with unsupported filter operator
+	r = httptest.NewRequest(http.MethodGet, "/foo/?filter[a][thud]=b", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// GET sorted collection
+	r = httptest.NewRequest(http.MethodGet, "/foo/?sort=-a,id", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// GET collection with unsupported sort field
+	r = httptest.NewRequest(http.MethodGet, "/foo/?sort=thud", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"parameter": "sort"`)) {
+		t.Errorf("missing source parameter: %s", b)
+	}
 
 	// HEAD non-existent collection
 	r = httptest.NewRequest(http.MethodHead, "/baz/", nil)
@@ -230,6 +353,50 @@ func TestHandleCollection(t *testing.T) {
 		)
 	}
 
+	// POST malformed JSON, reporting offset
+	b = []byte(`{"data":{"type":"foo",}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`byte offset 23`)) {
+		t.Errorf("missing offset of syntax error: %s", b)
+	}
+
+	// POST document failing to validate against JSON:API schema
+	b = []byte(`{"data":{"type":"foo","id":"baz","links":{"self":5}}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"pointer": "/data/links/self"`)) || !bytes.Contains(b, []byte(`"detail": "Invalid type. Expected: string, given: integer"`)) {
+		t.Errorf("missing error object for violation: %s", b)
+	}
+
 	// POST conflict
 	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":"b"},"meta":{"c":"d"}}}`)
 	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
@@ -268,6 +435,118 @@ func TestHandleCollection(t *testing.T) {
 		)
 	}
 
+	// Paginate by cursor, rather than by offset
+	e.Parameters = cursorParameters()
+
+	// GET first page of collection, by cursor
+	r = httptest.NewRequest(http.MethodGet, "/foo/?page[size]=1", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	var page struct {
+		Data  []struct{ ID string }
+		Links map[string]string
+	}
+	if err := json.NewDecoder(o.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != "bar" || page.Links["next"] == "" || page.Links["prev"] != "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	// GET next page of collection, by cursor
+	r = httptest.NewRequest(http.MethodGet, page.Links["next"], nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	page.Data, page.Links = nil, nil
+	if err := json.NewDecoder(o.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != "baz" || page.Links["next"] != "" || page.Links["prev"] == "" {
+		t.Fatalf("unexpected next page: %+v", page)
+	}
+
+	// GET previous page of collection, by cursor
+	r = httptest.NewRequest(http.MethodGet, page.Links["prev"], nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	page.Data, page.Links = nil, nil
+	if err := json.NewDecoder(o.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != "bar" || page.Links["next"] == "" || page.Links["prev"] != "" {
+		t.Fatalf("unexpected previous page: %+v", page)
+	}
+
+	// GET collection with invalid cursor
+	r = httptest.NewRequest(http.MethodGet, "/foo/?page[after]=thud", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// GET collection with both page[after] and page[before]
+	r = httptest.NewRequest(http.MethodGet, "/foo/?page[after]=a&page[before]=b", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
 	// Unsupported method
 	r = httptest.NewRequest(http.MethodConnect, "/foo/", nil)
 	r.Header.Set("Accept", "application/vnd.api+json")
@@ -286,3 +565,26 @@ func TestHandleCollection(t *testing.T) {
 	}
 
 }
+
+// Returns config.Parameters, but for pagination by cursor rather than by
+// offset.
+func cursorParameters() model.Parameters {
+
+	params := make(model.Parameters, len(config.Parameters))
+	for par, parameter := range config.Parameters {
+		params[par] = parameter
+	}
+
+	for _, par := range []string{"page[size]", "page[after]", "page[before]"} {
+		parameter := params[par]
+		parameter.Allowed = true
+		params[par] = parameter
+	}
+	for _, par := range []string{"page[limit]", "page[offset]"} {
+		parameter := params[par]
+		parameter.Allowed = false
+		params[par] = parameter
+	}
+
+	return params
+}
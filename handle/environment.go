@@ -3,6 +3,7 @@ package handle
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"mime"
 	"net/http"
@@ -19,20 +20,31 @@ import (
 )
 
 type Environment struct {
-	BaseURL    url.URL
-	Graph      graph.Graph
-	Parameters model.Parameters
-	Stderr     *log.Logger
-	Stdout     *log.Logger
+	ActorHeader     string
+	BaseURL         url.URL
+	CachePolicy     CachePolicy
+	ChangesInterval time.Duration
+	ChangesTimeout  time.Duration
+	Graph           graph.Graph
+	Parameters      model.Parameters
+	Registry        *Registry
+	Schemas         *schema.Resources
+	SoftDelete      bool
+	Stderr          *log.Logger
+	Stdout          *log.Logger
+	WriteOnly       WriteOnly
 }
 
-// Response is the header, body and status for a response.
+// Response is the header, body and status for a response.  LastModified,
+// if not zero, is given as the Last-Modified validator of a response to GET
+// or HEAD.
 type Response struct {
-	Created time.Time
-	Header  http.Header
-	Body    *core.Document
-	Status  int
-	Trailer http.Header
+	Created      time.Time
+	Header       http.Header
+	Body         *core.Document
+	LastModified time.Time
+	Status       int
+	Trailer      http.Header
 }
 
 // NewResponse is a Response constructor.
@@ -54,7 +66,8 @@ func copyHeader(dst, src http.Header) {
 	}
 }
 
-// Validates Content-Type header per JSON:API spec
+// Validates Content-Type header per JSON:API spec.  Extensions named in the
+// ext parameter are checked for support by Environment.Negotiate.
 func ValidateMIME(contentType string) *core.Error {
 
 	//contentType := r.Header.Get("Content-Type")
@@ -63,7 +76,7 @@ func ValidateMIME(contentType string) *core.Error {
 		e := core.MakeError(http.StatusUnsupportedMediaType)
 		e.Code = "d24289"
 		e.Title = "Missing media type"
-		e.Detail = "Clients MUST send all JSON:API data in request documents with the header `Content-Type: application/vnd.api+json` without any media type parameters"
+		e.Detail = "Clients MUST send all JSON:API data in request documents with the header `Content-Type: application/vnd.api+json` without any media type parameters other than ext and profile"
 		return e
 	}
 
@@ -74,25 +87,78 @@ func ValidateMIME(contentType string) *core.Error {
 		e.Title = "Encountered internal error while parsing media type"
 		e.Detail = err.Error()
 		return e
-	} else if mediatype != "application/vnd.api+json" || len(params) != 0 {
+	}
+
+	delete(params, "ext")
+	delete(params, "profile")
+
+	if mediatype != "application/vnd.api+json" || len(params) != 0 {
 		e := core.MakeError(http.StatusUnsupportedMediaType)
 		e.Code = "d24289"
 		e.Title = "Invalid media type"
-		e.Detail = "Clients MUST send all JSON:API data in request documents with the header `Content-Type: application/vnd.api+json` without any media type parameters"
+		e.Detail = "Clients MUST send all JSON:API data in request documents with the header `Content-Type: application/vnd.api+json` without any media type parameters other than ext and profile"
+		return e
+	}
+
+	return nil
+}
+
+// Validates Content-Type header per JSON:API spec for a request document of
+// the extension with URI ext, which must be among those of the ext media
+// type parameter.
+func ValidateExtensionMIME(contentType, ext string) *core.Error {
+
+	if len(strings.TrimSpace(contentType)) == 0 {
+		e := core.MakeError(http.StatusUnsupportedMediaType)
+		e.Code = "7e2b51"
+		e.Title = "Missing media type"
+		e.Detail = fmt.Sprintf("Clients MUST send request documents with the header `Content-Type: application/vnd.api+json; ext=\"%s\"`", ext)
+		return e
+	}
+
+	mediatype, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		e := core.MakeError(http.StatusInternalServerError)
+		e.Code = "c50f3d"
+		e.Title = "Encountered internal error while parsing media type"
+		e.Detail = err.Error()
+		return e
+	}
+
+	exts := strings.Fields(params["ext"])
+	delete(params, "ext")
+
+	if mediatype != "application/vnd.api+json" || len(params) != 0 || !stringInSlice(ext, exts) {
+		e := core.MakeError(http.StatusUnsupportedMediaType)
+		e.Code = "7b7b80"
+		e.Title = "Invalid media type"
+		e.Detail = fmt.Sprintf("Clients MUST send request documents with the header `Content-Type: application/vnd.api+json; ext=\"%s\"`", ext)
 		return e
 	}
 
 	return nil
 }
 
-// Fail completes an errored response.
-func (env *Environment) Fail(w http.ResponseWriter, r *http.Request, e *core.Error) {
+func stringInSlice(a string, b []string) bool {
+	for _, c := range b {
+		if c == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Fail completes an errored response, with an error object for each of es.
+// The status is that common to es or else the most general applicable.
+func (env *Environment) Fail(w http.ResponseWriter, r *http.Request, es ...*core.Error) {
 
 	//var document model.Document
 	var document core.Document = core.New()
-	document.Errors = append(document.Errors, *e)
+	for _, e := range es {
+		document.Errors = append(document.Errors, *e)
+	}
 
-	status, err := strconv.Atoi(e.Status)
+	status, err := failureStatus(es)
 	if err != nil {
 		status = http.StatusInternalServerError
 		e := core.MakeError(http.StatusInternalServerError)
@@ -154,9 +220,40 @@ func (env *Environment) Fail(w http.ResponseWriter, r *http.Request, e *core.Err
 	return
 }
 
+// Returns the status for a response with errors es: that of each, if the
+// same, or else 400 for client errors and 500 for any server error.
+func failureStatus(es []*core.Error) (int, error) {
+
+	var status int
+
+	for _, e := range es {
+		n, err := strconv.Atoi(e.Status)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case status == 0 || status == n:
+			status = n
+		case n >= 500 || status >= 500:
+			status = http.StatusInternalServerError
+		default:
+			status = http.StatusBadRequest
+		}
+	}
+
+	if status == 0 {
+		return http.StatusInternalServerError, nil
+	}
+
+	return status, nil
+}
+
 // Success completes an unerrored response.
 func (env *Environment) Success(w http.ResponseWriter, r *http.Request, response Response) {
 
+	media := MediaFromContext(r.Context())
+
 	// Validate response body against JSON:API schema
 	if response.Body != nil {
 
@@ -169,6 +266,16 @@ func (env *Environment) Success(w http.ResponseWriter, r *http.Request, response
 		}
 		response.Body.Meta["took"] = time.Now().Sub(response.Created).Milliseconds()
 
+		// Omit write-only attributes
+		env.WriteOnly.redactDocument(response.Body)
+
+		// Apply profiles negotiated for the response
+		for _, uri := range media.Profiles {
+			if profile := env.Registry.Profiles[uri]; profile.Apply != nil {
+				profile.Apply(r, response.Body)
+			}
+		}
+
 		// Validate
 		result, err := schema.Validate(response.Body)
 		if err != nil {
@@ -187,6 +294,41 @@ func (env *Environment) Success(w http.ResponseWriter, r *http.Request, response
 			env.Fail(w, r, errObj)
 			return
 		}
+	}
+
+	// Set cache policy and validators, and answer a conditional request
+	// for an unmodified representation without the body
+	if (r.Method == "GET" || r.Method == "HEAD") && response.Status == http.StatusOK {
+
+		if control := env.CachePolicy.control(r); control != "" {
+			response.Header.Set("Cache-Control", control)
+		}
+
+		if !response.LastModified.IsZero() {
+			response.Header.Set("Last-Modified", response.LastModified.UTC().Format(http.TimeFormat))
+		}
+
+		if response.Header.Get("ETag") == "" && response.Body != nil {
+			etag, err := bodyETag(response.Body)
+			if err != nil {
+				errObj := core.MakeError(http.StatusInternalServerError)
+				errObj.Code = "5e0b7c"
+				errObj.Title = "Encountered internal error while computing entity tag"
+				errObj.Detail = err.Error()
+				env.Fail(w, r, errObj)
+				return
+			}
+			response.Header.Set("ETag", etag)
+		}
+
+		if notModified(r, response.Header) {
+			copyHeader(w.Header(), response.Header)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if response.Body != nil {
 
 		// Set Headers as appropriate given HTTP Method
 		if r.Method == "HEAD" {
@@ -194,7 +336,7 @@ func (env *Environment) Success(w http.ResponseWriter, r *http.Request, response
 			response.Header.Set("Content-Length", strconv.Itoa(length))
 		} else {
 			response.Header.Set("X-Content-Type-Options", "nosniff")
-			response.Header.Set("Content-Type", "application/vnd.api+json")
+			response.Header.Set("Content-Type", media.String())
 		}
 	}
 
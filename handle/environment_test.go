@@ -3,8 +3,12 @@ package handle
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
 )
 
 func TestCopyHeader(t *testing.T) {
@@ -48,4 +52,77 @@ func TestValidateMIME(t *testing.T) {
 		t.Fatalf("Unexpected %v", err)
 	}
 
+	if err := ValidateMIME("application/vnd.api+json; charset=utf-8"); err == nil {
+		t.Fatal("Unexpected nil error")
+	} else if err.Status != fmt.Sprintf("%d", http.StatusUnsupportedMediaType) {
+		t.Fatalf("Unexpected %v", err.Status)
+	}
+
+	if err := ValidateMIME(`application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"; profile="https://example.com/profile"`); err != nil {
+		t.Fatalf("Unexpected %v", err)
+	}
+
+}
+
+func TestFailureStatus(t *testing.T) {
+
+	for _, test := range []struct {
+		statuses []int
+		want     int
+	}{
+		{[]int{http.StatusUnprocessableEntity}, http.StatusUnprocessableEntity},
+		{[]int{http.StatusUnprocessableEntity, http.StatusUnprocessableEntity}, http.StatusUnprocessableEntity},
+		{[]int{http.StatusUnprocessableEntity, http.StatusNotFound}, http.StatusBadRequest},
+		{[]int{http.StatusNotFound, http.StatusBadGateway}, http.StatusInternalServerError},
+	} {
+		var es []*core.Error
+		for _, status := range test.statuses {
+			es = append(es, core.MakeError(status))
+		}
+
+		if got, err := failureStatus(es); err != nil {
+			t.Fatal(err)
+		} else if got != test.want {
+			t.Errorf("failureStatus(%v) = %d, want %d", test.statuses, got, test.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+
+	modified := time.Date(2021, 7, 13, 18, 51, 45, 0, time.UTC)
+
+	header := make(http.Header)
+	header.Set("ETag", `"2"`)
+	header.Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	for _, test := range []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"If-None-Match", `"2"`, true},
+		{"If-None-Match", `W/"2"`, true},
+		{"If-None-Match", `"1", "2"`, true},
+		{"If-None-Match", `*`, true},
+		{"If-None-Match", `"1"`, false},
+		{"If-Modified-Since", modified.Format(http.TimeFormat), true},
+		{"If-Modified-Since", modified.Add(time.Hour).Format(http.TimeFormat), true},
+		{"If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat), false},
+		{"If-Modified-Since", "yesterday", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(test.name, test.value)
+		if got := notModified(r, header); got != test.want {
+			t.Errorf("%s: %s, notModified = %v, want %v", test.name, test.value, got, test.want)
+		}
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", `"1"`)
+	r.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	if notModified(r, header) {
+		t.Error("notModified = true, want false")
+	}
 }
@@ -0,0 +1,65 @@
+package handle
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+// History is a handler for requests corresponding to the history of a
+// resource (of type t string and identifier i string), with possible
+// methods GET and HEAD.  Its route is under "-", which is not a member
+// name, so as not to shadow resources related by a relationship.
+func (env *Environment) HandleHistory(w http.ResponseWriter, r *http.Request) {
+
+	var response Response = NewResponse()
+
+	q, e := model.ParseQueryString(r.URL, env.Parameters)
+	if e != nil {
+		env.Fail(w, r, e)
+		return
+	}
+
+	t := chi.URLParam(r, "type")
+	i := chi.URLParam(r, "id")
+
+	switch r.Method {
+
+	case "OPTIONS":
+
+		_, e := model.GetHistory(r.Context(), env.Graph, t, i, env.BaseURL, q)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+		response.Header.Set("Allow", "OPTIONS, GET, HEAD")
+		response.Header.Set("Access-Control-Allow-Methods", "OPTIONS, GET, HEAD")
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
+		return
+
+	case "GET", "HEAD":
+
+		document, e := model.GetHistory(r.Context(), env.Graph, t, i, env.BaseURL, q)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+		env.WriteOnly.redactHistory(t, document)
+		response.Body = document
+		response.Status = http.StatusOK
+		env.Success(w, r, response)
+		return
+
+	default:
+
+		// HTTP Method not allowed
+		e := core.MakeError(http.StatusMethodNotAllowed)
+		e.Code = "f1d6a3"
+		env.Fail(w, r, e)
+		return
+
+	}
+}
@@ -0,0 +1,204 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wamuir/go-jsonapi-server/config"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestHandleHistory(t *testing.T) {
+
+	var (
+		b   []byte
+		ctx *chi.Context
+		r   *http.Request
+		o   *http.Response
+		w   *httptest.ResponseRecorder
+	)
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		ActorHeader: "X-Forwarded-User",
+		Graph:       g,
+		Parameters:  config.Parameters,
+		Stderr:      log.New(devnull, "", 0),
+		Stdout:      log.New(devnull, "", 0),
+	}
+
+	// attribute changes, as would the router
+	h := middleware.RequestID(e.Audit(http.HandlerFunc(e.HandleResource)))
+
+	// post resource
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":"b"}}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusCreated {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusCreated,
+		)
+	}
+
+	// patch resource, by an actor
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":"c"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("X-Forwarded-User", "alice")
+	r.Header.Set("X-Request-Id", "thud")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// GET history of non-existent resource
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/-/history", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleHistory(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+
+	// GET sorted history
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar/-/history?sort=time", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleHistory(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// GET history
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar/-/history", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleHistory(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(o.Body)
+		t.Fatalf(
+			"o.StatusCode = %v, want %v: %s",
+			o.StatusCode,
+			http.StatusOK,
+			b,
+		)
+	}
+
+	var document struct {
+		Data []struct {
+			Type       string                 `json:"type"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+		Links struct {
+			Self string `json:"self"`
+		} `json:"links"`
+	}
+	if err := json.NewDecoder(o.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	} else if len(document.Data) != 2 {
+		t.Fatalf("Got %d changes, want 2", len(document.Data))
+	}
+	if !strings.HasPrefix(document.Links.Self, "/foo/bar/-/history?") {
+		t.Errorf("Got self link %s, want history under /-/", document.Links.Self)
+	}
+
+	inserted, updated := document.Data[0].Attributes, document.Data[1].Attributes
+	if inserted["operation"] != "insert" || inserted["before"] != nil || inserted["actor"] != nil {
+		t.Errorf("Got %v, want unattributed insert", inserted)
+	}
+	if updated["operation"] != "update" || updated["actor"] != "alice" || updated["requestId"] != "thud" {
+		t.Errorf("Got %v, want update by alice in request thud", updated)
+	}
+	if after, ok := updated["after"].(map[string]interface{}); !ok {
+		t.Errorf("Got %v, want state after update", updated["after"])
+	} else if attributes, _ := after["attributes"].(map[string]interface{}); attributes["a"] != "c" {
+		t.Errorf("Got %v, want attributes after update", after)
+	}
+
+	// Unsupported method
+	r = httptest.NewRequest(http.MethodPost, "/foo/bar/-/history", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleHistory(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusMethodNotAllowed,
+		)
+	}
+}
@@ -0,0 +1,225 @@
+package handle
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/wamuir/go-jsonapi-core"
+)
+
+// Extension is a JSON:API extension supported by the server, identified by
+// its URI.
+type Extension struct {
+	URI string
+}
+
+// Profile is a JSON:API profile supported by the server, identified by its
+// URI.  Apply, if not nil, is called on each response document to which the
+// profile is applied, being those requested with the profile.
+type Profile struct {
+	URI   string
+	Apply func(r *http.Request, d *core.Document)
+}
+
+// Registry holds the extensions and profiles supported by the server, keyed
+// on URI.  A nil *Registry supports none.
+type Registry struct {
+	Extensions map[string]Extension
+	Profiles   map[string]Profile
+}
+
+// NewRegistry is a Registry constructor.
+func NewRegistry() *Registry {
+	return &Registry{
+		Extensions: make(map[string]Extension),
+		Profiles:   make(map[string]Profile),
+	}
+}
+
+// RegisterExtension adds ext to the extensions supported.
+func (reg *Registry) RegisterExtension(ext Extension) {
+	reg.Extensions[ext.URI] = ext
+}
+
+// RegisterProfile adds profile to the profiles supported.
+func (reg *Registry) RegisterProfile(profile Profile) {
+	reg.Profiles[profile.URI] = profile
+}
+
+// SupportsExtension reports whether the extension with URI uri is supported.
+func (reg *Registry) SupportsExtension(uri string) bool {
+	if reg == nil {
+		return false
+	}
+	_, ok := reg.Extensions[uri]
+	return ok
+}
+
+// SupportsProfile reports whether the profile with URI uri is supported.
+func (reg *Registry) SupportsProfile(uri string) bool {
+	if reg == nil {
+		return false
+	}
+	_, ok := reg.Profiles[uri]
+	return ok
+}
+
+// Media is the JSON:API media type negotiated for a response: the
+// extensions and profiles, by URI, to apply.
+type Media struct {
+	Extensions []string
+	Profiles   []string
+}
+
+// String returns the value of the Content-Type header for m.
+func (m Media) String() string {
+
+	var b strings.Builder
+	b.WriteString("application/vnd.api+json")
+	if len(m.Extensions) > 0 {
+		fmt.Fprintf(&b, "; ext=%q", strings.Join(m.Extensions, " "))
+	}
+	if len(m.Profiles) > 0 {
+		fmt.Fprintf(&b, "; profile=%q", strings.Join(m.Profiles, " "))
+	}
+
+	return b.String()
+}
+
+type contextKey string
+
+const mediaKey contextKey = "media"
+
+// MediaFromContext returns the media type negotiated for the request with
+// ctx, being plain JSON:API if none was.
+func MediaFromContext(ctx context.Context) Media {
+	m, _ := ctx.Value(mediaKey).(Media)
+	return m
+}
+
+// Negotiate is middleware for JSON:API content negotiation.  Requests with
+// a JSON:API Content-Type having parameters other than ext and profile, or
+// any unsupported extension, fail with 415.  Requests accepting JSON:API
+// media types only with such parameters or extensions fail with 406.
+// Otherwise, the media type for the response, with the supported profiles
+// requested, is made available by MediaFromContext.
+func (env *Environment) Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			e := env.validateExtensions(contentType)
+			if e != nil {
+				env.Fail(w, r, e)
+				return
+			}
+		}
+
+		m, e := env.negotiateAccept(r.Header.Values("Accept"))
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), mediaKey, m)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Validates the extensions of the ext parameter of a JSON:API Content-Type,
+// which must each be supported.  Other media types are left to ValidateMIME.
+func (env *Environment) validateExtensions(contentType string) *core.Error {
+
+	mediatype, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediatype != "application/vnd.api+json" {
+		return nil
+	}
+
+	for par := range params {
+		if par != "ext" && par != "profile" {
+			e := core.MakeError(http.StatusUnsupportedMediaType)
+			e.Code = "4c0e7d"
+			e.Title = "Invalid media type"
+			e.Detail = fmt.Sprintf("Unsupported media type parameter: %s", par)
+			return e
+		}
+	}
+
+	for _, ext := range strings.Fields(params["ext"]) {
+		if !env.Registry.SupportsExtension(ext) {
+			e := core.MakeError(http.StatusUnsupportedMediaType)
+			e.Code = "a9f3b2"
+			e.Title = "Unsupported extension"
+			e.Detail = fmt.Sprintf("Unsupported extension: %s", ext)
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Returns the media type for a response to a request with Accept headers
+// accept, from the first JSON:API media type therein without parameters
+// other than ext and profile and without unsupported extensions.  If the
+// JSON:API media type is accepted only with such parameters or extensions,
+// fails with 406.
+func (env *Environment) negotiateAccept(accept []string) (Media, *core.Error) {
+
+	var (
+		m        Media
+		rejected bool
+	)
+
+	for _, header := range accept {
+		for _, entry := range strings.Split(header, ",") {
+
+			mediatype, params, err := mime.ParseMediaType(entry)
+			if err != nil || mediatype != "application/vnd.api+json" {
+				continue
+			}
+
+			if acceptable(env.Registry, params) {
+				for _, profile := range strings.Fields(params["profile"]) {
+					if env.Registry.SupportsProfile(profile) {
+						m.Profiles = append(m.Profiles, profile)
+					}
+				}
+				return m, nil
+			}
+
+			rejected = true
+		}
+	}
+
+	if rejected {
+		e := core.MakeError(http.StatusNotAcceptable)
+		e.Code = "5e81ad"
+		e.Title = "Not acceptable"
+		e.Detail = "Every instance of the JSON:API media type in the Accept header has unsupported media type parameters or extensions"
+		return m, e
+	}
+
+	return m, nil
+}
+
+// Reports whether a JSON:API media type with params is acceptable, having
+// no parameters but ext, profile and the accept-param q, and no unsupported
+// extensions.
+func acceptable(reg *Registry, params map[string]string) bool {
+
+	for par := range params {
+		if par != "ext" && par != "profile" && par != "q" {
+			return false
+		}
+	}
+
+	for _, ext := range strings.Fields(params["ext"]) {
+		if !reg.SupportsExtension(ext) {
+			return false
+		}
+	}
+
+	return true
+}
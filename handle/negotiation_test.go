@@ -0,0 +1,93 @@
+package handle
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/wamuir/go-jsonapi-core"
+)
+
+func TestNegotiate(t *testing.T) {
+
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	registry := NewRegistry()
+	registry.RegisterExtension(Extension{URI: "https://example.com/ext/foo"})
+	registry.RegisterProfile(Profile{
+		URI: "https://example.com/profile/bar",
+		Apply: func(r *http.Request, d *core.Document) {
+			d.Meta["bar"] = true
+		},
+	})
+
+	env := &Environment{
+		Registry: registry,
+		Stderr:   log.New(devnull, "", 0),
+		Stdout:   log.New(devnull, "", 0),
+	}
+
+	h := env.Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := NewResponse()
+		response.Body = &core.Document{Meta: map[string]interface{}{}}
+		response.Status = http.StatusOK
+		env.Success(w, r, response)
+	}))
+
+	tests := []struct {
+		accept      string
+		contentType string
+		status      int
+		want        string
+	}{
+		{"", "", http.StatusOK, "application/vnd.api+json"},
+		{"*/*", "", http.StatusOK, "application/vnd.api+json"},
+		{"application/vnd.api+json", "", http.StatusOK, "application/vnd.api+json"},
+		{"application/vnd.api+json; charset=utf-8", "", http.StatusNotAcceptable, ""},
+		{"application/vnd.api+json; charset=utf-8, application/vnd.api+json", "", http.StatusOK, "application/vnd.api+json"},
+		{`application/vnd.api+json; ext="https://example.com/ext/thud"`, "", http.StatusNotAcceptable, ""},
+		{`application/vnd.api+json; ext="https://example.com/ext/foo"`, "", http.StatusOK, "application/vnd.api+json"},
+		{`application/vnd.api+json; profile="https://example.com/profile/bar https://example.com/profile/thud"`, "", http.StatusOK, `application/vnd.api+json; profile="https://example.com/profile/bar"`},
+		{"", "application/vnd.api+json; charset=utf-8", http.StatusUnsupportedMediaType, ""},
+		{"", `application/vnd.api+json; ext="https://example.com/ext/thud"`, http.StatusUnsupportedMediaType, ""},
+		{"", `application/vnd.api+json; ext="https://example.com/ext/foo"; profile="https://example.com/profile/thud"`, http.StatusOK, "application/vnd.api+json"},
+	}
+
+	for _, test := range tests {
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		o := w.Result()
+
+		if o.StatusCode != test.status {
+			t.Errorf(
+				"Accept %q, Content-Type %q: o.StatusCode = %v, want %v",
+				test.accept,
+				test.contentType,
+				o.StatusCode,
+				test.status,
+			)
+		} else if got := o.Header.Get("Content-Type"); test.want != "" && got != test.want {
+			t.Errorf(
+				"Accept %q, Content-Type %q: Content-Type = %v, want %v",
+				test.accept,
+				test.contentType,
+				got,
+				test.want,
+			)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package handle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/model"
+	"github.com/wamuir/go-jsonapi-server/schema"
+)
+
+// Operations is a handler for requests of the Atomic Operations extension,
+// with possible methods OPTIONS and POST.  Operations are performed in
+// order, and all are committed to the graph or none are.
+func (env *Environment) HandleOperations(w http.ResponseWriter, r *http.Request) {
+
+	var response Response = NewResponse()
+
+	q, e := model.ParseQueryString(r.URL, env.Parameters)
+	if e != nil {
+		env.Fail(w, r, e)
+		return
+	}
+
+	switch r.Method {
+
+	case "OPTIONS":
+
+		response.Header.Set("Allow", "OPTIONS, POST")
+		response.Header.Set("Access-Control-Allow-Methods", "OPTIONS, POST")
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
+		return
+
+	case "POST":
+
+		// Validate content type
+		e := ValidateExtensionMIME(r.Header.Get("Content-Type"), model.AtomicExtension)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+
+		// Parse request body
+		operations, e := model.DecodeOperations(r.Body)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+
+		// Perform operations
+		results, es := model.PostOperations(r.Context(), env.Graph, env.Schemas, operations, env.BaseURL, q, env.SoftDelete)
+		if es != nil {
+			env.Fail(w, r, es...)
+			return
+		}
+
+		env.SucceedOperations(w, r, response, results)
+		return
+
+	default:
+
+		e := core.MakeError(http.StatusMethodNotAllowed)
+		e.Code = "b83e1f"
+		env.Fail(w, r, e)
+		return
+
+	}
+}
+
+// SucceedOperations completes an unerrored response of the Atomic
+// Operations extension, with no content unless any of results has data.
+func (env *Environment) SucceedOperations(w http.ResponseWriter, r *http.Request, response Response, results []model.Result) {
+
+	var hasData bool
+
+	// Validate the data of each result against JSON:API schema
+	for n, result := range results {
+
+		if result.Data == nil {
+			continue
+		}
+		hasData = true
+
+		env.WriteOnly.redactData(result.Data)
+
+		document := core.New()
+		document.Data = result.Data
+
+		v, err := schema.Validate(document)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "0d93c6"
+			errObj.Title = "Encountered internal error while validating response body against JSON:API schema"
+			errObj.Detail = err.Error()
+			env.Fail(w, r, errObj)
+			return
+
+		} else if !v.Valid() {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "a61b0e"
+			errObj.Title = "Response document failed to validate against JSON:API schema"
+			errObj.Detail = fmt.Sprintf("Result %d failed to validate", n)
+			env.Fail(w, r, errObj)
+			return
+		}
+	}
+
+	if !hasData {
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
+		return
+	}
+
+	document := core.New()
+	body := model.Operations{
+		JSONAPI: document.JSONAPI,
+		Results: results,
+		Meta: map[string]interface{}{
+			"took": time.Now().Sub(response.Created).Milliseconds(),
+		},
+	}
+
+	// Write body to buffer
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	encoder.Encode(body)
+
+	// Write header and body
+	response.Header.Set("X-Content-Type-Options", "nosniff")
+	media := MediaFromContext(r.Context())
+	media.Extensions = []string{model.AtomicExtension}
+	response.Header.Set("Content-Type", media.String())
+	copyHeader(w.Header(), response.Header)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+	return
+}
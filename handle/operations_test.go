@@ -0,0 +1,274 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-server/config"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestHandleOperations(t *testing.T) {
+
+	var (
+		b   []byte
+		ctx *chi.Context
+		r   *http.Request
+		o   *http.Response
+		w   *httptest.ResponseRecorder
+	)
+
+	const mediatype = `application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"`
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		Graph:      g,
+		Parameters: config.Parameters,
+		Stderr:     log.New(devnull, "", 0),
+		Stdout:     log.New(devnull, "", 0),
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// OPTIONS
+	r = httptest.NewRequest(http.MethodOptions, "/operations", nil)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// POST without extension media type
+	b = []byte(`{"atomic:operations":[{"op":"add","data":{"type":"ops","attributes":{"a":"b"}}}]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusUnsupportedMediaType,
+		)
+	}
+
+	// POST without operations
+	b = []byte(`{"atomic:operations":[]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", mediatype)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// POST add resources, referenced by lid, and add to relationship
+	b = []byte(`{"atomic:operations":[
+		{"op":"add","data":{"type":"ops","lid":"x","attributes":{"a":"b"}}},
+		{"op":"add","data":{"type":"ops","id":"y","relationships":{"parent":{"data":{"type":"ops","lid":"x"}}}}},
+		{"op":"add","ref":{"type":"ops","lid":"x","relationship":"children"},"data":[{"type":"ops","id":"y"}]},
+		{"op":"update","data":{"type":"ops","lid":"x","attributes":{"c":"d"}}}
+	]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", mediatype)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(o.Body)
+		t.Fatalf(
+			"o.StatusCode = %v, want %v: %s",
+			o.StatusCode,
+			http.StatusOK,
+			b,
+		)
+	}
+	if ct := o.Header.Get("Content-Type"); ct != mediatype {
+		t.Errorf("Content-Type = %s, want %s", ct, mediatype)
+	}
+	var results struct {
+		Results []struct {
+			Data *struct {
+				ID         string
+				Attributes map[string]interface{}
+			}
+		} `json:"atomic:results"`
+	}
+	if err := json.NewDecoder(o.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 4 {
+		t.Fatalf("len(results) = %d, want %d", len(results.Results), 4)
+	}
+	if results.Results[0].Data == nil || results.Results[2].Data != nil || results.Results[3].Data == nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	x := results.Results[0].Data.ID
+	if x == "" || results.Results[3].Data.ID != x || results.Results[3].Data.Attributes["c"] != "d" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// GET relationship added by lid
+	r = httptest.NewRequest(http.MethodGet, "/ops/y/relationships/parent", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "ops")
+	ctx.URLParams.Add("id", "y")
+	ctx.URLParams.Add("relationship", "parent")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"id": "`+x+`"`)) {
+		t.Errorf("unexpected relationship: %s", b)
+	}
+
+	// POST with failing operation, rolling back all
+	b = []byte(`{"atomic:operations":[
+		{"op":"add","data":{"type":"ops","id":"z"}},
+		{"op":"update","ref":{"type":"ops","id":"thud"},"data":{"type":"ops","id":"thud","attributes":{"a":"b"}}}
+	]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", mediatype)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"pointer": "/atomic:operations/1"`)) {
+		t.Errorf("missing source pointer: %s", b)
+	}
+
+	// GET resource added by rolled back operation
+	r = httptest.NewRequest(http.MethodGet, "/ops/z", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "ops")
+	ctx.URLParams.Add("id", "z")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+
+	// POST with unknown lid
+	b = []byte(`{"atomic:operations":[{"op":"remove","ref":{"type":"ops","lid":"thud"}}]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", mediatype)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// POST remove from relationship and remove resources, by href
+	b = []byte(`{"atomic:operations":[
+		{"op":"remove","ref":{"type":"ops","id":"` + x + `","relationship":"children"},"data":[{"type":"ops","id":"y"}]},
+		{"op":"remove","href":"/ops/y"},
+		{"op":"remove","ref":{"type":"ops","id":"` + x + `"}}
+	]}`)
+	r = httptest.NewRequest(http.MethodPost, "/operations", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", mediatype)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		b, _ := io.ReadAll(o.Body)
+		t.Errorf(
+			"o.StatusCode = %v, want %v: %s",
+			o.StatusCode,
+			http.StatusNoContent,
+			b,
+		)
+	}
+
+	// GET empty collection of known type
+	r = httptest.NewRequest(http.MethodGet, "/ops/", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "ops")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"data": []`)) {
+		t.Errorf("unexpected empty collection: %s", b)
+	}
+
+	// Unsupported method
+	r = httptest.NewRequest(http.MethodGet, "/operations", nil)
+	w = httptest.NewRecorder()
+	e.HandleOperations(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusMethodNotAllowed,
+		)
+	}
+
+}
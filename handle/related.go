@@ -22,6 +22,13 @@ func (env *Environment) HandleRelated(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Of any type, as the types of related resources are not known
+	e = env.WriteOnly.refuse("", q)
+	if e != nil {
+		env.Fail(w, r, e)
+		return
+	}
+
 	t := chi.URLParam(r, "type")
 	i := chi.URLParam(r, "id")
 	k := chi.URLParam(r, "related")
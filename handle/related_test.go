@@ -12,7 +12,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/wamuir/go-jsonapi-server/config"
-	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
 )
 
 func TestHandleRelated(t *testing.T) {
@@ -28,11 +28,7 @@ func TestHandleRelated(t *testing.T) {
 	/////////////////////////////////////// SETUP
 
 	// graph
-	g, err := sqlite3.Connect("file::memory:?cache=shared")
-	if err != nil {
-		t.Fatal(err)
-		return
-	}
+	g := memory.New()
 	defer g.Close()
 
 	// open /dev/null for logging to nowhere
@@ -77,7 +73,7 @@ func TestHandleRelated(t *testing.T) {
 	}
 
 	// define a second resource + relationship to be posted
-	b = []byte(`{"data":{"type":"foo","id":"baz","relationships":{"qux":{"data":{"type":"foo","id":"bar"}}}}}`)
+	b = []byte(`{"data":{"type":"foo","id":"baz","relationships":{"qux":{"data":[{"type":"foo","id":"bar"}]},"quuz":{"data":{"type":"foo","id":"bar"}}}}}`)
 
 	// create http request
 	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
@@ -192,6 +188,89 @@ func TestHandleRelated(t *testing.T) {
 		)
 	}
 
+	// GET sorted related collection
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/qux/?sort=-a,id", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("related", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelated(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// GET related resource of to-one relationship
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/quuz/", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("related", "quuz")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelated(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"data": {`)) {
+		t.Errorf("unexpected to-one related resource: %s", b)
+	}
+
+	// GET related collection with unsupported sort field
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/qux/?sort=thud", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("related", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelated(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// GET related collection with invalid cursor
+	e.Parameters = cursorParameters()
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/qux/?page[before]=thud", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("related", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelated(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"parameter": "page[before]"`)) {
+		t.Errorf("missing source parameter: %s", b)
+	}
+
 	// HEAD non-existent related collection
 	r = httptest.NewRequest(http.MethodHead, "/foo/baz/thud/", nil)
 	r.Header.Set("Accept", "application/vnd.api+json")
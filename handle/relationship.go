@@ -63,9 +63,9 @@ func (env *Environment) HandleRelationship(w http.ResponseWriter, r *http.Reques
 		}
 
 		// Parse request body
-		document, e := model.Decode(r.Body)
-		if e != nil {
-			env.Fail(w, r, e)
+		document, es := model.Decode(r.Body)
+		if es != nil {
+			env.Fail(w, r, es...)
 			return
 		}
 
@@ -83,10 +83,28 @@ func (env *Environment) HandleRelationship(w http.ResponseWriter, r *http.Reques
 
 	case "PATCH":
 
-		// Method not implemented
-		e := core.MakeError(http.StatusNotImplemented)
-		e.Code = "b4d563"
-		env.Fail(w, r, e)
+		// Validate content type
+		e := ValidateMIME(r.Header.Get("Content-Type"))
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+
+		// Parse request body
+		document, es := model.Decode(r.Body)
+		if es != nil {
+			env.Fail(w, r, es...)
+			return
+		}
+
+		// Replace members of relationship
+		e = model.PatchRelationship(r.Context(), env.Graph, t, i, k, document)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
 		return
 
 	case "DELETE":
@@ -99,9 +117,9 @@ func (env *Environment) HandleRelationship(w http.ResponseWriter, r *http.Reques
 		}
 
 		// Parse request body
-		document, e := model.Decode(r.Body)
-		if e != nil {
-			env.Fail(w, r, e)
+		document, es := model.Decode(r.Body)
+		if es != nil {
+			env.Fail(w, r, es...)
 			return
 		}
 
@@ -8,11 +8,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/wamuir/go-jsonapi-server/config"
-	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
 )
 
 func TestHandleRelationship(t *testing.T) {
@@ -28,11 +30,7 @@ func TestHandleRelationship(t *testing.T) {
 	/////////////////////////////////////// SETUP
 
 	// graph
-	g, err := sqlite3.Connect("file::memory:?cache=shared")
-	if err != nil {
-		t.Fatal(err)
-		return
-	}
+	g := memory.New()
 	defer g.Close()
 
 	// open /dev/null for logging to nowhere
@@ -338,6 +336,345 @@ func TestHandleRelationship(t *testing.T) {
 		)
 	}
 
+	// PATCH non-existent resource
+	b = []byte(`{"data":[]}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/thud/relationships/qux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "thud")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+
+	// POST to-one relationship, replacing member
+	b = []byte(`{"data":{"type":"foo","id":"xyzzy"}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/baz/relationships/qux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// GET to-one relationship
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/relationships/qux", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"id": "xyzzy"`)) || bytes.Contains(b, []byte(`"id": "bar"`)) {
+		t.Errorf("unexpected to-one relationship: %s", b)
+	}
+
+	// PATCH to-one relationship with array
+	b = []byte(`{"data":[{"type":"foo","id":"bar"}]}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/qux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// PATCH to-one relationship without data member
+	for _, body := range []string{`{}`, `{"meta":{}}`} {
+		r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/qux", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx = chi.NewRouteContext()
+		ctx.URLParams.Add("type", "foo")
+		ctx.URLParams.Add("id", "baz")
+		ctx.URLParams.Add("relationship", "qux")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w = httptest.NewRecorder()
+		e.HandleRelationship(w, r)
+		o = w.Result()
+		if o.StatusCode != http.StatusBadRequest {
+			t.Errorf(
+				"o.StatusCode = %v, want %v for %s",
+				o.StatusCode,
+				http.StatusBadRequest,
+				body,
+			)
+		}
+	}
+
+	// GET to-one relationship left by PATCH without data member
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/relationships/qux", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"id": "xyzzy"`)) {
+		t.Errorf("to-one relationship cleared without data member: %s", b)
+	}
+
+	// PATCH to-one relationship with null data
+	b = []byte(`{"data":null}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/qux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// GET empty to-one relationship
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/relationships/qux", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "qux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"data": null`)) {
+		t.Errorf("unexpected empty to-one relationship: %s", b)
+	}
+
+	// PATCH relationship with ordered members
+	b = []byte(`{"data":[{"type":"foo","id":"xyzzy"},{"type":"foo","id":"bar"}]}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/quux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// GET patched relationship, members in order
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/relationships/quux", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); bytes.Index(b, []byte(`"xyzzy"`)) > bytes.Index(b, []byte(`"bar"`)) {
+		t.Errorf("relationship members out of order: %s", b)
+	}
+
+	// PATCH relationship reordering its members, keeping their edges
+	tx, err := g.Transaction(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := tx.FindEdge("foo", "baz", "foo", "xyzzy", "quux")
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	b = []byte(`{"data":[{"type":"foo","id":"bar"},{"type":"foo","id":"xyzzy"}]}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/quux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	tx, err = g.Transaction(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := tx.FindEdge("foo", "baz", "foo", "xyzzy", "quux")
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Position != 1 || !after.Created.Equal(before.Created) || !after.Updated.After(before.Updated) {
+		t.Errorf("edge not updated in place: got %+v, was %+v", after, before)
+	}
+
+	// PATCH to-many relationship with single resource
+	b = []byte(`{"data":{"type":"foo","id":"bar"}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/quux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// PATCH to-many relationship with null data
+	b = []byte(`{"data":null}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/quux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
+	// PATCH relationship with empty data
+	b = []byte(`{"data":[]}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz/relationships/quux", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNoContent {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// GET empty relationship
+	r = httptest.NewRequest(http.MethodGet, "/foo/baz/relationships/quux", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "baz")
+	ctx.URLParams.Add("relationship", "quux")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"data": []`)) {
+		t.Errorf("unexpected empty relationship: %s", b)
+	}
+
 	// Unsupported method
 	r = httptest.NewRequest(http.MethodConnect, "/foo/baz", nil)
 	r.Header.Set("Accept", "application/vnd.api+json")
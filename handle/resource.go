@@ -28,7 +28,7 @@ func (env *Environment) HandleResource(w http.ResponseWriter, r *http.Request) {
 
 	case "OPTIONS":
 
-		_, e := model.GetResource(r.Context(), env.Graph, t, i, env.BaseURL, q)
+		_, _, e := model.GetResource(r.Context(), env.Graph, t, i, env.BaseURL, q)
 		if e != nil {
 			env.Fail(w, r, e)
 			return
@@ -41,11 +41,17 @@ func (env *Environment) HandleResource(w http.ResponseWriter, r *http.Request) {
 
 	case "GET", "HEAD":
 
-		document, e := model.GetResource(r.Context(), env.Graph, t, i, env.BaseURL, q)
+		document, v, e := model.GetResource(r.Context(), env.Graph, t, i, env.BaseURL, q)
 		if e != nil {
 			env.Fail(w, r, e)
 			return
 		}
+		// The validators do not cover included resources, so a document
+		// with any takes the entity tag of its encoding instead
+		if len(q.Include) == 0 {
+			response.Header.Set("ETag", v.ETag)
+			response.LastModified = v.LastModified
+		}
 		if document.Meta == nil {
 			document.Meta = make(map[string]interface{}, 1)
 		}
@@ -56,15 +62,38 @@ func (env *Environment) HandleResource(w http.ResponseWriter, r *http.Request) {
 
 	case "PATCH":
 
-		// Method not allowed
-		e := core.MakeError(http.StatusMethodNotAllowed)
-		e.Code = "b83e07"
-		env.Fail(w, r, e)
+		// Validate content type
+		e := ValidateMIME(r.Header.Get("Content-Type"))
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+
+		// Parse request body
+		document, es := model.Decode(r.Body)
+		if es != nil {
+			env.Fail(w, r, es...)
+			return
+		}
+
+		// Update the resource, if it matches If-Match
+		p := model.ParsePrecondition(r.Header.Values("If-Match"))
+		document, v, es := model.PatchResource(r.Context(), env.Graph, env.Schemas, t, i, p, document, env.BaseURL, q)
+		if es != nil {
+			env.Fail(w, r, es...)
+			return
+		}
+		response.Header.Set("ETag", v.ETag)
+		response.Body = document
+		response.Status = http.StatusOK
+		env.Success(w, r, response)
 		return
 
 	case "DELETE":
 
-		e := model.DeleteResource(r.Context(), env.Graph, t, i)
+		// Delete the resource, if it matches If-Match
+		p := model.ParsePrecondition(r.Header.Values("If-Match"))
+		e := model.DeleteResource(r.Context(), env.Graph, t, i, p, env.SoftDelete)
 		if e != nil {
 			env.Fail(w, r, e)
 			return
@@ -3,16 +3,20 @@ package handle
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/wamuir/go-jsonapi-server/config"
-	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+	"github.com/wamuir/go-jsonapi-server/schema"
 )
 
 func TestHandleResource(t *testing.T) {
@@ -28,11 +32,7 @@ func TestHandleResource(t *testing.T) {
 	/////////////////////////////////////// SETUP
 
 	// graph
-	g, err := sqlite3.Connect("file::memory:?cache=shared")
-	if err != nil {
-		t.Fatal(err)
-		return
-	}
+	g := memory.New()
 	defer g.Close()
 
 	// open /dev/null for logging to nowhere
@@ -163,6 +163,45 @@ func TestHandleResource(t *testing.T) {
 		)
 	}
 
+	// GET resource with sparse fieldset
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar?fields[foo]=", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); bytes.Contains(b, []byte(`"attributes"`)) {
+		t.Errorf("attributes not removed by sparse fieldset: %s", b)
+	}
+
+	// GET resource with sparse fieldset naming unknown field
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar?fields[foo]=a,thud", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusBadRequest {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusBadRequest,
+		)
+	}
+
 	// HEAD non-existent resource
 	r = httptest.NewRequest(http.MethodHead, "/foo/baz", nil)
 	r.Header.Set("Accept", "application/vnd.api+json")
@@ -199,8 +238,29 @@ func TestHandleResource(t *testing.T) {
 		)
 	}
 
+	// PATCH resource missing Content-Type header
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"e":"f"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusUnsupportedMediaType,
+		)
+	}
+
 	// PATCH non-existent resource
-	r = httptest.NewRequest(http.MethodPatch, "/foo/baz", nil)
+	b = []byte(`{"data":{"type":"foo","id":"baz","attributes":{"e":"f"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/baz", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
 	r.Header.Set("Accept", "application/vnd.api+json")
 	ctx = chi.NewRouteContext()
 	ctx.URLParams.Add("type", "foo")
@@ -209,16 +269,38 @@ func TestHandleResource(t *testing.T) {
 	w = httptest.NewRecorder()
 	e.HandleResource(w, r)
 	o = w.Result()
-	if o.StatusCode != http.StatusMethodNotAllowed {
+	if o.StatusCode != http.StatusNotFound {
 		t.Errorf(
 			"o.StatusCode = %v, want %v",
 			o.StatusCode,
-			http.StatusMethodNotAllowed,
+			http.StatusNotFound,
+		)
+	}
+
+	// PATCH resource with mismatched identifier
+	b = []byte(`{"data":{"type":"foo","id":"baz","attributes":{"e":"f"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusConflict {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusConflict,
 		)
 	}
 
 	// PATCH resource
-	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", nil)
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"e":"f"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
 	r.Header.Set("Accept", "application/vnd.api+json")
 	ctx = chi.NewRouteContext()
 	ctx.URLParams.Add("type", "foo")
@@ -227,11 +309,228 @@ func TestHandleResource(t *testing.T) {
 	w = httptest.NewRecorder()
 	e.HandleResource(w, r)
 	o = w.Result()
-	if o.StatusCode != http.StatusMethodNotAllowed {
+	if o.StatusCode != http.StatusOK {
 		t.Errorf(
 			"o.StatusCode = %v, want %v",
 			o.StatusCode,
-			http.StatusMethodNotAllowed,
+			http.StatusOK,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"a": "b"`)) || !bytes.Contains(b, []byte(`"e": "f"`)) {
+		t.Errorf("attributes not merged: %s", b)
+	}
+
+	// GET resource with sparse fieldset naming field added since cached
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar?fields[foo]=e", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// PATCH resource failing to validate against JSON schema
+	e.Schemas, err = schema.LoadResources(fstest.MapFS{
+		"foo.attributes.json": &fstest.MapFile{
+			Data: []byte(`{"type":"object","required":["a"],"properties":{"a":{"type":"string"},"e":{"type":"string"}}}`),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":1,"e":2}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusUnprocessableEntity,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"pointer": "/data/attributes/a"`)) || !bytes.Contains(b, []byte(`"pointer": "/data/attributes/e"`)) {
+		t.Errorf("missing error objects for violations: %s", b)
+	}
+
+	// POST resource failing to validate against JSON schema
+	b = []byte(`{"data":{"type":"foo","attributes":{"e":"f"}}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusUnprocessableEntity,
+		)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"pointer": "/data/attributes/a"`)) {
+		t.Errorf("missing error object for violation: %s", b)
+	}
+	e.Schemas = nil
+
+	// GET resource with entity tag
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	etag := o.Header.Get("ETag")
+	if etag != `"2"` {
+		t.Errorf("ETag = %s, want %s", etag, `"2"`)
+	}
+	lastModified := o.Header.Get("Last-Modified")
+	if lastModified == "" {
+		t.Error("missing Last-Modified")
+	}
+	var body struct {
+		Data struct {
+			Meta map[string]interface{} `json:"meta"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(o.Body).Decode(&body); err != nil {
+		t.Error(err)
+	} else if body.Data.Meta["created"] == nil || body.Data.Meta["updated"] == nil {
+		t.Errorf("meta = %v, want created and updated", body.Data.Meta)
+	}
+
+	// GET resource not modified since
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-Modified-Since", lastModified)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusNotModified {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotModified,
+		)
+	}
+
+	// GET resource of sparse fieldset, of another entity tag
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar?fields[foo]=a", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-None-Match", etag)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	fieldsETag := o.Header.Get("ETag")
+	if fieldsETag == etag || !strings.HasPrefix(fieldsETag, `"2-`) {
+		t.Errorf("ETag = %s, want a tag of revision 2 other than %s", fieldsETag, etag)
+	}
+
+	// PATCH resource not matching If-Match
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"e":"g"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-Match", `"1", W/"2"`)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusPreconditionFailed,
+		)
+	}
+
+	// PATCH resource matching If-Match
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"e":"g"}}}`)
+	r = httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-Match", `"1", `+fieldsETag)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if got := o.Header.Get("ETag"); got != `"3"` {
+		t.Errorf("ETag = %s, want %s", got, `"3"`)
+	}
+
+	// DELETE resource not matching If-Match
+	r = httptest.NewRequest(http.MethodDelete, "/foo/bar", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	r.Header.Set("If-Match", etag)
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusPreconditionFailed,
 		)
 	}
 
@@ -290,3 +589,83 @@ func TestHandleResource(t *testing.T) {
 	}
 
 }
+
+func TestHandleResourceClearToOne(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		Graph:      g,
+		Parameters: config.Parameters,
+		Stderr:     log.New(devnull, "", 0),
+		Stdout:     log.New(devnull, "", 0),
+	}
+
+	// post resources, the second with a to-one relationship to the first
+	for _, b := range []string{
+		`{"data":{"type":"foo","id":"qux"}}`,
+		`{"data":{"type":"foo","id":"bar","relationships":{"owner":{"data":{"type":"foo","id":"qux"}}}}}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/foo/", strings.NewReader(b))
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx := chi.NewRouteContext()
+		ctx.URLParams.Add("type", "foo")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w := httptest.NewRecorder()
+		e.HandleCollection(w, r)
+		if o := w.Result(); o.StatusCode != http.StatusCreated {
+			t.Fatalf("o.StatusCode = %v, want %v", o.StatusCode, http.StatusCreated)
+		}
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// PATCH resource, clearing the to-one relationship
+	b := []byte(`{"data":{"type":"foo","id":"bar","relationships":{"owner":{"data":null}}}}`)
+	r := httptest.NewRequest(http.MethodPatch, "/foo/bar", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx := chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w := httptest.NewRecorder()
+	e.HandleResource(w, r)
+	o := w.Result()
+	if o.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(o.Body)
+		t.Fatalf("o.StatusCode = %v, want %v: %s", o.StatusCode, http.StatusOK, b)
+	}
+
+	// GET the relationship, now empty
+	r = httptest.NewRequest(http.MethodGet, "/foo/bar/relationships/owner", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	ctx.URLParams.Add("id", "bar")
+	ctx.URLParams.Add("relationship", "owner")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleRelationship(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusOK {
+		t.Errorf("o.StatusCode = %v, want %v", o.StatusCode, http.StatusOK)
+	}
+	if b, _ := io.ReadAll(o.Body); !bytes.Contains(b, []byte(`"data": null`)) {
+		t.Errorf("relationship not cleared: %s", b)
+	}
+}
@@ -0,0 +1,60 @@
+package handle
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+// Restore is a handler for requests to restore a tombstoned resource (of
+// type t string and identifier i string), with possible method POST.  Its
+// route is under "-", which is not a member name, so as not to shadow
+// resources related by a relationship.
+func (env *Environment) HandleRestore(w http.ResponseWriter, r *http.Request) {
+
+	var response Response = NewResponse()
+
+	q, e := model.ParseQueryString(r.URL, env.Parameters)
+	if e != nil {
+		env.Fail(w, r, e)
+		return
+	}
+
+	t := chi.URLParam(r, "type")
+	i := chi.URLParam(r, "id")
+
+	switch r.Method {
+
+	case "OPTIONS":
+
+		response.Header.Set("Allow", "OPTIONS, POST")
+		response.Header.Set("Access-Control-Allow-Methods", "OPTIONS, POST")
+		response.Status = http.StatusNoContent
+		env.Success(w, r, response)
+		return
+
+	case "POST":
+
+		document, v, e := model.RestoreResource(r.Context(), env.Graph, t, i, env.BaseURL, q)
+		if e != nil {
+			env.Fail(w, r, e)
+			return
+		}
+		response.Header.Set("ETag", v.ETag)
+		response.Body = document
+		response.Status = http.StatusOK
+		env.Success(w, r, response)
+		return
+
+	default:
+
+		// HTTP Method not allowed
+		e := core.MakeError(http.StatusMethodNotAllowed)
+		e.Code = "8c41fa"
+		env.Fail(w, r, e)
+		return
+
+	}
+}
@@ -0,0 +1,159 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-server/config"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestHandleRestore(t *testing.T) {
+
+	var (
+		b   []byte
+		ctx *chi.Context
+		r   *http.Request
+		o   *http.Response
+		w   *httptest.ResponseRecorder
+	)
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		Graph:      g,
+		Parameters: config.Parameters,
+		SoftDelete: true,
+		Stderr:     log.New(devnull, "", 0),
+		Stdout:     log.New(devnull, "", 0),
+	}
+
+	// post resource
+	b = []byte(`{"data":{"type":"foo","id":"bar","attributes":{"a":"b"}}}`)
+	r = httptest.NewRequest(http.MethodPost, "/foo/", bytes.NewBuffer(b))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set("Accept", "application/vnd.api+json")
+	ctx = chi.NewRouteContext()
+	ctx.URLParams.Add("type", "foo")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+	w = httptest.NewRecorder()
+	e.HandleCollection(w, r)
+	o = w.Result()
+	if o.StatusCode != http.StatusCreated {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusCreated,
+		)
+	}
+
+	// request to resource
+	resource := func(method string) *http.Response {
+		r := httptest.NewRequest(method, "/foo/bar", nil)
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx := chi.NewRouteContext()
+		ctx.URLParams.Add("type", "foo")
+		ctx.URLParams.Add("id", "bar")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w := httptest.NewRecorder()
+		e.HandleResource(w, r)
+		return w.Result()
+	}
+
+	// request to restore resource
+	restore := func(method string) *http.Response {
+		r := httptest.NewRequest(method, "/foo/bar/-/restore", nil)
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx := chi.NewRouteContext()
+		ctx.URLParams.Add("type", "foo")
+		ctx.URLParams.Add("id", "bar")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w := httptest.NewRecorder()
+		e.HandleRestore(w, r)
+		return w.Result()
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// POST restore of resource not tombstoned
+	o = restore(http.MethodPost)
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+
+	// DELETE resource, softly
+	o = resource(http.MethodDelete)
+	if o.StatusCode != http.StatusNoContent {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNoContent,
+		)
+	}
+
+	// GET tombstoned resource
+	o = resource(http.MethodGet)
+	if o.StatusCode != http.StatusNotFound {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusNotFound,
+		)
+	}
+
+	// POST restore
+	o = restore(http.MethodPost)
+	if o.StatusCode != http.StatusOK {
+		t.Fatalf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+	if o.Header.Get("ETag") == "" {
+		t.Errorf("Got no ETag, want ETag of resource restored")
+	}
+
+	// GET restored resource
+	o = resource(http.MethodGet)
+	if o.StatusCode != http.StatusOK {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusOK,
+		)
+	}
+
+	// Unsupported method
+	o = restore(http.MethodGet)
+	if o.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf(
+			"o.StatusCode = %v, want %v",
+			o.StatusCode,
+			http.StatusMethodNotAllowed,
+		)
+	}
+}
@@ -0,0 +1,124 @@
+package handle
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+// WriteOnly are the attributes, keyed by resource type, that are written by
+// request documents but never read: they are omitted from every resource
+// of a response, whether primary data, included, related, the result of an
+// operation or an event of the change feed, and from the history of the
+// resource, and may not be filtered or sorted on.
+type WriteOnly map[string][]string
+
+// Returns whether attribute k of resources of type t is write-only, or of
+// resources of any type if t is empty.
+func (wo WriteOnly) holds(t, k string) bool {
+
+	if t != "" {
+		return stringInSlice(k, wo[t])
+	}
+
+	for _, keys := range wo {
+		if stringInSlice(k, keys) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Omits the write-only attributes of resource.
+func (wo WriteOnly) redact(resource core.Resource) {
+
+	for _, k := range wo[resource.Type] {
+		delete(resource.Attributes, k)
+	}
+}
+
+// Omits the write-only attributes of the data and included resources of
+// document.
+func (wo WriteOnly) redactDocument(document *core.Document) {
+
+	wo.redactData(document.Data)
+
+	for _, resource := range document.Included {
+		wo.redact(resource)
+	}
+}
+
+// Omits the write-only attributes of data, a resource or collection of
+// resources.
+func (wo WriteOnly) redactData(data interface{}) {
+
+	switch data := data.(type) {
+	case core.Resource:
+		wo.redact(data)
+	case *core.Resource:
+		wo.redact(*data)
+	case core.Collection:
+		for _, resource := range data {
+			wo.redact(resource)
+		}
+	case []core.Resource:
+		for _, resource := range data {
+			wo.redact(resource)
+		}
+	}
+}
+
+// Omits the write-only attributes of resources of type t from the states
+// before and after each change of a history.
+func (wo WriteOnly) redactHistory(t string, document *core.Document) {
+
+	collection, ok := document.Data.(core.Collection)
+	if !ok || len(wo[t]) == 0 {
+		return
+	}
+
+	for _, change := range collection {
+		for _, state := range []string{"before", "after"} {
+			if s, ok := change.Attributes[state].(map[string]interface{}); ok {
+				if attributes, ok := s["attributes"].(map[string]interface{}); ok {
+					for _, k := range wo[t] {
+						delete(attributes, k)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Refuses the filters and sort keys of q on write-only attributes of
+// resources of type t, or of any type if t is empty, as their results would
+// disclose the values of those attributes.
+func (wo WriteOnly) refuse(t string, q model.QueryParams) *core.Error {
+
+	fail := func(parameter string, path []string) *core.Error {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "3c9e57"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = fmt.Sprintf("Attribute %s is write-only and may not be filtered or sorted on", strings.Join(path, "."))
+		errObj.Source = &core.SourceObject{Parameter: parameter}
+		return errObj
+	}
+
+	for _, filter := range q.Filters {
+		if wo.holds(t, filter.Path[0]) {
+			return fail("filter", filter.Path)
+		}
+	}
+
+	for _, key := range q.Sort {
+		if wo.holds(t, key.Path[0]) {
+			return fail("sort", key.Path)
+		}
+	}
+
+	return nil
+}
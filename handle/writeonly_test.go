@@ -0,0 +1,121 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wamuir/go-jsonapi-server/config"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestWriteOnly(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// set up environment
+	e := &Environment{
+		Graph:      g,
+		Parameters: config.Parameters,
+		Stderr:     log.New(devnull, "", 0),
+		Stdout:     log.New(devnull, "", 0),
+		WriteOnly:  WriteOnly{"webhooks": {"secret"}},
+	}
+
+	// Serves a request with the route parameters given in pairs
+	serve := func(handler http.HandlerFunc, method, target, body string, params ...string) (int, string) {
+		r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		r.Header.Set("Content-Type", "application/vnd.api+json")
+		r.Header.Set("Accept", "application/vnd.api+json")
+		ctx := chi.NewRouteContext()
+		for n := 0; n+1 < len(params); n += 2 {
+			ctx.URLParams.Add(params[n], params[n+1])
+		}
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		o := w.Result()
+		b, _ := io.ReadAll(o.Body)
+		return o.StatusCode, string(b)
+	}
+
+	// post a subscription, with its secret, and a resource related to it
+	for _, resource := range []struct{ t, body string }{
+		{"webhooks", `{"data":{"type":"webhooks","id":"a","attributes":{"url":"https://hooks.example.com/","secret":"s3cr3t"}}}`},
+		{"foo", `{"data":{"type":"foo","id":"bar","relationships":{"hook":{"data":{"type":"webhooks","id":"a"}}}}}`},
+	} {
+		status, body := serve(e.HandleCollection, http.MethodPost, "/"+resource.t+"/", resource.body, "type", resource.t)
+		if status != http.StatusCreated {
+			t.Fatalf("POST /%s/: got %d, want %d", resource.t, status, http.StatusCreated)
+		} else if strings.Contains(body, "s3cr3t") {
+			t.Errorf("POST /%s/: got the secret in %s", resource.t, body)
+		}
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// omitted from each representation of the subscription
+	for _, test := range []struct {
+		handler http.HandlerFunc
+		target  string
+		params  []string
+	}{
+		{e.HandleResource, "/webhooks/a/", []string{"type", "webhooks", "id", "a"}},
+		{e.HandleResource, "/webhooks/a/?fields[webhooks]=url,secret", []string{"type", "webhooks", "id", "a"}},
+		{e.HandleCollection, "/webhooks/", []string{"type", "webhooks"}},
+		{e.HandleResource, "/foo/bar/?include=hook", []string{"type", "foo", "id", "bar"}},
+		{e.HandleRelated, "/foo/bar/hook", []string{"type", "foo", "id", "bar", "related", "hook"}},
+		{e.HandleHistory, "/webhooks/a/-/history", []string{"type", "webhooks", "id", "a"}},
+	} {
+		status, body := serve(test.handler, http.MethodGet, test.target, "", test.params...)
+		if status != http.StatusOK {
+			t.Errorf("GET %s: got %d, want %d", test.target, status, http.StatusOK)
+		} else if strings.Contains(body, "s3cr3t") {
+			t.Errorf("GET %s: got the secret in %s", test.target, body)
+		} else if !strings.Contains(body, "hooks.example.com") {
+			t.Errorf("GET %s: got no url in %s", test.target, body)
+		}
+	}
+
+	// not filtered or sorted on
+	for _, test := range []struct {
+		handler http.HandlerFunc
+		target  string
+		params  []string
+	}{
+		{e.HandleCollection, "/webhooks/?filter[secret]=s3cr3t", []string{"type", "webhooks"}},
+		{e.HandleCollection, "/webhooks/?filter[secret][like]=s%25", []string{"type", "webhooks"}},
+		{e.HandleCollection, "/webhooks/?sort=-secret", []string{"type", "webhooks"}},
+		{e.HandleRelated, "/foo/bar/hook?filter[secret]=s3cr3t", []string{"type", "foo", "id", "bar", "related", "hook"}},
+	} {
+		status, _ := serve(test.handler, http.MethodGet, test.target, "", test.params...)
+		if status != http.StatusBadRequest {
+			t.Errorf("GET %s: got %d, want %d", test.target, status, http.StatusBadRequest)
+		}
+	}
+
+	// of other types, an attribute as any other
+	status, _ := serve(e.HandleCollection, http.MethodGet, "/foo/?filter[secret]=s3cr3t", "", "type", "foo")
+	if status != http.StatusOK {
+		t.Errorf("GET /foo/?filter[secret]=s3cr3t: got %d, want %d", status, http.StatusOK)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -11,8 +13,14 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/wamuir/go-jsonapi-server/config"
+	"github.com/wamuir/go-jsonapi-server/graph"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+	postgres "github.com/wamuir/go-jsonapi-server/graph/postgres"
 	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
 	"github.com/wamuir/go-jsonapi-server/handle"
+	"github.com/wamuir/go-jsonapi-server/model"
+	"github.com/wamuir/go-jsonapi-server/schema"
+	"github.com/wamuir/go-jsonapi-server/webhook"
 )
 
 func main() {
@@ -20,37 +28,146 @@ func main() {
 	stderr := log.New(os.Stderr, "ERROR: ", log.LstdFlags|log.LUTC)
 	stdout := log.New(os.Stdout, "INFO: ", log.LstdFlags|log.LUTC)
 
-	graph, err := sqlite3.Connect(config.DSN.String())
+	if len(os.Args) > 1 {
+		// Progress is logged to stderr, as stdout may be an export
+		err := command(os.Args[1], os.Args[2:], log.New(os.Stderr, "INFO: ", log.LstdFlags|log.LUTC))
+		if err != nil {
+			stderr.Fatal(err.Error())
+		}
+		return
+	}
+
+	graph, err := connect(config.DSN.String(), config.MigrateOnStart)
 	if err != nil {
 		stderr.Fatal(err.Error())
 	}
 
+	serve(graph, stdout, stderr)
+}
+
+// A backend, of its Connect, Open and Migrate.
+type backend struct {
+	connect func(dsn string) (graph.Graph, error)
+	open    func(dsn string) (graph.Graph, error)
+	migrate func(dsn string) (int, int, error)
+}
+
+// Returns the backend of dsn: postgres for a URL of scheme postgres or
+// postgresql, memory, of a graph that is empty and lost on exit, for one of
+// scheme memory, and otherwise sqlite3.
+func backendOf(dsn string) backend {
+
+	u, err := url.Parse(dsn)
+	switch {
+	case err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"):
+		return backend{postgres.Connect, postgres.Open, postgres.Migrate}
+	case err == nil && u.Scheme == "memory":
+		connect := func(string) (graph.Graph, error) { return memory.New(), nil }
+		return backend{connect, connect, func(string) (int, int, error) { return 0, 0, nil }}
+	}
+
+	return backend{sqlite3.Connect, sqlite3.Open, sqlite3.Migrate}
+}
+
+// Connect to the graph of dsn, migrating its schema if migrate.
+func connect(dsn string, migrate bool) (graph.Graph, error) {
+
+	if migrate {
+		return backendOf(dsn).connect(dsn)
+	}
+
+	return backendOf(dsn).open(dsn)
+}
+
+// Serve the graph, for as long as the server runs.
+func serve(graph graph.Graph, stdout, stderr *log.Logger) {
+
+	errObj := model.DeclareRelationships(context.Background(), graph, config.Relationships)
+	if errObj != nil {
+		stderr.Fatal(errObj.Detail)
+	}
+
+	var schemas *schema.Resources
+	if config.SchemaDir != "" {
+		var err error
+		schemas, err = schema.LoadResources(os.DirFS(config.SchemaDir))
+		if err != nil {
+			stderr.Fatal(err.Error())
+		}
+	}
+
+	registry := handle.NewRegistry()
+	registry.RegisterExtension(handle.Extension{URI: model.AtomicExtension})
+
 	env := &handle.Environment{
-		BaseURL:    config.BaseURL,
-		Graph:      graph,
-		Parameters: config.Parameters,
-		Stderr:     stderr,
-		Stdout:     stdout,
+		ActorHeader:     config.ActorHeader,
+		BaseURL:         config.BaseURL,
+		CachePolicy:     config.CachePolicy,
+		ChangesInterval: time.Duration(config.ChangesInterval) * time.Millisecond,
+		ChangesTimeout:  time.Duration(config.ChangesTimeout) * time.Millisecond,
+		Graph:           graph,
+		Parameters:      config.Parameters,
+		Registry:        registry,
+		Schemas:         schemas,
+		SoftDelete:      config.SoftDelete,
+		Stderr:          stderr,
+		Stdout:          stdout,
+	}
+
+	if config.WebhookType != "" {
+		env.WriteOnly = handle.WriteOnly{config.WebhookType: {"secret"}}
 	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
-	r.Use(middleware.NoCache)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(time.Duration(config.CtxTimeout) * time.Second))
+	r.Use(env.Negotiate)
+	r.Use(env.Audit)
 	r.NotFound(env.Handle404)
 	r.MethodNotAllowed(env.Handle405)
-	r.Route(`/{type}`, func(r chi.Router) {
-		r.HandleFunc("/", env.HandleCollection)
-		r.Route(`/{id}`, func(r chi.Router) {
-			r.HandleFunc(`/`, env.HandleResource)
-			r.HandleFunc(`/{related}`, env.HandleRelated)
-			r.HandleFunc(`/relationships/{relationship}`, env.HandleRelationship)
+
+	// Streams of the change feed outlast the context timeout
+	r.HandleFunc(`/_changes`, env.HandleChanges)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(time.Duration(config.CtxTimeout) * time.Second))
+		r.HandleFunc(`/operations`, env.HandleOperations)
+		r.Route(`/{type}`, func(r chi.Router) {
+			r.HandleFunc("/", env.HandleCollection)
+			r.Route(`/{id}`, func(r chi.Router) {
+				r.HandleFunc(`/`, env.HandleResource)
+				r.HandleFunc(`/{related}`, env.HandleRelated)
+				// Under "-", not a member name, so as not to shadow relationships
+				r.HandleFunc(`/-/history`, env.HandleHistory)
+				r.HandleFunc(`/-/restore`, env.HandleRestore)
+				r.HandleFunc(`/relationships/{relationship}`, env.HandleRelationship)
+			})
 		})
 	})
 
+	if config.SoftDelete {
+		go purge(graph, stdout, stderr)
+	}
+
+	go purgeOutbox(graph, stdout, stderr)
+
+	if config.WebhookType != "" {
+		worker := &webhook.Worker{
+			Graph:      graph,
+			Type:       config.WebhookType,
+			Hosts:      config.WebhookHosts,
+			Client:     &http.Client{Timeout: time.Duration(config.WebhookTimeout) * time.Second},
+			Attempts:   config.WebhookAttempts,
+			Backoff:    time.Duration(config.WebhookBackoff) * time.Second,
+			MaxBackoff: time.Duration(config.WebhookMaxBackoff) * time.Second,
+			Stderr:     stderr,
+			Stdout:     stdout,
+		}
+		go worker.Run(context.Background(), time.Duration(config.WebhookInterval)*time.Second)
+	}
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", config.ListenAddr, config.ListenPort),
 		Handler:      r,
@@ -62,3 +179,49 @@ func main() {
 
 	stderr.Fatal(server.ListenAndServe())
 }
+
+// Purge resources tombstoned longer than the retention period, at each
+// interval, for as long as the server runs.
+func purge(g graph.Graph, stdout, stderr *log.Logger) {
+
+	retention := time.Duration(config.RetentionHours) * time.Hour
+
+	ticker := time.NewTicker(time.Duration(config.PurgeHours) * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+
+		count, errObj := model.PurgeResources(context.Background(), g, time.Now().Add(-retention))
+		if errObj != nil {
+			stderr.Print(errObj.Detail)
+			continue
+		}
+
+		if count > 0 {
+			stdout.Printf("Purged %d tombstoned resources", count)
+		}
+	}
+}
+
+// Purge changes older than the retention period from the outbox, while it
+// has no consumer, at each interval, for as long as the server runs.
+func purgeOutbox(g graph.Graph, stdout, stderr *log.Logger) {
+
+	retention := time.Duration(config.OutboxRetentionHours) * time.Hour
+
+	ticker := time.NewTicker(time.Duration(config.OutboxPurgeHours) * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+
+		count, errObj := model.PurgeMessages(context.Background(), g, time.Now().Add(-retention))
+		if errObj != nil {
+			stderr.Print(errObj.Detail)
+			continue
+		}
+
+		if count > 0 {
+			stdout.Printf("Purged %d changes from the outbox", count)
+		}
+	}
+}
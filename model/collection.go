@@ -23,7 +23,12 @@ func GetCollection(ctx context.Context, g graph.Graph, t string, h url.URL, q Qu
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
+
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return nil, errObj
+	}
 
 	document, errObj := tx.GetCollection(t, h, q)
 	if errObj != nil {
@@ -39,7 +44,23 @@ func (tx *Tx) GetCollection(t string, h url.URL, q QueryParams) (*core.Document,
 
 	var document *core.Document = &core.Document{}
 
-	count, err := tx.CountVertices(t)
+	if len(q.Sort) > 0 {
+		attributeKeys, err := tx.findAttributeKeys(t, sortAttributes(q))
+		if err != nil {
+			e := core.MakeError(http.StatusInternalServerError)
+			e.Code = "c93b0f"
+			e.Title = "Encountered internal error while querying graph"
+			e.Detail = err.Error()
+			return nil, e
+		}
+
+		e := validateSort(attributeKeys, q)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	count, err := tx.CountVertices(t, q.Filters)
 	if err != nil {
 		e := core.MakeError(http.StatusInternalServerError)
 		e.Code = "d71a15"
@@ -48,16 +69,33 @@ func (tx *Tx) GetCollection(t string, h url.URL, q QueryParams) (*core.Document,
 		return nil, e
 	}
 
+	// An empty collection is found only for a known type
 	if count == 0 {
-		e := core.MakeError(http.StatusNotFound)
-		e.Code = "4aea6d"
-		return nil, e
+		err := tx.FindVertexType(t)
+		if err == graph.ErrNoRows {
+			e := core.MakeError(http.StatusNotFound)
+			e.Code = "4aea6d"
+			return nil, e
+		} else if err != nil {
+			e := core.MakeError(http.StatusInternalServerError)
+			e.Code = "5f2c81"
+			e.Title = "Encountered internal error while querying graph"
+			e.Detail = err.Error()
+			return nil, e
+		}
 	}
 
 	collection := make(core.Collection, 0, count)
 
-	vertices, err := tx.FindVertices(t, q.Limit, q.Offset, q.Sort)
-	if err != nil {
+	vertices, err := tx.FindVertices(t, q.Filters, q.Sort, q.page())
+	if err == graph.ErrInvalidCursor {
+		e := core.MakeError(http.StatusBadRequest)
+		e.Code = "7a0c3e"
+		e.Title = "Invalid query string"
+		e.Detail = err.Error()
+		e.Source = &core.SourceObject{Parameter: q.cursorParameter()}
+		return nil, e
+	} else if err != nil {
 		e := core.MakeError(http.StatusInternalServerError)
 		e.Code = "f3bce6"
 		e.Title = "Encountered internal error while querying graph"
@@ -65,6 +103,9 @@ func (tx *Tx) GetCollection(t string, h url.URL, q QueryParams) (*core.Document,
 		return nil, e
 	}
 
+	from, to, more := q.trim(len(vertices))
+	vertices = vertices[from:to]
+
 	for _, vertex := range vertices {
 
 		identifier := core.Resource{
@@ -110,10 +151,29 @@ func (tx *Tx) GetCollection(t string, h url.URL, q QueryParams) (*core.Document,
 		return nil, e
 	}
 
+	if q.Cursor {
+		var first, last string
+		if len(vertices) > 0 {
+			first, last = vertices[0].Cursor, vertices[len(vertices)-1].Cursor
+		}
+		_, document.Links = paginateByCursor(
+			collection,
+			h,
+			ref,
+			q.Values,
+			q,
+			first,
+			last,
+			more,
+		)
+		return document, nil
+	}
+
 	_, document.Links = paginate(
 		collection,
 		h,
 		ref,
+		q.Values,
 		q.Limit,
 		q.Offset,
 		count,
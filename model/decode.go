@@ -2,6 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -9,25 +11,45 @@ import (
 	"github.com/wamuir/go-jsonapi-server/schema"
 )
 
-func Decode(body io.Reader) (*core.Document, *core.Error) {
+// Decode a request document, validating it against the JSON:API schema.
+// Each violation of the schema is its own error object, pointing to the
+// value at fault.
+func Decode(body io.Reader) (*core.Document, []*core.Error) {
 
-	var document *core.Document
+	var (
+		document *core.Document
+		members  map[string]json.RawMessage
+		raw      json.RawMessage
+	)
 
 	// Decode JSON document
 	decoder := json.NewDecoder(body)
-	err := decoder.Decode(&document)
-	if err != nil {
+	err := decoder.Decode(&raw)
+	if err == nil {
+		err = json.Unmarshal(raw, &document)
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &members)
+	}
+	if err != nil || document == nil {
 		e := core.MakeError(http.StatusBadRequest)
 		e.Code = "e6f91b"
-		return nil, e
+		e.Title = "Malformed request body"
+		e.Detail = describeMalformed(err, decoder.InputOffset()-int64(len(raw)))
+		return nil, []*core.Error{e}
 	}
 
-	// Decode Data member
-	data, errObj := decodeDataMbr(document.Data)
-	if errObj != nil {
-		return document, errObj
+	// Decode Data member, keeping a null value distinct from a missing one
+	if _, ok := members["data"]; ok {
+		data, errObj := decodeDataMbr(document.Data)
+		if errObj != nil {
+			return document, []*core.Error{errObj}
+		}
+		document.Data = data
+		if resource, ok := data.(core.Resource); ok {
+			keepRelationshipNulls(resource, members["data"])
+		}
 	}
-	document.Data = data
 
 	// Validate
 	result, err := schema.Validate(document)
@@ -36,22 +58,88 @@ func Decode(body io.Reader) (*core.Document, *core.Error) {
 		errObj.Code = "52f5cf"
 		errObj.Title = "Encountered internal error while validating request body against JSON:API schema"
 		errObj.Detail = err.Error()
-		return document, errObj
+		return document, []*core.Error{errObj}
 	}
 
 	if !result.Valid() {
-		errObj := core.MakeError(http.StatusBadRequest)
-		errObj.Code = "d96685"
-		errObj.Title = "Request body failed to validate against JSON:API schema"
-		// errObj.Detail = <-- need to add this... pointer?
-		return document, errObj
+		var errObjs []*core.Error
+		for _, violation := range result.Errors() {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "d96685"
+			errObj.Title = "Request body failed to validate against JSON:API schema"
+			errObj.Detail = violation.Description()
+			if pointer := schema.Pointer("", violation); pointer != "" {
+				errObj.Source = &core.SourceObject{Pointer: pointer}
+			}
+			errObjs = append(errObjs, errObj)
+		}
+		return document, errObjs
 	}
 
 	return document, nil
 }
 
+// Describe err, from decoding a malformed JSON document, with the byte
+// offset at fault.  Offsets of errors from unmarshaling the document, once
+// read, are relative to base, the offset of the document in the body.
+func describeMalformed(err error, base int64) string {
+
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+
+	case err == nil:
+		return "Request body must contain a JSON object"
+
+	case err == io.EOF:
+		return "Request body is empty"
+
+	case err == io.ErrUnexpectedEOF:
+		return "Unexpected end of JSON input"
+
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Syntax error at byte offset %d: %s", syntaxErr.Offset, syntaxErr)
+
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("Invalid value at byte offset %d: %s", base+typeErr.Offset, typeErr)
+
+	}
+
+	return err.Error()
+}
+
+// Set the data of each relationship of resource, decoded from raw, whose
+// data member is null, to null rather than nil, as a null value clears a
+// relationship, while a missing one leaves it be.
+func keepRelationshipNulls(resource core.Resource, raw json.RawMessage) {
+
+	var members struct {
+		Relationships map[string]map[string]json.RawMessage `json:"relationships"`
+	}
+
+	if json.Unmarshal(raw, &members) != nil {
+		return
+	}
+
+	for k, relationship := range members.Relationships {
+		if data, ok := relationship["data"]; ok && string(data) == "null" {
+			document := resource.Relationships[k]
+			document.Data = null{}
+			resource.Relationships[k] = document
+		}
+	}
+}
+
 func decodeDataMbr(i interface{}) (interface{}, *core.Error) {
 
+	switch i.(type) {
+	case nil, null:
+		return null{}, nil
+	}
+
 	j, err := json.Marshal(i)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
@@ -4,8 +4,21 @@ import (
 	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
-type Tx struct{ graph.Tx }
+// Tx is a transaction on Graph, which, if not nil, keys what is cached
+// between transactions on the graph.
+type Tx struct {
+	graph.Tx
+	Graph graph.Graph
+}
 
 type Edge = graph.Edge
 
 type Vertex = graph.Vertex
+
+// null is a data member whose value is null, as distinct from a document
+// that has no data member at all.
+type null struct{}
+
+func (null) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
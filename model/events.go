@@ -0,0 +1,235 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Names of the events of the change feed, for resources created (or
+// restored), updated and deleted (or tombstoned) and for members added to
+// and removed from relationships.
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventAdd    = "add"
+	EventRemove = "remove"
+)
+
+// Event is an event of the change feed: a committed change to a resource of
+// Type, or to a Relationship of it, as a JSON:API document.  Events are in
+// order of ID, with which a feed may be resumed.
+type Event struct {
+	ID           int64
+	Name         string
+	Type         string
+	Relationship string
+	Document     *core.Document
+}
+
+// Begin a new transaction (*Tx) and make a call to *Tx.GetEvents()
+func GetEvents(ctx context.Context, g graph.Graph, types []string, after, limit int64) ([]Event, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, true)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "b5e1c2"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	return tx.GetEvents(types, after, limit)
+}
+
+// Find and return up to limit events following the event with ID after, for
+// resources of any type, or of types.
+func (tx *Tx) GetEvents(types []string, after, limit int64) ([]Event, *core.Error) {
+
+	changes, err := tx.FindChangesAfter(after, types, limit)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "3e96a0"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	events := make([]Event, 0, len(changes))
+
+	for _, change := range changes {
+
+		event, errObj := NewEvent(change)
+		if errObj != nil {
+			return nil, errObj
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Begin a new transaction (*Tx) and return the ID of the last event, or zero
+// if none, from which to follow the change feed.
+func GetLastEventID(ctx context.Context, g graph.Graph) (int64, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, true)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "d49f17"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+	defer transaction.Close()
+
+	id, err := transaction.FindLastChangeID()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "70ac3b"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+
+	return id, nil
+}
+
+// NewEvent returns the event for change.  The data of an event for a resource is the
+// resource, or its identifier if deleted.  The data of an event for a
+// relationship is the identifier of the resource, with the relationship
+// having as data the member added, updated or removed.
+func NewEvent(change graph.Change) (Event, *core.Error) {
+
+	var (
+		event = Event{ID: change.ID, Type: change.VertexType, Relationship: change.Key}
+		state []byte
+	)
+
+	switch change.Operation {
+	case graph.Insert, graph.Restore:
+		event.Name, state = EventCreate, change.After
+	case graph.Update:
+		event.Name, state = EventUpdate, change.After
+	case graph.Delete, graph.Tombstone:
+		event.Name, state = EventDelete, change.Before
+	}
+
+	resource := core.Resource{
+		Type:       change.VertexType,
+		Identifier: change.VertexID,
+	}
+
+	if change.Key != "" {
+
+		var edge struct {
+			To   graph.Identifier       `json:"to"`
+			Meta map[string]interface{} `json:"meta"`
+		}
+
+		err := json.Unmarshal(state, &edge)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "c2f85d"
+			errObj.Title = "Encountered internal error while transforming data"
+			errObj.Detail = err.Error()
+			return event, errObj
+		}
+
+		if event.Name == EventDelete {
+			event.Name = EventRemove
+		} else {
+			event.Name = EventAdd
+		}
+
+		resource.Relationships = map[string]core.Document{
+			change.Key: {
+				Data: core.Resource{
+					Type:       edge.To.Type,
+					Identifier: edge.To.ID,
+					Meta:       edge.Meta,
+				},
+			},
+		}
+
+	} else if event.Name != EventDelete {
+
+		var vertex struct {
+			Attributes map[string]interface{} `json:"attributes"`
+			Meta       map[string]interface{} `json:"meta"`
+		}
+
+		err := json.Unmarshal(state, &vertex)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "8f07e4"
+			errObj.Title = "Encountered internal error while transforming data"
+			errObj.Detail = err.Error()
+			return event, errObj
+		}
+
+		resource.Attributes, resource.Meta = vertex.Attributes, vertex.Meta
+	}
+
+	event.Document = &core.Document{
+		Data: resource,
+		Meta: map[string]interface{}{
+			"time": change.Time.UTC().Format(graph.TimeFormat),
+		},
+	}
+
+	if change.Actor != "" {
+		event.Document.Meta["actor"] = change.Actor
+	}
+
+	if change.RequestID != "" {
+		event.Document.Meta["requestId"] = change.RequestID
+	}
+
+	return event, nil
+}
+
+// Begin a new transaction and purge the messages of the outbox created
+// before createdBefore, if no consumer is registered, returning the number
+// purged.
+func PurgeMessages(ctx context.Context, g graph.Graph, createdBefore time.Time) (int64, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "6acf37"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+	defer transaction.Close()
+
+	count, err := transaction.PurgeMessages(createdBefore)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "6743d3"
+		errObj.Title = "Encountered internal error while deleting from graph"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "ed552e"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+
+	return count, nil
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/wamuir/go-jsonapi-core"
+)
+
+// Verify that each field requested in a sparse fieldset is an attribute or
+// relationship of at least one resource of that type.
+func (tx *Tx) ValidateFields(q QueryParams) *core.Error {
+
+	types := make([]string, 0, len(q.Fields))
+	for t := range q.Fields {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+
+		edgeKeys, err := tx.FindEdgeKeys(t)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "e2b95d"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
+
+		// Attribute keys are looked for only for fields not relationships
+		var want []string
+		for _, f := range q.Fields[t] {
+			if !stringInSlice(f, edgeKeys) {
+				want = append(want, f)
+			}
+		}
+
+		attributeKeys, err := tx.findAttributeKeys(t, want)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "4c1e8a"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
+
+		for _, f := range q.Fields[t] {
+			if !stringInSlice(f, attributeKeys) && !stringInSlice(f, edgeKeys) {
+				errObj := core.MakeError(http.StatusBadRequest)
+				errObj.Code = "a5f3c2"
+				errObj.Title = "Invalid query string"
+				errObj.Detail = fmt.Sprintf("Type %s has no field %s", t, f)
+				errObj.Source = &core.SourceObject{
+					Parameter: fmt.Sprintf("fields[%s]", t),
+				}
+				return errObj
+			}
+		}
+	}
+
+	return nil
+}
+
+// Remove from resource any attribute or relationship that is not selected by
+// its sparse fieldset.
+func applyFieldset(resource core.Resource, fields Fieldsets) core.Resource {
+
+	if _, ok := fields[resource.Type]; !ok {
+		return resource
+	}
+
+	for k := range resource.Attributes {
+		if !fields.Selects(resource.Type, k) {
+			delete(resource.Attributes, k)
+		}
+	}
+
+	for k := range resource.Relationships {
+		if !fields.Selects(resource.Type, k) {
+			delete(resource.Relationships, k)
+		}
+	}
+
+	if len(resource.Relationships) == 0 {
+		resource.Relationships = nil
+	}
+
+	return resource
+}
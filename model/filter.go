@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Parse filter parameters, which take the forms filter[path]=value and
+// filter[path][operator]=value, where path is an attribute name or a
+// dot-separated path to a nested attribute (e.g., author.name).  Values that
+// are JSON numbers, booleans or strings are compared as such; any other
+// value is compared as a string.  The paths meta.created and meta.updated
+// are of the times at which resources were created and last updated, which
+// compare as strings in the layout 2006-01-02T15:04:05.000000Z.  The in
+// operator takes a comma-separated list of values and the null operator
+// takes true or false.
+//
+//  examples:  ?filter[title]=Hello
+//             ?filter[age][ge]=21
+//             ?filter[author.name][in]=Alice,Bob
+//             ?filter[deletedAt][null]=true
+//             ?filter[meta.updated][ge]=2021-07-13
+//
+func ParseFilters(q url.Values) ([]graph.Filter, *core.Error) {
+
+	var filters []graph.Filter
+
+	// Iterate in a stable order, so queries are reproducible
+	parameters := make([]string, 0, len(q))
+	for par := range q {
+		if family(par) == "filter" {
+			parameters = append(parameters, par)
+		}
+	}
+	sort.Strings(parameters)
+
+	for _, par := range parameters {
+
+		var (
+			filter graph.Filter
+			key    string
+			op     string
+		)
+
+		fail := func(detail string) *core.Error {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "7f2d4b"
+			errObj.Title = "Invalid query string"
+			errObj.Detail = detail
+			errObj.Source = &core.SourceObject{Parameter: par}
+			return errObj
+		}
+
+		members := strings.Split(strings.TrimSuffix(strings.TrimPrefix(par, "filter["), "]"), "][")
+		switch len(members) {
+		case 1:
+			key, op = members[0], string(graph.Equal)
+		case 2:
+			key, op = members[0], members[1]
+		default:
+			return nil, fail(fmt.Sprintf("Unable to parse filter parameter %s", par))
+		}
+
+		filter.Path = strings.Split(key, ".")
+		for _, p := range filter.Path {
+			if p == "" {
+				return nil, fail(fmt.Sprintf("Invalid attribute path in filter parameter %s", par))
+			}
+		}
+
+		for _, entry := range q[par] {
+
+			filter.Operator = graph.Operator(op)
+			filter.Values = nil
+
+			switch filter.Operator {
+
+			case graph.Equal, graph.NotEqual, graph.LessThan, graph.LessThanOrEqual,
+				graph.GreaterThan, graph.GreaterThanOrEqual:
+				filter.Values = []interface{}{filterValue(entry)}
+
+			case graph.Like:
+				filter.Values = []interface{}{entry}
+
+			case graph.In:
+				for _, v := range strings.Split(entry, ",") {
+					filter.Values = append(filter.Values, filterValue(v))
+				}
+
+			case graph.Null:
+				switch entry {
+				case "true":
+				case "false":
+					filter.Operator = graph.NotNull
+				default:
+					return nil, fail(fmt.Sprintf("Value of filter parameter %s must be true or false", par))
+				}
+
+			default:
+				return nil, fail(fmt.Sprintf("Unsupported filter operator: %s", op))
+
+			}
+
+			filters = append(filters, filter)
+		}
+	}
+
+	return filters, nil
+}
+
+// Returns the value of a filter parameter as a number, boolean or string.
+func filterValue(s string) interface{} {
+
+	var v interface{}
+
+	err := json.Unmarshal([]byte(s), &v)
+	if err != nil {
+		return s
+	}
+
+	switch v.(type) {
+	case float64, bool, string:
+		return v
+	}
+
+	return s
+}
@@ -0,0 +1,212 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// ChangeType is the resource type of the entries of the history of a
+// resource.
+const ChangeType = "changes"
+
+// Begin a new transaction (*Tx) and make a call to *Tx.GetHistory()
+func GetHistory(ctx context.Context, g graph.Graph, t, i string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, true)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "2b7c90"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	return tx.GetHistory(t, i, h, q)
+}
+
+// Find and return a JSON:API document with the history of the resource of
+// type t and identifier i, oldest first, as a collection of resource objects
+// of ChangeType.  The history of a deleted resource remains.
+func (tx *Tx) GetHistory(t, i string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
+
+	var document *core.Document = &core.Document{}
+
+	for _, unsupported := range []struct {
+		parameter string
+		given     bool
+	}{
+		{"fields", len(q.Fields) > 0},
+		{"filter", len(q.Filters) > 0},
+		{"include", len(q.Include) > 0},
+		{"sort", len(q.Sort) > 0},
+	} {
+		if unsupported.given {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "84e0f3"
+			errObj.Title = "Invalid query string"
+			errObj.Detail = fmt.Sprintf("Parameter not supported for history: %s", unsupported.parameter)
+			errObj.Source = &core.SourceObject{Parameter: unsupported.parameter}
+			return nil, errObj
+		}
+	}
+
+	count, err := tx.CountChanges(t, i)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "0f5ad6"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	// A resource without history is found only if it exists
+	if count == 0 {
+		_, err := tx.FindVertex(t, i)
+		if err == graph.ErrNoRows {
+			errObj := core.MakeError(http.StatusNotFound)
+			errObj.Code = "e51b2c"
+			return nil, errObj
+		} else if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "9c3e47"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return nil, errObj
+		}
+	}
+
+	changes, err := tx.FindChanges(t, i, q.page())
+	if err == graph.ErrInvalidCursor {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "5a90d1"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = err.Error()
+		errObj.Source = &core.SourceObject{Parameter: q.cursorParameter()}
+		return nil, errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "d03f8a"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	from, to, more := q.trim(len(changes))
+	changes = changes[from:to]
+
+	collection := make(core.Collection, 0, len(changes))
+
+	for _, change := range changes {
+
+		resource, errObj := changeResource(change)
+		if errObj != nil {
+			return nil, errObj
+		}
+
+		collection = append(collection, resource)
+	}
+
+	document.Data = collection
+
+	ref, err := url.Parse(
+		path.Join(t, i, "-", "history"),
+	)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "a6e24b"
+		errObj.Title = "Encountered internal error while generating response"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	if q.Cursor {
+		var first, last string
+		if len(changes) > 0 {
+			first, last = changes[0].Cursor, changes[len(changes)-1].Cursor
+		}
+		_, document.Links = paginateByCursor(
+			collection,
+			h,
+			ref,
+			q.Values,
+			q,
+			first,
+			last,
+			more,
+		)
+		return document, nil
+	}
+
+	_, document.Links = paginate(
+		collection,
+		h,
+		ref,
+		q.Values,
+		q.Limit,
+		q.Offset,
+		count,
+	)
+
+	return document, nil
+}
+
+// Returns the resource object of ChangeType for change.
+func changeResource(change graph.Change) (core.Resource, *core.Error) {
+
+	resource := core.Resource{
+		Type:       ChangeType,
+		Identifier: strconv.FormatInt(change.ID, 10),
+		Attributes: map[string]interface{}{
+			"operation": change.Operation,
+			"time":      change.Time.UTC().Format(graph.TimeFormat),
+			"actor":     nil,
+			"requestId": nil,
+		},
+	}
+
+	if change.Key != "" {
+		resource.Attributes["relationship"] = change.Key
+	}
+
+	if change.Actor != "" {
+		resource.Attributes["actor"] = change.Actor
+	}
+
+	if change.RequestID != "" {
+		resource.Attributes["requestId"] = change.RequestID
+	}
+
+	for key, state := range map[string][]byte{
+		"before": change.Before,
+		"after":  change.After,
+	} {
+
+		var v interface{}
+
+		if state != nil {
+			err := json.Unmarshal(state, &v)
+			if err != nil {
+				errObj := core.MakeError(http.StatusInternalServerError)
+				errObj.Code = "77b1e9"
+				errObj.Title = "Encountered internal error while transforming data"
+				errObj.Detail = err.Error()
+				return resource, errObj
+			}
+		}
+
+		resource.Attributes[key] = v
+	}
+
+	return resource, nil
+}
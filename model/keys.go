@@ -0,0 +1,62 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// attributeKeyCache holds, per graph and type, the attribute keys last
+// found, sparing the validation of sparse fieldsets and sort fields a scan
+// of every resource of the type.
+var attributeKeyCache sync.Map
+
+type attributeKeysOf struct {
+	graph      graph.Graph
+	vertexType string
+}
+
+// Returns the attribute keys of resources of type t, as found for each of
+// want.  The keys are scanned for only if one of want is not among those
+// cached for the graph of tx.  As a key no longer found remains cached until
+// the next scan, a field of a type may be accepted after its last attribute
+// is removed, to the effect of selecting or sorting by a missing value.
+func (tx *Tx) findAttributeKeys(t string, want []string) ([]string, error) {
+
+	if len(want) == 0 {
+		return nil, nil
+	}
+
+	of := attributeKeysOf{tx.Graph, t}
+
+	if tx.Graph != nil {
+		if cached, ok := attributeKeyCache.Load(of); ok {
+			keys := cached.([]string)
+			if stringsInSlice(want, keys) {
+				return keys, nil
+			}
+		}
+	}
+
+	keys, err := tx.FindAttributeKeys(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Graph != nil {
+		attributeKeyCache.Store(of, keys)
+	}
+
+	return keys, nil
+}
+
+func stringsInSlice(a, list []string) bool {
+
+	for _, s := range a {
+		if !stringInSlice(s, list) {
+			return false
+		}
+	}
+
+	return true
+}
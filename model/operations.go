@@ -0,0 +1,495 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/xid"
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+	"github.com/wamuir/go-jsonapi-server/schema"
+)
+
+// AtomicExtension is the URI of the JSON:API Atomic Operations extension.
+const AtomicExtension = "https://jsonapi.org/ext/atomic"
+
+// Operations is a request or response document of the Atomic Operations
+// extension, with a list of operations or of their results.
+type Operations struct {
+	JSONAPI    *core.Implementation   `json:"jsonapi,omitempty"`
+	Operations []Operation            `json:"atomic:operations,omitempty"`
+	Results    []Result               `json:"atomic:results,omitempty"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Operation is an operation (add, update or remove) on the resource or
+// relationship targeted by either Ref or Href.  Data is kept raw until the
+// operation is performed, so that lid references may first be resolved.
+type Operation struct {
+	Op   string                 `json:"op"`
+	Ref  *Ref                   `json:"ref,omitempty"`
+	Href string                 `json:"href,omitempty"`
+	Data json.RawMessage        `json:"data,omitempty"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Ref targets a resource, by type and either id or lid, or a relationship
+// of that resource.
+type Ref struct {
+	Type         string `json:"type"`
+	Identifier   string `json:"id,omitempty"`
+	Local        string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// Result is the result of an operation, with the resource added or updated
+// by the operation as data, if any.
+type Result struct {
+	Data interface{}            `json:"data,omitempty"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Decode a request document of the Atomic Operations extension.
+func DecodeOperations(body io.Reader) ([]Operation, *core.Error) {
+
+	var (
+		document Operations
+		members  map[string]json.RawMessage
+		raw      json.RawMessage
+	)
+
+	decoder := json.NewDecoder(body)
+	err := decoder.Decode(&raw)
+	if err == nil {
+		err = json.Unmarshal(raw, &members)
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &document)
+	}
+	if err != nil {
+		e := core.MakeError(http.StatusBadRequest)
+		e.Code = "a2c94e"
+		e.Title = "Malformed request body"
+		e.Detail = describeMalformed(err, decoder.InputOffset()-int64(len(raw)))
+		return nil, e
+	}
+
+	if _, ok := members["atomic:operations"]; !ok || len(document.Operations) == 0 {
+		e := core.MakeError(http.StatusBadRequest)
+		e.Code = "5d61f0"
+		e.Title = "Bad request"
+		e.Detail = "Document must contain a non-empty atomic:operations member"
+		e.Source = &core.SourceObject{Pointer: "/atomic:operations"}
+		return nil, e
+	}
+
+	return document.Operations, nil
+}
+
+// Begin a new transaction (*Tx) and perform each of operations, in order,
+// with all or none committed to the graph.
+func PostOperations(ctx context.Context, g graph.Graph, s *schema.Resources, operations []Operation, h url.URL, q QueryParams, soft bool) ([]Result, []*core.Error) {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "e1b7d3"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return nil, []*core.Error{errObj}
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return nil, []*core.Error{errObj}
+	}
+
+	results, errObjs := tx.PostOperations(s, operations, h, q, soft)
+	if errObjs != nil {
+		return nil, errObjs
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "7c3fa0"
+		errObj.Title = "Encountered internal error while committing graph transaction"
+		errObj.Detail = err.Error()
+		return nil, []*core.Error{errObj}
+	}
+
+	return results, nil
+}
+
+// Perform each of operations, in order, returning their results.  Errors
+// point to the operation at fault.  Resources removed are tombstoned if
+// soft.
+func (tx *Tx) PostOperations(s *schema.Resources, operations []Operation, h url.URL, q QueryParams, soft bool) ([]Result, []*core.Error) {
+
+	var (
+		lids    = make(map[string]core.Resource)
+		results = make([]Result, 0, len(operations))
+	)
+
+	for n, operation := range operations {
+
+		result, errObjs := tx.PostOperation(s, operation, lids, h, q, soft)
+		if errObjs != nil {
+			for _, errObj := range errObjs {
+				if errObj.Source == nil {
+					errObj.Source = &core.SourceObject{}
+				}
+				errObj.Source.Pointer = fmt.Sprintf("/atomic:operations/%d%s", n, errObj.Source.Pointer)
+			}
+			return nil, errObjs
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Perform operation, with lid references resolved through lids, to which
+// any lid of a resource added by the operation is itself added.
+func (tx *Tx) PostOperation(s *schema.Resources, operation Operation, lids map[string]core.Resource, h url.URL, q QueryParams, soft bool) (Result, []*core.Error) {
+
+	var result Result
+
+	target, errObj := operation.target(lids, h)
+	if errObj != nil {
+		return result, []*core.Error{errObj}
+	}
+
+	var document *core.Document
+
+	if operation.Data != nil {
+		data, errObj := resolveData(operation.Data, target.Relationship == "" && operation.Op == "add", lids)
+		if errObj != nil {
+			return result, []*core.Error{errObj}
+		}
+
+		var errObjs []*core.Error
+		document, errObjs = Decode(bytes.NewReader(data))
+		if errObjs != nil {
+			return result, errObjs
+		}
+	} else if operation.Op != "remove" || target.Relationship != "" {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "0b8e4d"
+		errObj.Title = "Bad request"
+		errObj.Detail = fmt.Sprintf("Operation %s must contain a data member", operation.Op)
+		return result, []*core.Error{errObj}
+	}
+
+	switch {
+
+	case operation.Op == "add" && target.Relationship == "":
+
+		resource, ok := document.Data.(core.Resource)
+		if !ok {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "c7f5a1"
+			errObj.Title = "Bad request"
+			errObj.Detail = "Unable to assert data member as resource"
+			return result, []*core.Error{errObj}
+		}
+
+		if target.Type == "" {
+			target.Type = resource.Type
+		}
+
+		identifier, errObjs := tx.PostResource(s, target.Type, document)
+		if errObjs != nil {
+			return result, errObjs
+		}
+
+		return tx.operationResult(identifier.Type, identifier.Identifier, h, q)
+
+	case operation.Op == "update" && target.Relationship == "":
+
+		resource, ok := document.Data.(core.Resource)
+		if !ok {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "8f02b6"
+			errObj.Title = "Bad request"
+			errObj.Detail = "Unable to assert data member as resource"
+			return result, []*core.Error{errObj}
+		}
+
+		if target.Type == "" && target.Identifier == "" {
+			target.Type, target.Identifier = resource.Type, resource.Identifier
+		}
+
+		errObjs := tx.PatchResource(s, target.Type, target.Identifier, nil, document)
+		if errObjs != nil {
+			return result, errObjs
+		}
+
+		return tx.operationResult(target.Type, target.Identifier, h, q)
+
+	case operation.Op == "remove" && target.Relationship == "":
+
+		if target.Identifier == "" {
+			break
+		}
+
+		return result, errorList(tx.DeleteResource(target.Type, target.Identifier, nil, soft))
+
+	case operation.Op == "add":
+
+		return result, errorList(tx.PostRelationship(target.Type, target.Identifier, target.Relationship, document))
+
+	case operation.Op == "update":
+
+		return result, errorList(tx.PatchRelationship(target.Type, target.Identifier, target.Relationship, document))
+
+	case operation.Op == "remove":
+
+		return result, errorList(tx.DeleteRelationship(target.Type, target.Identifier, target.Relationship, document))
+
+	}
+
+	errObj = core.MakeError(http.StatusBadRequest)
+	errObj.Code = "f4d9c2"
+	errObj.Title = "Bad request"
+	errObj.Detail = fmt.Sprintf("Unsupported operation %q on target", operation.Op)
+	errObj.Source = &core.SourceObject{Pointer: "/op"}
+	return result, []*core.Error{errObj}
+}
+
+// Returns errObj as a list of error objects, being nil if errObj is nil.
+func errorList(errObj *core.Error) []*core.Error {
+
+	if errObj == nil {
+		return nil
+	}
+
+	return []*core.Error{errObj}
+}
+
+// Returns the result of an operation that added or updated the resource of
+// type t and identifier i.
+func (tx *Tx) operationResult(t, i string, h url.URL, q QueryParams) (Result, []*core.Error) {
+
+	var result Result
+
+	document, errObj := tx.GetResource(t, i, h, q)
+	if errObj != nil {
+		return result, []*core.Error{errObj}
+	}
+
+	result.Data = document.Data
+
+	return result, nil
+}
+
+// Returns the target of operation, from either of its ref or href members,
+// with any lid resolved through lids.  The target of an operation to add
+// a resource is empty but for, possibly, the type.
+func (operation Operation) target(lids map[string]core.Resource, h url.URL) (Ref, *core.Error) {
+
+	var target Ref
+
+	switch {
+
+	case operation.Ref != nil && operation.Href != "":
+
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "3a8d57"
+		errObj.Title = "Bad request"
+		errObj.Detail = "Operation must not contain both ref and href members"
+		return target, errObj
+
+	case operation.Ref != nil:
+
+		target = *operation.Ref
+
+		if target.Local != "" {
+			resource, ok := lids[target.Local]
+			if !ok || resource.Type != target.Type {
+				errObj := core.MakeError(http.StatusBadRequest)
+				errObj.Code = "d6b1e8"
+				errObj.Title = "Bad request"
+				errObj.Detail = fmt.Sprintf("Unknown lid %q for type %s", target.Local, target.Type)
+				errObj.Source = &core.SourceObject{Pointer: "/ref/lid"}
+				return target, errObj
+			}
+			target.Identifier, target.Local = resource.Identifier, ""
+		}
+
+	case operation.Href != "":
+
+		u, err := h.Parse(operation.Href)
+		if err != nil || !strings.HasPrefix(u.Path, h.Path) {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "95c0af"
+			errObj.Title = "Bad request"
+			errObj.Detail = fmt.Sprintf("Unable to resolve href %q", operation.Href)
+			errObj.Source = &core.SourceObject{Pointer: "/href"}
+			return target, errObj
+		}
+
+		segments := strings.Split(strings.Trim(strings.TrimPrefix(u.Path, h.Path), "/"), "/")
+
+		switch {
+		case len(segments) == 1:
+			target.Type = segments[0]
+		case len(segments) == 2:
+			target.Type, target.Identifier = segments[0], segments[1]
+		case len(segments) == 4 && segments[2] == "relationships":
+			target.Type, target.Identifier, target.Relationship = segments[0], segments[1], segments[3]
+		default:
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "e0a4b0"
+			errObj.Title = "Bad request"
+			errObj.Detail = fmt.Sprintf("Unable to resolve href %q", operation.Href)
+			errObj.Source = &core.SourceObject{Pointer: "/href"}
+			return target, errObj
+		}
+
+	}
+
+	if target.Relationship != "" && (target.Type == "" || target.Identifier == "") {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "61e7b9"
+		errObj.Title = "Bad request"
+		errObj.Detail = "Target of relationship operation must identify a resource"
+		return target, errObj
+	}
+
+	return target, nil
+}
+
+// Returns a document with data as its data member, with lid references in
+// resource identifier objects replaced by the id of the resource added with
+// that lid.  If adding, data is a resource whose lid (if any) is assigned
+// an id (if none given) and added to lids.
+func resolveData(data json.RawMessage, adding bool, lids map[string]core.Resource) ([]byte, *core.Error) {
+
+	var v interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&v)
+	if err != nil {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "c11c00"
+		errObj.Detail = err.Error()
+		errObj.Source = &core.SourceObject{Pointer: "/data"}
+		return nil, errObj
+	}
+
+	resolve := func(v interface{}) *core.Error {
+
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		lid, ok := object["lid"].(string)
+		if !ok {
+			return nil
+		}
+
+		t, _ := object["type"].(string)
+
+		resource, ok := lids[lid]
+		if !ok || resource.Type != t {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "bad86f"
+			errObj.Title = "Bad request"
+			errObj.Detail = fmt.Sprintf("Unknown lid %q for type %s", lid, t)
+			errObj.Source = &core.SourceObject{Pointer: "/data"}
+			return errObj
+		}
+
+		object["id"] = resource.Identifier
+		delete(object, "lid")
+
+		return nil
+	}
+
+	resolveAll := func(v interface{}) *core.Error {
+
+		if collection, ok := v.([]interface{}); ok {
+			for _, w := range collection {
+				if errObj := resolve(w); errObj != nil {
+					return errObj
+				}
+			}
+			return nil
+		}
+
+		return resolve(v)
+	}
+
+	if !adding {
+
+		// Resource identifier objects as data, or a resource to update
+		errObj := resolveAll(v)
+		if errObj != nil {
+			return nil, errObj
+		}
+
+	} else if object, ok := v.(map[string]interface{}); ok {
+
+		if lid, ok := object["lid"].(string); ok {
+
+			if _, ok := lids[lid]; ok {
+				errObj := core.MakeError(http.StatusBadRequest)
+				errObj.Code = "2e5f9a"
+				errObj.Title = "Bad request"
+				errObj.Detail = fmt.Sprintf("Duplicate lid %q", lid)
+				errObj.Source = &core.SourceObject{Pointer: "/data/lid"}
+				return nil, errObj
+			}
+
+			id, ok := object["id"].(string)
+			if !ok {
+				id = xid.New().String()
+			}
+
+			t, _ := object["type"].(string)
+
+			lids[lid] = core.Resource{Type: t, Identifier: id}
+			object["id"] = id
+			delete(object, "lid")
+		}
+
+	}
+
+	// Resource identifier objects within relationships of a resource
+	if object, ok := v.(map[string]interface{}); ok {
+		relationships, _ := object["relationships"].(map[string]interface{})
+		for _, relationship := range relationships {
+			if relationship, ok := relationship.(map[string]interface{}); ok {
+				errObj := resolveAll(relationship["data"])
+				if errObj != nil {
+					return nil, errObj
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"data": v})
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "4b7e2c"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	return b, nil
+}
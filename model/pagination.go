@@ -3,8 +3,10 @@ package model
 import (
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
 func maxInt64(a, b int64) int64 {
@@ -21,9 +23,16 @@ func minInt64(a, b int64) int64 {
 	return b
 }
 
-func makePaginationLink(base url.URL, ref *url.URL, limit, offset int64) string {
+// Returns a link to the page at offset, carrying over any query parameters
+// in query other than those for pagination.
+func makePaginationLink(base url.URL, ref *url.URL, query url.Values, limit, offset int64) string {
 
 	v := url.Values{}
+	for par, entries := range query {
+		if !strings.HasPrefix(par, "page[") {
+			v[par] = entries
+		}
+	}
 	v.Set("page[limit]", strconv.FormatInt(limit, 10))
 	v.Set("page[offset]", strconv.FormatInt(offset, 10))
 
@@ -32,19 +41,119 @@ func makePaginationLink(base url.URL, ref *url.URL, limit, offset int64) string
 	return base.ResolveReference(ref).String()
 }
 
-func paginate(collection core.Collection, base url.URL, ref *url.URL, limit, offset, count int64) (core.Collection, core.LinksObject) {
+func paginate(collection core.Collection, base url.URL, ref *url.URL, query url.Values, limit, offset, count int64) (core.Collection, core.LinksObject) {
 
 	links := make(core.LinksObject)
-	links["first"] = makePaginationLink(base, ref, limit, 0)
-	links["last"] = makePaginationLink(base, ref, limit, maxInt64(0, count-(count-offset%limit)%limit))
+	links["first"] = makePaginationLink(base, ref, query, limit, 0)
+	links["last"] = makePaginationLink(base, ref, query, limit, maxInt64(0, count-(count-offset%limit)%limit))
 	if offset > 0 {
-		links["prev"] = makePaginationLink(base, ref, limit, maxInt64(0, offset-limit))
+		links["prev"] = makePaginationLink(base, ref, query, limit, maxInt64(0, offset-limit))
 	}
 	if (offset + limit) < count {
-		links["next"] = makePaginationLink(base, ref, limit, offset+limit)
+		links["next"] = makePaginationLink(base, ref, query, limit, offset+limit)
 	}
-	links["self"] = makePaginationLink(base, ref, limit, offset)
+	links["self"] = makePaginationLink(base, ref, query, limit, offset)
 
 	return collection, links
 
 }
+
+// Returns the page of the graph requested in q.  A page by cursor is one
+// longer than requested, so that its surplus member shows whether there are
+// more beyond it.
+func (q QueryParams) page() graph.Page {
+
+	if !q.Cursor {
+		return graph.Page{Limit: q.Limit, Offset: q.Offset}
+	}
+
+	return graph.Page{
+		Limit:  minInt64(q.Limit, 1<<63-2) + 1,
+		After:  q.After,
+		Before: q.Before,
+	}
+}
+
+// Returns the parameter with the cursor in q, for reporting errors.
+func (q QueryParams) cursorParameter() string {
+
+	if q.Before != "" {
+		return "page[before]"
+	}
+
+	return "page[after]"
+}
+
+// Returns the bounds of the members to keep, of n found for the page
+// requested in q, and whether there are more beyond the page.  Members are dropped
+// from the start of a page that precedes a cursor, else from the end.
+func (q QueryParams) trim(n int) (from, to int, more bool) {
+
+	if !q.Cursor || int64(n) <= q.Limit {
+		return 0, n, false
+	}
+
+	if q.Before != "" {
+		return n - int(q.Limit), n, true
+	}
+
+	return 0, int(q.Limit), true
+}
+
+// Returns a link to the page of size following (par page[after]) or
+// preceding (par page[before]) cursor, or the first page if par is empty,
+// carrying over any query parameters in query other than those for
+// pagination.
+func makeCursorLink(base url.URL, ref *url.URL, query url.Values, size int64, par, cursor string) string {
+
+	v := url.Values{}
+	for par, entries := range query {
+		if !strings.HasPrefix(par, "page[") {
+			v[par] = entries
+		}
+	}
+	v.Set("page[size]", strconv.FormatInt(size, 10))
+	if par != "" {
+		v.Set(par, cursor)
+	}
+
+	ref.RawQuery = v.Encode()
+
+	return base.ResolveReference(ref).String()
+}
+
+// Returns links for a page of a collection paginated by cursor, per q, where
+// first and last are the cursors of the first and last members of the page
+// and more reports whether there are members beyond the page, being after
+// it or, when paging by page[before], before it.
+func paginateByCursor(collection core.Collection, base url.URL, ref *url.URL, query url.Values, q QueryParams, first, last string, more bool) (core.Collection, core.LinksObject) {
+
+	links := make(core.LinksObject)
+	links["first"] = makeCursorLink(base, ref, query, q.Limit, "", "")
+
+	switch {
+	case q.Before != "":
+		links["self"] = makeCursorLink(base, ref, query, q.Limit, "page[before]", q.Before)
+		if more {
+			links["prev"] = makeCursorLink(base, ref, query, q.Limit, "page[before]", first)
+		}
+		if last != "" {
+			links["next"] = makeCursorLink(base, ref, query, q.Limit, "page[after]", last)
+		}
+	case q.After != "":
+		links["self"] = makeCursorLink(base, ref, query, q.Limit, "page[after]", q.After)
+		if first != "" {
+			links["prev"] = makeCursorLink(base, ref, query, q.Limit, "page[before]", first)
+		}
+		if more {
+			links["next"] = makeCursorLink(base, ref, query, q.Limit, "page[after]", last)
+		}
+	default:
+		links["self"] = makeCursorLink(base, ref, query, q.Limit, "", "")
+		if more {
+			links["next"] = makeCursorLink(base, ref, query, q.Limit, "page[after]", last)
+		}
+	}
+
+	return collection, links
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
+)
+
+// ETag returns the strong entity tag for a resource of revision.
+func ETag(revision int64) string {
+	return strconv.Quote(strconv.FormatInt(revision, 10))
+}
+
+// Returns the strong entity tag for a resource of revision, as represented
+// with fields.  A representation of sparse fieldsets differs from that of
+// every field, and so its tag is the revision with a hash of the fieldsets.
+func fieldsETag(revision int64, fields Fieldsets) string {
+
+	if len(fields) == 0 {
+		return ETag(revision)
+	}
+
+	types := make([]string, 0, len(fields))
+	for t := range fields {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	h := sha256.New()
+	for _, t := range types {
+		fieldset := append([]string{}, fields[t]...)
+		sort.Strings(fieldset)
+		fmt.Fprintf(h, "%q:%q;", t, fieldset)
+	}
+
+	return strconv.Quote(strconv.FormatInt(revision, 10) + "-" + hex.EncodeToString(h.Sum(nil)[:8]))
+}
+
+// Validators are those of the representation of a resource: its entity tag
+// and the time at which it was last modified, if known.
+type Validators struct {
+	ETag         string
+	LastModified time.Time
+}
+
+// Precondition is the entity tags of If-Match headers, one of which must
+// match the revision of a resource for a conditional write to proceed.  A
+// nil Precondition, as for a request without If-Match, always holds.
+type Precondition []string
+
+// ParsePrecondition returns the Precondition of the If-Match header values.
+func ParsePrecondition(values []string) Precondition {
+
+	var p Precondition
+
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				p = append(p, tag)
+			}
+		}
+	}
+
+	return p
+}
+
+// Reports whether a resource of revision meets the precondition, by strong
+// comparison: weak entity tags never match.  The tag of a representation of
+// sparse fieldsets matches as that of every field.
+func (p Precondition) matches(revision int64) bool {
+
+	if p == nil {
+		return true
+	}
+
+	variant := `"` + strconv.FormatInt(revision, 10) + "-"
+
+	for _, tag := range p {
+		if tag == "*" || tag == ETag(revision) || strings.HasPrefix(tag, variant) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verify that the resource of type t and identifier i, being of revision,
+// meets the precondition p.
+func (p Precondition) verify(t, i string, revision int64) *core.Error {
+
+	if !p.matches(revision) {
+		return preconditionFailed(t, i)
+	}
+
+	return nil
+}
+
+// Returns the error for a conditional write to the resource of type t and
+// identifier i, failing for want of a matching revision.
+func preconditionFailed(t, i string) *core.Error {
+
+	errObj := core.MakeError(http.StatusPreconditionFailed)
+	errObj.Code = "c4a1e2"
+	errObj.Detail = fmt.Sprintf("Resource %s/%s does not match If-Match", t, i)
+	return errObj
+}
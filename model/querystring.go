@@ -8,17 +8,50 @@ import (
 	"strings"
 
 	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
 )
 
 type QueryParams struct {
 	Limit   int64
 	Offset  int64
+	Cursor  bool   // Whether paginated by cursor, rather than offset
+	After   string // Cursor for page[after]
+	Before  string // Cursor for page[before]
 	Include KeyRing
-	Sort    string
+	Fields  Fieldsets
+	Filters []graph.Filter
+	Sort    []graph.SortKey
+	Values  url.Values // As parsed from the query string
+}
+
+// Fieldsets are the sparse fieldsets requested per resource type, keyed on
+// type, e.g., ?fields[people]=name,age
+type Fieldsets map[string][]string
+
+// Selects reports whether the fieldset for type t includes field f.  All
+// fields are selected for a type without a fieldset.
+func (fields Fieldsets) Selects(t, f string) bool {
+
+	fieldset, ok := fields[t]
+	if !ok {
+		return true
+	}
+
+	return stringInSlice(f, fieldset)
 }
 
 type Parameters map[string]Parameter
 
+// Cursor reports whether collections are paginated by cursor, with page[size],
+// page[after] and page[before], rather than by offset, with page[limit] and
+// page[offset].  The former is used if page[size] is allowed.  The two are
+// exclusive, so that each link of a collection is of one or the other, and
+// offset is the default of config.Parameters only so as not to refuse the
+// requests of existing clients.
+func (params Parameters) Cursor() bool {
+	return params["page[size]"].Allowed
+}
+
 type Parameter struct {
 	Allowed bool
 	Default int64
@@ -158,8 +191,10 @@ func ParseQueryString(u *url.URL, params Parameters) (QueryParams, *core.Error)
 		return queryParams, errObj
 	}
 
+	queryParams.Values = q
+
 	for par := range q {
-		if !params[par].Allowed {
+		if !params[family(par)].Allowed {
 			errObj = core.MakeError(http.StatusBadRequest)
 			errObj.Code = "6d01c2"
 			errObj.Title = "Invalid query string"
@@ -168,25 +203,80 @@ func ParseQueryString(u *url.URL, params Parameters) (QueryParams, *core.Error)
 		}
 	}
 
-	// Parse page[limit]
-	queryParams.Limit, errObj = ParseIntegerParameter(
-		"page[limit]", q, params,
-	)
+	if params.Cursor() {
+
+		queryParams.Cursor = true
+
+		// Parse page[size]
+		queryParams.Limit, errObj = ParseIntegerParameter(
+			"page[size]", q, params,
+		)
+		if errObj != nil {
+			return queryParams, errObj
+		}
+
+		// Parse page[after] and page[before]
+		queryParams.After, queryParams.Before, errObj = ParseCursors(q)
+		if errObj != nil {
+			return queryParams, errObj
+		}
+
+	} else {
+
+		// Parse page[limit]
+		queryParams.Limit, errObj = ParseIntegerParameter(
+			"page[limit]", q, params,
+		)
+		if errObj != nil {
+			return queryParams, errObj
+		}
+
+		// Parse page[offset]
+		queryParams.Offset, errObj = ParseIntegerParameter(
+			"page[offset]", q, params,
+		)
+		if errObj != nil {
+			return queryParams, errObj
+		}
+
+	}
+
+	// Parse sort
+	queryParams.Sort, errObj = ParseSort(q)
 	if errObj != nil {
 		return queryParams, errObj
 	}
 
-	// Parse page[offset]
-	queryParams.Offset, errObj = ParseIntegerParameter(
-		"page[offset]", q, params,
-	)
+	// Parse fields
+	for par, entries := range q {
+
+		if family(par) != "fields" {
+			continue
+		}
+
+		t := strings.TrimSuffix(strings.TrimPrefix(par, "fields["), "]")
+
+		if queryParams.Fields == nil {
+			queryParams.Fields = make(Fieldsets)
+		}
+
+		fieldset := []string{}
+		for _, entry := range entries {
+			for _, f := range strings.Split(entry, ",") {
+				if f != "" {
+					fieldset = append(fieldset, f)
+				}
+			}
+		}
+		queryParams.Fields[t] = fieldset
+	}
+
+	// Parse filter
+	queryParams.Filters, errObj = ParseFilters(q)
 	if errObj != nil {
 		return queryParams, errObj
 	}
 
-	// Parse sort
-	queryParams.Sort = q.Get("sort")
-
 	// Parse include
 	set := map[string]bool{}
 
@@ -211,6 +301,57 @@ func ParseQueryString(u *url.URL, params Parameters) (QueryParams, *core.Error)
 	return queryParams, nil
 }
 
+// Parses the cursors of page[after] and page[before], of which at most one
+// may be given.  Cursors are opaque here and are validated by the graph.
+func ParseCursors(q url.Values) (string, string, *core.Error) {
+
+	var cursors [2]string
+
+	for n, parameter := range []string{"page[after]", "page[before]"} {
+
+		entries := q[parameter]
+		if len(entries) > 1 {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "3c7e0a"
+			errObj.Title = "Invalid query string"
+			errObj.Detail = fmt.Sprintf("Superfluous parameter: %s", parameter)
+			errObj.Source = &core.SourceObject{Parameter: parameter}
+			return "", "", errObj
+		} else if len(entries) == 1 {
+			cursors[n] = entries[0]
+		}
+	}
+
+	if cursors[0] != "" && cursors[1] != "" {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "e58d21"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = "Parameters page[after] and page[before] are mutually exclusive"
+		errObj.Source = &core.SourceObject{Parameter: "page[before]"}
+		return "", "", errObj
+	}
+
+	return cursors[0], cursors[1], nil
+}
+
+// Returns the family of a query parameter, being the name of the parameter
+// up to any opening bracket: fields[people] is of family fields.  Parameters
+// that are not members of a family are returned unchanged.
+func family(parameter string) string {
+
+	i := strings.Index(parameter, "[")
+	if i < 1 || !strings.HasSuffix(parameter, "]") {
+		return parameter
+	}
+
+	switch name := parameter[:i]; name {
+	case "fields", "filter":
+		return name
+	}
+
+	return parameter
+}
+
 func stringInSlice(a string, b []string) bool {
 	for _, c := range b {
 		if c == a {
@@ -26,9 +26,14 @@ func GetRelated(ctx context.Context, g graph.Graph, t, i, k string, h url.URL, q
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
-	document, errObj := tx.GetRelated(t, i, k, h, q)
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return document, errObj
+	}
+
+	document, errObj = tx.GetRelated(t, i, k, h, q)
 	if errObj != nil {
 		return document, errObj
 	}
@@ -40,7 +45,33 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 
 	var document *core.Document = &core.Document{}
 
-	count, err := tx.CountRelatedVertices(t, i, k)
+	cardinality, errObj := tx.FindRelationship(t, i, k)
+	if errObj != nil {
+		return document, errObj
+	}
+
+	// A to-one relationship has at most one member, and is not paginated
+	if cardinality == graph.ToOne {
+		return tx.getToOneRelated(t, i, k, h, q)
+	}
+
+	if len(q.Sort) > 0 {
+		attributeKeys, err := tx.FindRelatedAttributeKeys(t, i, k)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "8d2e64"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return document, errObj
+		}
+
+		errObj := validateSort(attributeKeys, q)
+		if errObj != nil {
+			return document, errObj
+		}
+	}
+
+	count, err := tx.CountRelatedVertices(t, i, k, q.Filters)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "e9bf2d"
@@ -51,15 +82,16 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 
 	collection := make(core.Collection, 0, count)
 
-	if count == 0 {
-		errObj := core.MakeError(http.StatusNotFound)
-		errObj.Code = "9fbdd5"
-		return document, errObj
-	}
-
 	var edges []Edge
-	edges, err = tx.FindEdges(t, i, k, q.Limit, q.Offset)
-	if err != nil {
+	edges, err = tx.FindEdges(t, i, k, q.Filters, q.Sort, q.page())
+	if err == graph.ErrInvalidCursor {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "c41f8b"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = err.Error()
+		errObj.Source = &core.SourceObject{Parameter: q.cursorParameter()}
+		return document, errObj
+	} else if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "9a8ffa"
 		errObj.Title = "Encountered internal error while querying graph"
@@ -67,6 +99,9 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 		return document, errObj
 	}
 
+	from, to, more := q.trim(len(edges))
+	edges = edges[from:to]
+
 	for _, edge := range edges {
 
 		identifier := core.Resource{
@@ -105,7 +140,7 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 			return document, errObj
 		}
 
-		data.Meta = identifier.Meta
+		data.Meta = stamp(identifier.Meta, edge.Created, edge.Updated)
 
 		collection = append(collection, data)
 
@@ -126,10 +161,29 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 		return document, errObj
 	}
 
+	if q.Cursor {
+		var first, last string
+		if len(edges) > 0 {
+			first, last = edges[0].Cursor, edges[len(edges)-1].Cursor
+		}
+		_, document.Links = paginateByCursor(
+			collection,
+			h,
+			ref,
+			q.Values,
+			q,
+			first,
+			last,
+			more,
+		)
+		return document, nil
+	}
+
 	_, document.Links = paginate(
 		collection,
 		h,
 		ref,
+		q.Values,
 		q.Limit,
 		q.Offset,
 		count,
@@ -137,3 +191,63 @@ func (tx *Tx) GetRelated(t, i, k string, h url.URL, q QueryParams) (*core.Docume
 
 	return document, nil
 }
+
+// Get the resource in the to-one relationship keyed by k for the resource of
+// type t and identifier i, having data of that resource or null if empty.
+func (tx *Tx) getToOneRelated(t, i, k string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
+
+	var document *core.Document = &core.Document{}
+
+	edges, err := tx.FindEdges(t, i, k, q.Filters, nil, graph.Page{Limit: 1})
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "b5e27a"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return document, errObj
+	}
+
+	if len(edges) == 0 {
+		document.Data = null{}
+		return document, nil
+	}
+
+	resource, errObj := tx.GetResource(
+		edges[0].To.Type,
+		edges[0].To.Identifier,
+		h,
+		q,
+	)
+	if errObj != nil {
+		return document, errObj
+	}
+
+	data, ok := resource.Data.(core.Resource)
+	if !ok {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "3ad9f0"
+		errObj.Title = "Type assertion failed"
+		errObj.Detail = fmt.Sprintf(
+			"Interface of type %T is not Resource",
+			resource.Data,
+		)
+		return document, errObj
+	}
+
+	var meta map[string]interface{}
+	err = json.Unmarshal(edges[0].Meta, &meta)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "c7e1d4"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return document, errObj
+	}
+
+	data.Meta = stamp(meta, edges[0].Created, edges[0].Updated)
+
+	document.Data = data
+	document.Included = resource.Included
+
+	return document, nil
+}
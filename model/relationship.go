@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 
 	"github.com/wamuir/go-jsonapi-core"
 	"github.com/wamuir/go-jsonapi-server/graph"
@@ -14,7 +15,7 @@ import (
 
 func DeleteRelationship(ctx context.Context, g graph.Graph, t, i, k string, d *core.Document) *core.Error {
 
-	transaction, err := g.Transaction(ctx, true)
+	transaction, err := g.Transaction(ctx, false)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "8c58b4"
@@ -24,13 +25,22 @@ func DeleteRelationship(ctx context.Context, g graph.Graph, t, i, k string, d *c
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
 	errObj := tx.DeleteRelationship(t, i, k, d)
 	if errObj != nil {
 		return errObj
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "24e0eb"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
 	return nil
 }
 
@@ -87,9 +97,14 @@ func GetRelationship(ctx context.Context, g graph.Graph, t, i, k string, h url.U
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
-	document, errObj := tx.GetRelationship(t, i, k, h, q)
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return document, errObj
+	}
+
+	document, errObj = tx.GetRelationship(t, i, k, h, q)
 	if errObj != nil {
 		return document, errObj
 	}
@@ -101,8 +116,18 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 
 	var document *core.Document = &core.Document{}
 
+	cardinality, errObj := tx.FindRelationship(t, i, k)
+	if errObj != nil {
+		return document, errObj
+	}
+
+	// A to-one relationship has at most one member, and is not paginated
+	if cardinality == graph.ToOne {
+		return tx.getToOneRelationship(t, i, k, h, q)
+	}
+
 	// Count of related vertices is needed for pagination
-	count, err := tx.CountRelatedVertices(t, i, k)
+	count, err := tx.CountRelatedVertices(t, i, k, nil)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "f3bc34"
@@ -114,8 +139,15 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 	collection := make(core.Collection, 0, count)
 
 	var edges []Edge
-	edges, err = tx.FindEdges(t, i, k, q.Limit, q.Offset)
-	if err != nil {
+	edges, err = tx.FindEdges(t, i, k, nil, nil, q.page())
+	if err == graph.ErrInvalidCursor {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "5b9d06"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = err.Error()
+		errObj.Source = &core.SourceObject{Parameter: q.cursorParameter()}
+		return document, errObj
+	} else if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "38440d"
 		errObj.Title = "Encountered internal error while querying graph"
@@ -123,6 +155,9 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 		return document, errObj
 	}
 
+	from, to, more := q.trim(len(edges))
+	edges = edges[from:to]
+
 	for _, edge := range edges {
 
 		resource := core.Resource{
@@ -139,24 +174,15 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 			return document, errObj
 		}
 
+		resource.Meta = stamp(resource.Meta, edge.Created, edge.Updated)
+
 		collection = append(collection, resource)
 
 	}
 
-	switch {
-
-	case count == 0:
-
-		errObj := core.MakeError(http.StatusNotFound)
-		errObj.Code = "9fbdd5"
-		return document, errObj
-
-	case count == 1:
-
-		identifier := collection[0]
-
-		if q.Include.Requests(k) {
-			q.Include = q.Include.SplitOn(k)
+	if q.Include.Requests(k) {
+		q.Include = q.Include.SplitOn(k)
+		for _, identifier := range collection {
 			resource, errObj := tx.GetResource(
 				identifier.Type,
 				identifier.Identifier,
@@ -170,7 +196,7 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 			data, ok := resource.Data.(core.Resource)
 			if !ok {
 				errObj := core.MakeError(http.StatusInternalServerError)
-				errObj.Code = "f8f4c4"
+				errObj.Code = "f7c124"
 				errObj.Title = "Type assertion failed"
 				errObj.Detail = fmt.Sprintf(
 					"Interface of type %T is not Resource",
@@ -182,66 +208,230 @@ func (tx *Tx) GetRelationship(t, i, k string, h url.URL, q QueryParams) (*core.D
 			document.Included = document.Included.MergeResource(data)
 			document.Included = document.Included.Merge(resource.Included)
 		}
+	}
 
-		document.Data = identifier
+	document.Data = collection
 
-	case count >= 2:
+	ref, err := url.Parse(
+		path.Join(t, i, "relationships", k),
+	)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "1d385a"
+		errObj.Title = "Encountered internal error while generating response"
+		errObj.Detail = err.Error()
+		return document, errObj
+	}
 
-		if q.Include.Requests(k) {
-			q.Include = q.Include.SplitOn(k)
-			for _, identifier := range collection {
-				resource, errObj := tx.GetResource(
-					identifier.Type,
-					identifier.Identifier,
-					h,
-					q,
-				)
-				if errObj != nil {
-					return document, errObj
-				}
-
-				data, ok := resource.Data.(core.Resource)
-				if !ok {
-					errObj := core.MakeError(http.StatusInternalServerError)
-					errObj.Code = "f7c124"
-					errObj.Title = "Type assertion failed"
-					errObj.Detail = fmt.Sprintf(
-						"Interface of type %T is not Resource",
-						resource.Data,
-					)
-					return document, errObj
-				}
-
-				document.Included = document.Included.MergeResource(data)
-				document.Included = document.Included.Merge(resource.Included)
-			}
+	if q.Cursor {
+		var first, last string
+		if len(edges) > 0 {
+			first, last = edges[0].Cursor, edges[len(edges)-1].Cursor
 		}
+		_, document.Links = paginateByCursor(
+			collection,
+			h,
+			ref,
+			nil,
+			q,
+			first,
+			last,
+			more,
+		)
+		return document, nil
+	}
+
+	_, document.Links = paginate(
+		collection,
+		h,
+		ref,
+		nil,
+		q.Limit,
+		q.Offset,
+		count,
+	)
+
+	return document, nil
+}
+
+// Get the to-one relationship keyed by k for the resource of type t and
+// identifier i, having data of a resource identifier or null if empty.
+func (tx *Tx) getToOneRelationship(t, i, k string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
+
+	var document *core.Document = &core.Document{}
+
+	edges, err := tx.FindEdges(t, i, k, nil, nil, graph.Page{Limit: 1})
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "2ee724"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return document, errObj
+	}
+
+	if len(edges) == 0 {
+		document.Data = null{}
+		return document, nil
+	}
 
-		document.Data = collection
+	identifier := core.Resource{
+		Type:       edges[0].To.Type,
+		Identifier: edges[0].To.Identifier,
+	}
 
-		ref, err := url.Parse(
-			path.Join(t, i, "relationships", k),
+	err = json.Unmarshal(edges[0].Meta, &identifier.Meta)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "e02d8b"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return document, errObj
+	}
+
+	identifier.Meta = stamp(identifier.Meta, edges[0].Created, edges[0].Updated)
+
+	if q.Include.Requests(k) {
+		q.Include = q.Include.SplitOn(k)
+		resource, errObj := tx.GetResource(
+			identifier.Type,
+			identifier.Identifier,
+			h,
+			q,
 		)
-		if err != nil {
+		if errObj != nil {
+			return document, errObj
+		}
+
+		data, ok := resource.Data.(core.Resource)
+		if !ok {
 			errObj := core.MakeError(http.StatusInternalServerError)
-			errObj.Code = "1d385a"
-			errObj.Title = "Encountered internal error while generating response"
-			errObj.Detail = err.Error()
+			errObj.Code = "f8f4c4"
+			errObj.Title = "Type assertion failed"
+			errObj.Detail = fmt.Sprintf(
+				"Interface of type %T is not Resource",
+				resource.Data,
+			)
 			return document, errObj
 		}
 
-		_, document.Links = paginate(
-			collection,
-			h,
-			ref,
-			q.Limit,
-			q.Offset,
-			count,
+		document.Included = document.Included.MergeResource(data)
+		document.Included = document.Included.Merge(resource.Included)
+	}
+
+	document.Data = identifier
+
+	return document, nil
+}
+
+// Verify that the resource of type t and identifier i exists and that the
+// relationship keyed by k is known for resources of type t, whether or not
+// that resource has any members in the relationship.  Returns the declared
+// cardinality of the relationship, if any.
+func (tx *Tx) FindRelationship(t, i, k string) (graph.Cardinality, *core.Error) {
+
+	_, err := tx.FindVertex(t, i)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "9fbdd5"
+		return "", errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "c8e1a4"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return "", errObj
+	}
+
+	cardinality, err := tx.FindEdgeKey(t, k)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "e4a3f0"
+		errObj.Detail = fmt.Sprintf("Relationship %s is unknown for type %s", k, t)
+		return "", errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "27b5d9"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return "", errObj
+	}
+
+	return cardinality, nil
+}
+
+// Declare the relationship keyed by k for resources of type t as of the
+// given cardinality, as by the shape of a data member written to it.  The
+// first declaration holds, so that writing a data member of the other shape
+// is a bad request.
+func (tx *Tx) DeclareRelationship(t, k string, cardinality graph.Cardinality) *core.Error {
+
+	err := tx.DeclareEdgeKey(t, k, cardinality)
+	if err == graph.ErrConflict {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "d3a7c1"
+		errObj.Title = "Bad Request"
+		errObj.Detail = fmt.Sprintf(
+			"Relationship %s for type %s is not %s",
+			k,
+			t,
+			describeCardinality(cardinality),
 		)
+		return errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "5c81e9"
+		errObj.Title = "Encountered internal error while inserting into graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	return nil
+}
 
+// DeclareRelationships declares the cardinality of relationships, keyed
+// by resource type and then by relationship key, before any is written.
+func DeclareRelationships(ctx context.Context, g graph.Graph, relationships map[string]map[string]graph.Cardinality) *core.Error {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "0b6f4d"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return errObj
 	}
+	defer transaction.Close()
 
-	return document, nil
+	var tx *Tx = &Tx{transaction, g}
+
+	for t, keys := range relationships {
+		for k, cardinality := range keys {
+			errObj := tx.DeclareRelationship(t, k, cardinality)
+			if errObj != nil {
+				return errObj
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "96e2a0"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	return nil
+}
+
+func describeCardinality(cardinality graph.Cardinality) string {
+
+	if cardinality == graph.ToOne {
+		return "to-one"
+	}
+
+	return "to-many"
 }
 
 func PostRelationship(ctx context.Context, g graph.Graph, t, i, k string, document *core.Document) *core.Error {
@@ -256,7 +446,7 @@ func PostRelationship(ctx context.Context, g graph.Graph, t, i, k string, docume
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
 	errObj := tx.PostRelationship(t, i, k, document)
 	if errObj != nil {
@@ -275,46 +465,33 @@ func PostRelationship(ctx context.Context, g graph.Graph, t, i, k string, docume
 	return nil
 }
 
+// Add the members in document to the relationship keyed by k, for the
+// resource of type t and identifier i.  A resource object declares a to-one
+// relationship, and replaces any member, while an array declares a to-many.
 func (tx *Tx) PostRelationship(t, i, k string, document *core.Document) *core.Error {
 
-	var collection []core.Resource
-
-	m, err := decodeDataMbr(document.Data)
-	if err != nil {
-		return err
+	collection, cardinality, errObj := relationshipMembers(document)
+	if errObj != nil {
+		return errObj
 	}
 
-	switch v := m.(type) {
-	// switch v := document.Data.(type) {
-
-	case core.Collection:
-		collection = v
-
-	case core.Resource:
-		collection = []core.Resource{v}
-
-	default:
-		errObj := core.MakeError(http.StatusBadRequest)
-		errObj.Code = "cebc7c"
-		errObj.Title = "Bad Request"
-		errObj.Detail = "Unable to assert data member as collection or resource"
+	errObj = tx.DeclareRelationship(t, k, cardinality)
+	if errObj != nil {
 		return errObj
+	}
 
+	if cardinality == graph.ToOne {
+		return tx.replaceEdges(t, i, k, collection)
 	}
 
 	for pos, related := range collection {
 
-		// Marshal meta member
-		meta, err := json.Marshal(related.Meta)
-		if err != nil {
-			errObj := core.MakeError(http.StatusInternalServerError)
-			errObj.Code = "b1474d"
-			errObj.Title = "Encountered internal error while transforming data"
-			errObj.Detail = err.Error()
+		meta, errObj := edgeMeta(related)
+		if errObj != nil {
 			return errObj
 		}
 
-		err = tx.InsertEdge(
+		err := tx.InsertEdge(
 			t,
 			i,
 			related.Type,
@@ -340,3 +517,235 @@ func (tx *Tx) PostRelationship(t, i, k string, document *core.Document) *core.Er
 
 	return nil
 }
+
+// Return the members in the data member of document, with the cardinality
+// they declare: to-one for a resource object and to-many for an array.
+func relationshipMembers(document *core.Document) ([]core.Resource, graph.Cardinality, *core.Error) {
+
+	m, err := decodeDataMbr(document.Data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch v := m.(type) {
+
+	case core.Collection:
+		return v, graph.ToMany, nil
+
+	case core.Resource:
+		return []core.Resource{v}, graph.ToOne, nil
+
+	}
+
+	errObj := core.MakeError(http.StatusBadRequest)
+	errObj.Code = "cebc7c"
+	errObj.Title = "Bad Request"
+	errObj.Detail = "Unable to assert data member as collection or resource"
+	return nil, "", errObj
+}
+
+// Return the meta member of related, as stored on the edge to it.
+func edgeMeta(related core.Resource) ([]byte, *core.Error) {
+
+	unstamp(related.Meta)
+	meta, err := json.Marshal(related.Meta)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "b1474d"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return nil, errObj
+	}
+
+	return meta, nil
+}
+
+// Replace the members of the relationship keyed by k, for the resource of
+// type t and identifier i, with collection, in its order.  Only the edges
+// that differ are written: those to members no longer in collection are
+// deleted, those to members new to it are inserted, and those to members
+// kept are updated if moved or given other meta, keeping their created time.
+func (tx *Tx) replaceEdges(t, i, k string, collection []core.Resource) *core.Error {
+
+	count, err := tx.CountRelatedVertices(t, i, k, nil)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "2dfd20"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	var (
+		edges    []Edge
+		existing = make(map[graph.Identifier]Edge)
+	)
+
+	if count > 0 {
+		edges, err = tx.FindEdges(t, i, k, nil, nil, graph.Page{Limit: count})
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "1ae3fc"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
+		for _, edge := range edges {
+			existing[graph.Identifier{Type: edge.To.Type, ID: edge.To.Identifier}] = edge
+		}
+	}
+
+	kept := make(map[graph.Identifier]bool)
+
+	for pos, related := range collection {
+
+		id := graph.Identifier{Type: related.Type, ID: related.Identifier}
+		if kept[id] {
+			continue // Listed more than once, and kept at its first position
+		}
+		kept[id] = true
+
+		meta, errObj := edgeMeta(related)
+		if errObj != nil {
+			return errObj
+		}
+
+		edge, ok := existing[id]
+		switch {
+
+		case !ok:
+			err = tx.InsertEdge(t, i, related.Type, related.Identifier, k, pos, meta)
+
+		case edge.Position != pos || !sameJSON(edge.Meta, meta):
+			err = tx.UpdateEdge(t, i, related.Type, related.Identifier, k, pos, meta)
+
+		default:
+			continue
+		}
+		if err == graph.ErrNoRows {
+			errObj := core.MakeError(http.StatusNotFound)
+			errObj.Code = "5125be"
+			return errObj
+		} else if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "80e6ef"
+			errObj.Title = "Encountered internal error while writing to graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
+	}
+
+	for _, edge := range edges {
+
+		if kept[graph.Identifier{Type: edge.To.Type, ID: edge.To.Identifier}] {
+			continue
+		}
+
+		err = tx.DeleteEdge(t, i, edge.To.Type, edge.To.Identifier, k)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "4f0b2a"
+			errObj.Title = "Encounted internal error while deleting from graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
+	}
+
+	return nil
+}
+
+// Reports whether a and b are the same JSON value, however formatted, of
+// which an empty value is null.
+func sameJSON(a, b []byte) bool {
+
+	var u, v interface{}
+
+	if len(a) > 0 && json.Unmarshal(a, &u) != nil {
+		return false
+	}
+	if len(b) > 0 && json.Unmarshal(b, &v) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(u, v)
+}
+
+func PatchRelationship(ctx context.Context, g graph.Graph, t, i, k string, document *core.Document) *core.Error {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "c6a2f8"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	errObj := tx.PatchRelationship(t, i, k, document)
+	if errObj != nil {
+		return errObj
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "2b7e5d"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	return nil
+}
+
+// Replace the members of the relationship keyed by k, for the resource of
+// type t and identifier i, with those in document.  Members are stored in
+// the order given.  A null data member clears a to-one relationship and an
+// empty array clears a to-many, while a document without a data member is
+// refused.
+func (tx *Tx) PatchRelationship(t, i, k string, document *core.Document) *core.Error {
+
+	if document.Data == nil {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "a480bf"
+		errObj.Title = "Bad Request"
+		errObj.Detail = "Request document has no data member"
+		return errObj
+	}
+
+	_, err := tx.FindVertex(t, i)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "a81c5e"
+		return errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "3e6b90"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	if _, ok := document.Data.(null); ok {
+		errObj := tx.DeclareRelationship(t, k, graph.ToOne)
+		if errObj != nil {
+			return errObj
+		}
+		return tx.replaceEdges(t, i, k, nil)
+	}
+
+	collection, cardinality, errObj := relationshipMembers(document)
+	if errObj != nil {
+		return errObj
+	}
+
+	errObj = tx.DeclareRelationship(t, k, cardinality)
+	if errObj != nil {
+		return errObj
+	}
+
+	return tx.replaceEdges(t, i, k, collection)
+}
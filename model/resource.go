@@ -11,9 +11,10 @@ import (
 	"github.com/rs/xid"
 	"github.com/wamuir/go-jsonapi-core"
 	"github.com/wamuir/go-jsonapi-server/graph"
+	"github.com/wamuir/go-jsonapi-server/schema"
 )
 
-func DeleteResource(ctx context.Context, g graph.Graph, t, i string) *core.Error {
+func DeleteResource(ctx context.Context, g graph.Graph, t, i string, p Precondition, soft bool) *core.Error {
 
 	transaction, err := g.Transaction(ctx, false)
 	if err != nil {
@@ -25,9 +26,9 @@ func DeleteResource(ctx context.Context, g graph.Graph, t, i string) *core.Error
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
-	errObj := tx.DeleteResource(t, i)
+	errObj := tx.DeleteResource(t, i, p, soft)
 	if errObj != nil {
 		return errObj
 	}
@@ -44,13 +45,48 @@ func DeleteResource(ctx context.Context, g graph.Graph, t, i string) *core.Error
 	return nil
 }
 
-func (tx *Tx) DeleteResource(t, i string) *core.Error {
+// Delete the resource of type t and identifier i, if it meets the
+// precondition p.  If soft, the resource is tombstoned, to be restored or
+// purged later, rather than deleted.
+func (tx *Tx) DeleteResource(t, i string, p Precondition, soft bool) *core.Error {
+
+	var revision int64
+
+	if p != nil {
+		vertex, err := tx.FindVertex(t, i)
+		if err == graph.ErrNoRows {
+			errObj := core.MakeError(http.StatusNotFound)
+			errObj.Code = "eb476c"
+			return errObj
+		} else if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "9e5d27"
+			errObj.Title = "Encountered internal error while querying graph"
+			errObj.Detail = err.Error()
+			return errObj
+		}
 
-	err := tx.DeleteVertex(t, i)
+		errObj := p.verify(t, i, vertex.Revision)
+		if errObj != nil {
+			return errObj
+		}
+
+		revision = vertex.Revision
+	}
+
+	var err error
+
+	if soft {
+		err = tx.TombstoneVertex(t, i, revision)
+	} else {
+		err = tx.DeleteVertex(t, i, revision)
+	}
 	if err == graph.ErrNoRows {
 		errObj := core.MakeError(http.StatusNotFound)
-		errObj.Code = "eb476c"
+		errObj.Code = "bdc99f"
 		return errObj
+	} else if err == graph.ErrStaleRevision {
+		return preconditionFailed(t, i)
 	} else if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "14d479"
@@ -62,8 +98,9 @@ func (tx *Tx) DeleteResource(t, i string) *core.Error {
 	return nil
 }
 
-// Begin a new *Transaction and make a call to GetResourceLinkage()
-func GetResource(ctx context.Context, g graph.Graph, t, i string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
+// Begin a new *Transaction and make a call to GetResourceLinkage(),
+// returning the resource with its validators.
+func GetResource(ctx context.Context, g graph.Graph, t, i string, h url.URL, q QueryParams) (*core.Document, Validators, *core.Error) {
 
 	var document *core.Document = &core.Document{}
 
@@ -73,21 +110,54 @@ func GetResource(ctx context.Context, g graph.Graph, t, i string, h url.URL, q Q
 		errObj.Code = "6af933"
 		errObj.Title = "Encountered internal error while beginning graph transaction"
 		errObj.Detail = err.Error()
-		return &core.Document{}, errObj
+		return &core.Document{}, Validators{}, errObj
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
-	document, errObj := tx.GetResource(t, i, h, q)
+	errObj := tx.ValidateFields(q)
 	if errObj != nil {
-		return document, errObj
+		return document, Validators{}, errObj
 	}
 
-	return document, nil
+	document, errObj = tx.GetResource(t, i, h, q)
+	if errObj != nil {
+		return document, Validators{}, errObj
+	}
+
+	v, errObj := tx.resourceValidators(t, i, q)
+	if errObj != nil {
+		return document, Validators{}, errObj
+	}
+
+	return document, v, nil
 
 }
 
+// Returns the validators for the resource of type t and identifier i, as
+// represented for q.
+func (tx *Tx) resourceValidators(t, i string, q QueryParams) (Validators, *core.Error) {
+
+	vertex, err := tx.FindVertex(t, i)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "bc037f"
+		return Validators{}, errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "70d1c8"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return Validators{}, errObj
+	}
+
+	return Validators{
+		ETag:         fieldsETag(vertex.Revision, q.Fields),
+		LastModified: vertex.Updated,
+	}, nil
+}
+
 func (tx *Tx) GetResource(t, i string, h url.URL, q QueryParams) (*core.Document, *core.Error) {
 
 	document := &core.Document{}
@@ -128,7 +198,9 @@ func (tx *Tx) GetResource(t, i string, h url.URL, q QueryParams) (*core.Document
 		return document, errObj
 	}
 
-	edgeKeys, err := tx.FindDistinctEdgeKeys(t, i)
+	resource.Meta = stamp(resource.Meta, vertex.Created, vertex.Updated)
+
+	edgeKeys, err := tx.FindEdgeKeys(t)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "443cda"
@@ -239,13 +311,13 @@ func (tx *Tx) GetResource(t, i string, h url.URL, q QueryParams) (*core.Document
 		"self": h.ResolveReference(ref).String(),
 	}
 
-	document.Data = resource
+	document.Data = applyFieldset(resource, q.Fields)
 
 	return document, nil
 
 }
 
-func PostResource(ctx context.Context, g graph.Graph, t string, d *core.Document) (core.Resource, *core.Error) {
+func PostResource(ctx context.Context, g graph.Graph, s *schema.Resources, t string, d *core.Document) (core.Resource, []*core.Error) {
 
 	var identifier core.Resource
 
@@ -255,15 +327,15 @@ func PostResource(ctx context.Context, g graph.Graph, t string, d *core.Document
 		errObj.Code = "1c3686"
 		errObj.Title = "Encountered internal error while beginning graph transaction"
 		errObj.Detail = err.Error()
-		return identifier, errObj
+		return identifier, []*core.Error{errObj}
 	}
 	defer transaction.Close()
 
-	var tx *Tx = &Tx{transaction}
+	var tx *Tx = &Tx{transaction, g}
 
-	identifier, errObj := tx.PostResource(t, d)
-	if errObj != nil {
-		return identifier, errObj
+	identifier, errObjs := tx.PostResource(s, t, d)
+	if errObjs != nil {
+		return identifier, errObjs
 	}
 
 	err = tx.Commit()
@@ -272,13 +344,15 @@ func PostResource(ctx context.Context, g graph.Graph, t string, d *core.Document
 		errObj.Code = "bca977"
 		errObj.Title = "Encountered internal error while committing graph transaction"
 		errObj.Detail = err.Error()
-		return identifier, errObj
+		return identifier, []*core.Error{errObj}
 	}
 
 	return identifier, nil
 }
 
-func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Error) {
+// Insert the resource in d, of type t, and its relationships.  Attributes
+// and meta must validate against the schemas for type t in s, if any.
+func (tx *Tx) PostResource(s *schema.Resources, t string, d *core.Document) (core.Resource, []*core.Error) {
 
 	var resource core.Resource
 
@@ -288,7 +362,7 @@ func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Err
 		errObj.Code = "b7a83f"
 		errObj.Title = "Bad request"
 		errObj.Detail = fmt.Sprintf("Unable to assert data member as resource")
-		return resource, errObj
+		return resource, []*core.Error{errObj}
 	}
 
 	if resource.Type != t {
@@ -296,7 +370,7 @@ func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Err
 		errObj.Code = "3b4ab2"
 		errObj.Title = "Bad request"
 		errObj.Detail = fmt.Sprintf("Resource of type %s cannot be posted to collection %s", resource.Type, t)
-		return resource, errObj
+		return resource, []*core.Error{errObj}
 	}
 
 	if resource.Identifier == "" {
@@ -309,16 +383,23 @@ func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Err
 		errObj.Code = "830283"
 		errObj.Title = "Encountered internal error while transforming data"
 		errObj.Detail = err.Error()
-		return resource, errObj
+		return resource, []*core.Error{errObj}
 	}
 
+	unstamp(resource.Meta)
+
 	meta, err := json.Marshal(resource.Meta)
 	if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "f2ae41"
 		errObj.Title = "Encountered internal error while transforming data"
 		errObj.Detail = err.Error()
-		return resource, errObj
+		return resource, []*core.Error{errObj}
+	}
+
+	errObjs := validateResource(s, t, attributes, meta)
+	if errObjs != nil {
+		return resource, errObjs
 	}
 
 	err = tx.InsertVertex(resource.Type, resource.Identifier, attributes, meta)
@@ -327,20 +408,25 @@ func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Err
 		errObj.Code = "2910dd"
 		errObj.Title = "Bad request"
 		errObj.Detail = err.Error()
-		return resource, errObj
+		return resource, []*core.Error{errObj}
 	} else if err != nil {
 		errObj := core.MakeError(http.StatusInternalServerError)
 		errObj.Code = "bfb7ab"
 		errObj.Title = "Encountered internal error while inserting data into graph"
 		errObj.Detail = err.Error()
-		return resource, errObj
+		return resource, []*core.Error{errObj}
 	}
 
 	for title, relationship := range resource.Relationships {
 
+		// Nothing to insert for empty to-one linkage
+		if _, ok := relationship.Data.(null); ok || relationship.Data == nil {
+			continue
+		}
+
 		errObj := tx.PostRelationship(resource.Type, resource.Identifier, title, &relationship)
 		if errObj != nil {
-			return resource, errObj
+			return resource, []*core.Error{errObj}
 		}
 
 	}
@@ -348,3 +434,191 @@ func (tx *Tx) PostResource(t string, d *core.Document) (core.Resource, *core.Err
 	return resource.Identify(), nil
 
 }
+
+// Begin a new transaction (*Tx), make a call to *Tx.PatchResource() and
+// return the updated resource, with its validators, as read back within the
+// same transaction.
+func PatchResource(ctx context.Context, g graph.Graph, s *schema.Resources, t, i string, p Precondition, d *core.Document, h url.URL, q QueryParams) (*core.Document, Validators, []*core.Error) {
+
+	var document *core.Document = &core.Document{}
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "5d0b6e"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return document, Validators{}, []*core.Error{errObj}
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return document, Validators{}, []*core.Error{errObj}
+	}
+
+	errObjs := tx.PatchResource(s, t, i, p, d)
+	if errObjs != nil {
+		return document, Validators{}, errObjs
+	}
+
+	document, errObj = tx.GetResource(t, i, h, q)
+	if errObj != nil {
+		return document, Validators{}, []*core.Error{errObj}
+	}
+
+	v, errObj := tx.resourceValidators(t, i, q)
+	if errObj != nil {
+		return document, Validators{}, []*core.Error{errObj}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "a3c7d0"
+		errObj.Title = "Encountered internal error while committing graph transaction"
+		errObj.Detail = err.Error()
+		return document, Validators{}, []*core.Error{errObj}
+	}
+
+	return document, v, nil
+}
+
+// Update the resource of type t and identifier i.  Members of attributes
+// and meta are merged into those already stored, while each member of
+// relationships replaces the corresponding relationship in full.  Merged
+// attributes and meta must validate against the schemas for type t in s,
+// if any, and the resource must meet the precondition p.
+func (tx *Tx) PatchResource(s *schema.Resources, t, i string, p Precondition, d *core.Document) []*core.Error {
+
+	resource, ok := d.Data.(core.Resource)
+	if !ok {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "6e1f0c"
+		errObj.Title = "Bad request"
+		errObj.Detail = "Unable to assert data member as resource"
+		return []*core.Error{errObj}
+	}
+
+	if resource.Identifier == "" {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "b2d8f4"
+		errObj.Title = "Bad request"
+		errObj.Detail = "Resource object must contain an id member"
+		return []*core.Error{errObj}
+	}
+
+	if resource.Type != t || resource.Identifier != i {
+		errObj := core.MakeError(http.StatusConflict)
+		errObj.Code = "e07a95"
+		errObj.Detail = fmt.Sprintf(
+			"Resource %s/%s does not match endpoint %s/%s",
+			resource.Type,
+			resource.Identifier,
+			t,
+			i,
+		)
+		return []*core.Error{errObj}
+	}
+
+	vertex, err := tx.FindVertex(t, i)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "40c5d3"
+		return []*core.Error{errObj}
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "7b09e2"
+		errObj.Title = "Encountered internal error while querying graph"
+		errObj.Detail = err.Error()
+		return []*core.Error{errObj}
+	}
+
+	errObj := p.verify(t, i, vertex.Revision)
+	if errObj != nil {
+		return []*core.Error{errObj}
+	}
+
+	attributes, err := mergeObject(vertex.Attributes, resource.Attributes)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "f9a1b7"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return []*core.Error{errObj}
+	}
+
+	unstamp(resource.Meta)
+
+	meta, err := mergeObject(vertex.Meta, resource.Meta)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "0c6d2a"
+		errObj.Title = "Encountered internal error while transforming data"
+		errObj.Detail = err.Error()
+		return []*core.Error{errObj}
+	}
+
+	errObjs := validateResource(s, t, attributes, meta)
+	if errObjs != nil {
+		return errObjs
+	}
+
+	// Conditional, so as to fail if updated since found
+	var revision int64
+	if p != nil {
+		revision = vertex.Revision
+	}
+
+	err = tx.UpdateVertex(t, i, revision, attributes, meta)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "4720f6"
+		return []*core.Error{errObj}
+	} else if err == graph.ErrStaleRevision {
+		return []*core.Error{preconditionFailed(t, i)}
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "d8e3a6"
+		errObj.Title = "Encountered internal error while updating graph"
+		errObj.Detail = err.Error()
+		return []*core.Error{errObj}
+	}
+
+	for title, relationship := range resource.Relationships {
+
+		errObj := tx.PatchRelationship(t, i, title, &relationship)
+		if errObj != nil {
+			return []*core.Error{errObj}
+		}
+
+	}
+
+	return nil
+}
+
+// Merge the members of patch into the JSON object stored as b, returning
+// the merged object as JSON.
+func mergeObject(b []byte, patch map[string]interface{}) ([]byte, error) {
+
+	var object map[string]interface{}
+
+	if len(b) > 0 {
+		err := json.Unmarshal(b, &object)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if object == nil && patch != nil {
+		object = make(map[string]interface{}, len(patch))
+	}
+
+	for k, v := range patch {
+		object[k] = v
+	}
+
+	return json.Marshal(object)
+}
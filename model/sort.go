@@ -0,0 +1,112 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Parse the sort parameter, a comma-separated list of sort fields applied
+// in order.  A field is an attribute name, a dot-separated path to a nested
+// attribute, id, or meta.created or meta.updated for the times at which
+// resources were created and last updated, and sorts in descending order if
+// prefixed with a minus.
+//
+//  example:  ?sort=-createdAt,title,id
+//            ?sort=-meta.updated
+//
+func ParseSort(q url.Values) ([]graph.SortKey, *core.Error) {
+
+	var keys []graph.SortKey
+
+	fail := func(detail string) *core.Error {
+		errObj := core.MakeError(http.StatusBadRequest)
+		errObj.Code = "b0c8e2"
+		errObj.Title = "Invalid query string"
+		errObj.Detail = detail
+		errObj.Source = &core.SourceObject{Parameter: "sort"}
+		return errObj
+	}
+
+	switch entries := q["sort"]; {
+
+	case len(entries) == 0:
+
+		return keys, nil
+
+	case len(entries) > 1:
+
+		return keys, fail("Superfluous parameter: sort")
+
+	}
+
+	for _, field := range strings.Split(q.Get("sort"), ",") {
+
+		var key graph.SortKey
+
+		if strings.HasPrefix(field, "-") {
+			key.Descending = true
+			field = field[1:]
+		}
+
+		key.Path = strings.Split(field, ".")
+		for _, p := range key.Path {
+			if p == "" {
+				return nil, fail(fmt.Sprintf("Invalid sort field: %q", field))
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Returns the attributes into which the sort fields of q are paths.
+func sortAttributes(q QueryParams) []string {
+
+	var attributes []string
+
+	for _, key := range q.Sort {
+		if len(key.Path) == 1 && key.Path[0] == "id" || isTimePath(key.Path) {
+			continue
+		}
+		attributes = append(attributes, key.Path[0])
+	}
+
+	return attributes
+}
+
+// Verify that each sort field is id, meta.created, meta.updated or a path
+// into one of the attributes named in attributeKeys.
+func validateSort(attributeKeys []string, q QueryParams) *core.Error {
+
+	for _, key := range q.Sort {
+
+		if len(key.Path) == 1 && key.Path[0] == "id" {
+			continue
+		}
+
+		if isTimePath(key.Path) {
+			continue
+		}
+
+		if !stringInSlice(key.Path[0], attributeKeys) {
+			errObj := core.MakeError(http.StatusBadRequest)
+			errObj.Code = "5e7a1d"
+			errObj.Title = "Invalid query string"
+			errObj.Detail = fmt.Sprintf(
+				"Unsupported sort field: %s",
+				strings.Join(key.Path, "."),
+			)
+			errObj.Source = &core.SourceObject{Parameter: "sort"}
+			return errObj
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Members of meta objects for the times at which a resource was created and
+// last updated, or a member was added to a relationship.  The graph keeps
+// these times, so any given by a client are discarded.
+const (
+	createdMember = "created"
+	updatedMember = "updated"
+)
+
+// Returns meta with members for the times created and updated, in
+// graph.TimeFormat, unless zero.
+func stamp(meta map[string]interface{}, created, updated time.Time) map[string]interface{} {
+
+	if created.IsZero() && updated.IsZero() {
+		return meta
+	}
+
+	if meta == nil {
+		meta = make(map[string]interface{}, 2)
+	}
+
+	if !created.IsZero() {
+		meta[createdMember] = created.UTC().Format(graph.TimeFormat)
+	}
+
+	if !updated.IsZero() {
+		meta[updatedMember] = updated.UTC().Format(graph.TimeFormat)
+	}
+
+	return meta
+}
+
+// Removes the members for times kept by the graph from meta, as given by a
+// client.
+func unstamp(meta map[string]interface{}) {
+	delete(meta, createdMember)
+	delete(meta, updatedMember)
+}
+
+// Reports whether path is that of a time kept by the graph.
+func isTimePath(path []string) bool {
+	return len(path) == 2 && path[0] == "meta" &&
+		(path[1] == createdMember || path[1] == updatedMember)
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Begin a new transaction (*Tx) and make a call to *Tx.RestoreResource(),
+// returning the resource restored with its validators.
+func RestoreResource(ctx context.Context, g graph.Graph, t, i string, h url.URL, q QueryParams) (*core.Document, Validators, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "c71e05"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return nil, Validators{}, errObj
+	}
+	defer transaction.Close()
+
+	var tx *Tx = &Tx{transaction, g}
+
+	errObj := tx.ValidateFields(q)
+	if errObj != nil {
+		return nil, Validators{}, errObj
+	}
+
+	errObj = tx.RestoreResource(t, i)
+	if errObj != nil {
+		return nil, Validators{}, errObj
+	}
+
+	document, errObj := tx.GetResource(t, i, h, q)
+	if errObj != nil {
+		return nil, Validators{}, errObj
+	}
+
+	v, errObj := tx.resourceValidators(t, i, q)
+	if errObj != nil {
+		return nil, Validators{}, errObj
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "5fa3d8"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return nil, Validators{}, errObj
+	}
+
+	return document, v, nil
+}
+
+// Restore the tombstoned resource of type t and identifier i, with the
+// relationships it had when tombstoned.
+func (tx *Tx) RestoreResource(t, i string) *core.Error {
+
+	err := tx.RestoreVertex(t, i)
+	if err == graph.ErrNoRows {
+		errObj := core.MakeError(http.StatusNotFound)
+		errObj.Code = "3d8b6e"
+		errObj.Detail = "No tombstoned resource with the type and identifier given"
+		return errObj
+	} else if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "b0e49c"
+		errObj.Title = "Encountered internal error while restoring to graph"
+		errObj.Detail = err.Error()
+		return errObj
+	}
+
+	return nil
+}
+
+// Delete the resources tombstoned before the time given, returning the
+// number deleted.
+func PurgeResources(ctx context.Context, g graph.Graph, tombstonedBefore time.Time) (int64, *core.Error) {
+
+	transaction, err := g.Transaction(ctx, false)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "7a2f61"
+		errObj.Title = "Encountered internal error while beginning graph transaction"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+	defer transaction.Close()
+
+	count, err := transaction.PurgeVertices(tombstonedBefore)
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "e9c450"
+		errObj.Title = "Encountered internal error while deleting from graph"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		errObj := core.MakeError(http.StatusInternalServerError)
+		errObj.Code = "46d9bb"
+		errObj.Title = "Encountered internal error while committing to graph"
+		errObj.Detail = err.Error()
+		return 0, errObj
+	}
+
+	return count, nil
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/schema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Validate the attributes and meta, as stored, of a resource of type t
+// against the schemas for that type in s.  Each violation is its own error
+// object, pointing to the value at fault.
+func validateResource(s *schema.Resources, t string, attributes, meta []byte) []*core.Error {
+
+	var errObjs []*core.Error
+
+	for _, member := range []struct {
+		name     string
+		b        []byte
+		validate func(string, interface{}) ([]gojsonschema.ResultError, error)
+	}{
+		{"attributes", attributes, s.ValidateAttributes},
+		{"meta", meta, s.ValidateMeta},
+	} {
+
+		var v interface{}
+
+		err := json.Unmarshal(member.b, &v)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "a0c4e7"
+			errObj.Title = "Encountered internal error while transforming data"
+			errObj.Detail = err.Error()
+			return []*core.Error{errObj}
+		}
+
+		violations, err := member.validate(t, v)
+		if err != nil {
+			errObj := core.MakeError(http.StatusInternalServerError)
+			errObj.Code = "3f81b6"
+			errObj.Title = "Encountered internal error while validating resource against JSON schema"
+			errObj.Detail = err.Error()
+			return []*core.Error{errObj}
+		}
+
+		for _, violation := range violations {
+			errObj := core.MakeError(http.StatusUnprocessableEntity)
+			errObj.Code = "6b2d09"
+			errObj.Title = "Resource failed to validate against JSON schema"
+			errObj.Detail = violation.Description()
+			errObj.Source = &core.SourceObject{
+				Pointer: schema.Pointer("/data/"+member.name, violation),
+			}
+			errObjs = append(errObjs, errObj)
+		}
+	}
+
+	return errObjs
+}
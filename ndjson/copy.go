@@ -0,0 +1,363 @@
+package ndjson
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Summary is the numbers of vertices, edges and changes of a graph, and a
+// checksum of them.  The checksum is of each vertex, edge and change, with
+// their attributes, meta and states as canonical JSON and their times in
+// graph.TimeFormat, and of each declared relationship, and not of their
+// order, so that graphs of different backends may be compared.
+type Summary struct {
+	Vertices int64
+	Edges    int64
+	Changes  int64
+	Checksum [sha256.Size]byte
+}
+
+func (s Summary) String() string {
+
+	return fmt.Sprintf("%d vertices, %d edges and %d changes (checksum %x)", s.Vertices, s.Edges, s.Changes, s.Checksum)
+}
+
+// Add a relationship of vertexType, declared of cardinality.
+func (s *Summary) addEdgeKey(vertexType, key string, cardinality graph.Cardinality) {
+
+	s.sum("relationship", vertexType, key, cardinality)
+}
+
+// Add vertex, with its revision and times.
+func (s *Summary) addVertex(vertex graph.Vertex) {
+
+	s.sum("vertex", vertex.Type, vertex.Identifier, canonical(vertex.Attributes), canonical(vertex.Meta),
+		vertex.Revision, timeOf(vertex.Created), timeOf(vertex.Updated), timeOf(vertex.Tombstoned))
+	s.Vertices++
+}
+
+// Add edge, with its position and times.
+func (s *Summary) addEdge(edge graph.Edge) {
+
+	s.sum("edge", edge.From.Type, edge.From.Identifier, edge.To.Type, edge.To.Identifier, edge.Key,
+		edge.Position, canonical(edge.Meta), timeOf(edge.Created), timeOf(edge.Updated))
+	s.Edges++
+}
+
+// Add change, with its ID.
+func (s *Summary) addChange(change graph.Change) {
+
+	s.sum("change", change.ID, change.VertexType, change.VertexID, change.Key, change.Operation,
+		change.Actor, change.RequestID, timeOf(change.Time), canonical(change.Before), canonical(change.After))
+	s.Changes++
+}
+
+// Add the hash of fields to the checksum.  As vertices, edges, changes and
+// relationships are unique, exclusive or is as good as any sum.
+func (s *Summary) sum(fields ...interface{}) {
+
+	b, _ := json.Marshal(fields)
+
+	hash := sha256.Sum256(b)
+	for i := range s.Checksum {
+		s.Checksum[i] ^= hash[i]
+	}
+}
+
+// Returns b as canonical JSON, with keys of objects sorted and
+// insignificant whitespace removed, or as is if not valid JSON (e.g.,
+// empty, or nil, as a backend may hold either).
+func canonical(b []byte) string {
+
+	var v interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&v)
+	if err != nil {
+		return string(b)
+	}
+
+	c, err := json.Marshal(v)
+	if err != nil {
+		return string(b)
+	}
+
+	return string(c)
+}
+
+// Returns t in graph.TimeFormat, or empty if zero.
+func timeOf(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(graph.TimeFormat)
+}
+
+// Sum returns the summary of g, as read within a single transaction.
+func Sum(ctx context.Context, g graph.Graph) (Summary, error) {
+
+	var summary Summary
+
+	tx, err := g.Transaction(ctx, true)
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Close()
+
+	err = eachEdgeKey(tx, func(vertexType, key string, cardinality graph.Cardinality) error {
+		summary.addEdgeKey(vertexType, key, cardinality)
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	err = tx.DumpVertices(func(vertex graph.Vertex) error {
+		summary.addVertex(vertex)
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	err = tx.DumpEdges(func(edge graph.Edge) error {
+		summary.addEdge(edge)
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	err = tx.DumpChanges(func(change graph.Change) error {
+		summary.addChange(change)
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+// ErrNotEmpty is returned by Copy for a graph to copy to with any vertex or
+// change.
+var ErrNotEmpty = errors.New("graph to copy to is not empty")
+
+// Copy copies the graph from to the graph to as is, as read within a single
+// transaction, committed in batches: its declared relationships, its
+// vertices, tombstoned or not, and edges, with their times, revisions and
+// positions, and its history, with the IDs of its changes.  Neither the
+// outbox nor the deliveries to webhooks are copied, as those are of the
+// consumers of from; consumers of to are given the changes after the copy.
+// Once copied, to is verified to be from, by the numbers of vertices, edges
+// and changes and their checksum, which are returned.
+//
+// A graph to copy to with any vertex or change, to which from would not be
+// copied as is, is refused with ErrNotEmpty before anything is written.
+func Copy(ctx context.Context, from, to graph.Graph, batch int, progress Progress) (Summary, error) {
+
+	var want Summary
+
+	err := empty(ctx, to)
+	if err != nil {
+		return want, err
+	}
+
+	src, err := from.Transaction(ctx, true)
+	if err != nil {
+		return want, err
+	}
+	defer src.Close()
+
+	dst := &batcher{ctx: ctx, g: to, batch: batch, progress: func() {
+		progress.report(want.Vertices, want.Edges)
+	}}
+	defer dst.close()
+
+	err = eachEdgeKey(src, func(vertexType, key string, cardinality graph.Cardinality) error {
+		want.addEdgeKey(vertexType, key, cardinality)
+		return dst.write(func(tx graph.Tx) error {
+			return tx.DeclareEdgeKey(vertexType, key, cardinality)
+		})
+	})
+	if err != nil {
+		return want, err
+	}
+
+	// Vertices precede the edges from and to them
+	err = src.DumpVertices(func(vertex graph.Vertex) error {
+		want.addVertex(vertex)
+		return dst.write(func(tx graph.Tx) error {
+			return tx.LoadVertex(vertex)
+		})
+	})
+	if err != nil {
+		return want, err
+	}
+
+	err = src.DumpEdges(func(edge graph.Edge) error {
+		want.addEdge(edge)
+		return dst.write(func(tx graph.Tx) error {
+			return tx.LoadEdge(edge)
+		})
+	})
+	if err != nil {
+		return want, err
+	}
+
+	err = src.DumpChanges(func(change graph.Change) error {
+		want.addChange(change)
+		return dst.write(func(tx graph.Tx) error {
+			return tx.LoadChange(change)
+		})
+	})
+	if err != nil {
+		return want, err
+	}
+
+	err = dst.commit()
+	if err != nil {
+		return want, err
+	}
+
+	got, err := Sum(ctx, to)
+	if err != nil {
+		return want, err
+	} else if got != want {
+		return want, fmt.Errorf("copied %s, want %s", got, want)
+	}
+
+	return want, nil
+}
+
+// Returns ErrNotEmpty if g has any vertex, tombstoned or not, or change.
+func empty(ctx context.Context, g graph.Graph) error {
+
+	tx, err := g.Transaction(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = tx.DumpVertices(func(graph.Vertex) error {
+		return ErrNotEmpty
+	})
+	if err != nil {
+		return err
+	}
+
+	id, err := tx.FindLastChangeID()
+	if err != nil {
+		return err
+	} else if id > 0 {
+		return ErrNotEmpty
+	}
+
+	return nil
+}
+
+// Calls fn with each relationship declared by tx, with its cardinality.
+func eachEdgeKey(tx graph.Tx, fn func(vertexType, key string, cardinality graph.Cardinality) error) error {
+
+	types, err := tx.FindVertexTypes()
+	if err != nil {
+		return err
+	}
+
+	for _, vertexType := range types {
+
+		keys, err := tx.FindEdgeKeys(vertexType)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			cardinality, err := tx.FindEdgeKey(vertexType, key)
+			if err != nil {
+				return err
+			} else if cardinality == "" {
+				continue
+			}
+
+			err = fn(vertexType, key, cardinality)
+			if err != nil {
+				return fmt.Errorf("relationship %s of %s: %w", key, vertexType, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// batcher writes to a graph in transactions of batch writes each.
+type batcher struct {
+	ctx      context.Context
+	g        graph.Graph
+	batch    int
+	progress func() // called after each commit
+	tx       graph.Tx
+	n        int
+}
+
+// Write with fn, within the transaction of the batch, committing it once
+// the batch is full.
+func (b *batcher) write(fn func(graph.Tx) error) error {
+
+	if b.tx == nil {
+		tx, err := b.g.Transaction(b.ctx, false)
+		if err != nil {
+			return err
+		}
+		b.tx, b.n = tx, 0
+	}
+
+	err := fn(b.tx)
+	if err != nil {
+		return err
+	}
+
+	b.n++
+	if b.n >= b.batch {
+		return b.commit()
+	}
+
+	return nil
+}
+
+// Commit the transaction of the batch, if any.
+func (b *batcher) commit() error {
+
+	if b.tx == nil {
+		return nil
+	}
+
+	err := b.tx.Commit()
+	b.close()
+	if err != nil {
+		return err
+	}
+
+	b.progress()
+
+	return nil
+}
+
+// Close the transaction of the batch, if any, discarding its writes.
+func (b *batcher) close() {
+
+	if b.tx != nil {
+		b.tx.Close()
+		b.tx = nil
+	}
+}
@@ -0,0 +1,238 @@
+package ndjson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+)
+
+func TestCopy(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	ctx := graph.WithAudit(context.Background(), graph.Audit{Actor: "alice", RequestID: "r1"})
+	dir := t.TempDir()
+
+	// graphs, as files, to copy from and to
+	src, err := sqlite3.Connect("file:" + filepath.Join(dir, "src.sqlite3") + "?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer src.Close()
+
+	dst, err := sqlite3.Connect("file:" + filepath.Join(dir, "dst.sqlite3") + "?_foreign_keys=ON")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer dst.Close()
+
+	tx, err := src.Transaction(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = tx.DeclareEdgeKey("people", "manager", graph.ToOne)
+	if err != nil {
+		tx.Close()
+		t.Fatal(err)
+		return
+	}
+
+	// more vertices than are written in a batch, each but the first managed
+	// by the first, of which one is updated and another tombstoned
+	for i := 0; i < 250; i++ {
+		id := fmt.Sprintf("%04d", i)
+		err = tx.InsertVertex("people", id, []byte(fmt.Sprintf(`{"n":%d,"name":"p%s"}`, i, id)), nil)
+		if err == nil && i > 0 {
+			err = tx.InsertEdge("people", id, "people", "0000", "manager", 0, []byte(fmt.Sprintf(`{"since":%d}`, i)))
+		}
+		if err != nil {
+			tx.Close()
+			t.Fatal(err)
+			return
+		}
+	}
+
+	err = tx.UpdateVertex("people", "0001", 1, []byte(`{"n":1,"name":"updated"}`), nil)
+	if err == nil {
+		err = tx.TombstoneVertex("people", "0002", 0)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// copy
+	summary, err := Copy(ctx, src, dst, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if summary.Vertices != 250 || summary.Edges != 249 || summary.Changes != 250+249+2 {
+		t.Errorf("Copied %s, want 250 vertices, 249 edges and 501 changes", summary)
+	}
+
+	got, err := Sum(ctx, dst)
+	if err != nil {
+		t.Fatal(err)
+	} else if got != summary {
+		t.Errorf("Got %s, want %s", got, summary)
+	}
+
+	// as is
+	a, err := src.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	b, err := dst.Transaction(ctx, true)
+	if err != nil {
+		a.Close()
+		t.Fatal(err)
+		return
+	}
+
+	for _, id := range []string{"0000", "0001"} {
+		want, err := a.FindVertex("people", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := b.FindVertex("people", id)
+		if err != nil {
+			t.Error(err)
+		} else if got.Revision != want.Revision || !got.Created.Equal(want.Created) || !got.Updated.Equal(want.Updated) {
+			t.Errorf("Got %s of revision %d, created %s and updated %s, want %d, %s and %s",
+				id, got.Revision, got.Created, got.Updated, want.Revision, want.Created, want.Updated)
+		}
+	}
+
+	if _, err = b.FindVertex("people", "0002"); err != graph.ErrNoRows {
+		t.Errorf("Got %v for a tombstoned vertex, want %v", err, graph.ErrNoRows)
+	}
+
+	cardinality, err := b.FindEdgeKey("people", "manager")
+	if err != nil {
+		t.Error(err)
+	} else if cardinality != graph.ToOne {
+		t.Errorf("Got cardinality %s, want %s", cardinality, graph.ToOne)
+	}
+
+	wantChanges, err := a.FindChanges("people", "0001", graph.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotChanges, err := b.FindChanges("people", "0001", graph.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(gotChanges) != len(wantChanges) {
+		t.Fatalf("Got %d changes, want %d", len(gotChanges), len(wantChanges))
+	}
+	for i := range wantChanges {
+		g, w := gotChanges[i], wantChanges[i]
+		if g.ID != w.ID || g.Operation != w.Operation || g.Actor != w.Actor || g.RequestID != w.RequestID || !g.Time.Equal(w.Time) {
+			t.Errorf("Got change %d %s by %s (%s) at %s, want %d %s by %s (%s) at %s",
+				g.ID, g.Operation, g.Actor, g.RequestID, g.Time, w.ID, w.Operation, w.Actor, w.RequestID, w.Time)
+		}
+	}
+
+	a.Close()
+	b.Close()
+
+	// tombstoned, and restored, and the next change after those copied
+	tx, err = dst.Transaction(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = tx.RestoreVertex("people", "0002")
+	if err == nil {
+		err = tx.Commit()
+	}
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	b, err = dst.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	changes, err := b.FindChangesAfter(summary.Changes, nil, 10)
+	b.Close()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 1 || changes[0].ID != summary.Changes+1 || changes[0].Operation != graph.Restore {
+		t.Errorf("Got %v, want the restore of 0002 as change %d", changes, summary.Changes+1)
+	}
+
+	// copy again, refused as the graph copied to is not empty
+	_, err = Copy(ctx, src, dst, 100, nil)
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Got %v, want %v", err, ErrNotEmpty)
+	}
+
+	// copy to another backend, as is
+	m := memory.New()
+	defer m.Close()
+
+	again, err := Copy(ctx, src, m, 1000, nil)
+	if err != nil {
+		t.Error(err)
+	} else if again != summary {
+		t.Errorf("Copied %s, want %s", again, summary)
+	}
+}
+
+func TestSummary(t *testing.T) {
+
+	var a, b Summary
+
+	created := time.Date(2021, 7, 13, 12, 0, 0, 0, time.UTC)
+
+	a.addVertex(graph.Vertex{Type: "people", Identifier: "1", Attributes: []byte(`{"name":"Alice","age":30}`), Revision: 2, Created: created})
+	a.addVertex(graph.Vertex{Type: "people", Identifier: "2", Attributes: []byte(`{"name":"Bob"}`), Revision: 1})
+	a.addEdge(graph.Edge{From: graph.Vertex{Type: "people", Identifier: "1"}, To: graph.Vertex{Type: "people", Identifier: "2"}, Key: "friends", Meta: []byte(`{"a":1,"b":2}`), Position: 3})
+	a.addChange(graph.Change{ID: 1, VertexType: "people", VertexID: "1", Operation: graph.Insert, Time: created, After: []byte(`{"attributes":{"name":"Alice"}}`)})
+
+	// the same, in another order, formatting and zone
+	b.addChange(graph.Change{ID: 1, VertexType: "people", VertexID: "1", Operation: graph.Insert, Time: created.In(time.FixedZone("", 3600)), After: []byte(`{ "attributes": {"name": "Alice"} }`)})
+	b.addEdge(graph.Edge{From: graph.Vertex{Type: "people", Identifier: "1"}, To: graph.Vertex{Type: "people", Identifier: "2"}, Key: "friends", Meta: []byte(`{ "b":2, "a":1 }`), Position: 3})
+	b.addVertex(graph.Vertex{Type: "people", Identifier: "2", Attributes: []byte(`{"name":"Bob"}`), Revision: 1})
+	b.addVertex(graph.Vertex{Type: "people", Identifier: "1", Attributes: []byte(`{"age":30,"name":"Alice"}`), Revision: 2, Created: created})
+
+	if a != b {
+		t.Errorf("Got %s, want %s", b, a)
+	}
+	if a.Vertices != 2 || a.Edges != 1 || a.Changes != 1 {
+		t.Errorf("Got %s, want 2 vertices, 1 edge and 1 change", a)
+	}
+
+	// not the same, with Bob of another revision, as a vertex added twice
+	// is of the checksum no more
+	b.addVertex(graph.Vertex{Type: "people", Identifier: "2", Attributes: []byte(`{"name":"Bob"}`), Revision: 1})
+	b.addVertex(graph.Vertex{Type: "people", Identifier: "2", Attributes: []byte(`{"name":"Bob"}`), Revision: 2})
+	if a.Checksum == b.Checksum {
+		t.Error("Got the same checksum for another revision")
+	}
+}
@@ -0,0 +1,410 @@
+// Package ndjson exports and imports whole graphs as newline-delimited
+// JSON:API resource objects, one per line, through graph.Graph alone.
+//
+// An export has a line for each vertex, with its attributes and meta, and
+// then a line for each vertex with edges from it, with those edges as the
+// data of its relationships, in order.  The data of a relationship is
+// always an array of resource identifiers, with the meta of the edges, and
+// the relationship has the positions of the edges as meta, along with its
+// cardinality, if declared, which is declared on import:
+//
+//	{"type":"people","id":"1","attributes":{"name":"Alice"}}
+//	{"type":"people","id":"1","relationships":{"friends":{"data":[{"type":"people","id":"2"}],"meta":{"positions":[0],"cardinality":"many"}}}}
+//
+// Vertices must precede the edges to them.  A vertex imported without
+// attributes, or with null, has an empty object, and one with attributes
+// not an object is refused.  Imports are idempotent: a vertex or edge
+// already as given is left as is.  Copy copies one graph to another, of
+// the same backend or not, as is, with the times, revisions, tombstoned
+// vertices and history that an export does not carry, and verifies the
+// copy.
+package ndjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+)
+
+// Number of vertices and edges read from a graph at once.
+const pageSize = 500
+
+// Progress is called with the numbers of vertices and edges exported or
+// imported so far, after each page or batch.
+type Progress func(vertices, edges int64)
+
+// Resource is a line of an export.
+type Resource struct {
+	Type          string                  `json:"type"`
+	ID            string                  `json:"id"`
+	Attributes    json.RawMessage         `json:"attributes,omitempty"`
+	Meta          json.RawMessage         `json:"meta,omitempty"`
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+}
+
+// Relationship is a relationship of a Resource, with an identifier of the
+// vertex to which each edge is, in order, and its declared cardinality, if
+// any.
+type Relationship struct {
+	Data []Identifier `json:"data"`
+	Meta struct {
+		Positions   []int             `json:"positions,omitempty"`
+		Cardinality graph.Cardinality `json:"cardinality,omitempty"`
+	} `json:"meta"`
+}
+
+// Identifier is the resource identifier of the vertex to which an edge is,
+// with the meta of the edge.
+type Identifier struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// Export writes every vertex, and then every edge, of g to w, as read
+// within a single transaction.
+func Export(ctx context.Context, g graph.Graph, w io.Writer, progress Progress) error {
+
+	var vertices, edges int64
+
+	tx, err := g.Transaction(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	encoder := json.NewEncoder(w)
+
+	types, err := tx.FindVertexTypes()
+	if err != nil {
+		return err
+	}
+
+	// Vertices
+	for _, vertexType := range types {
+		err = eachVertices(tx, vertexType, func(page []graph.Vertex) error {
+			for _, vertex := range page {
+				err := encoder.Encode(Resource{
+					Type:       vertex.Type,
+					ID:         vertex.Identifier,
+					Attributes: raw(vertex.Attributes),
+					Meta:       raw(vertex.Meta),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			vertices += int64(len(page))
+			progress.report(vertices, edges)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Edges, from each vertex
+	for _, vertexType := range types {
+		err = eachVertices(tx, vertexType, func(page []graph.Vertex) error {
+			for _, vertex := range page {
+				resource, n, err := relationships(tx, vertex)
+				if err != nil {
+					return err
+				} else if n == 0 {
+					continue
+				}
+				err = encoder.Encode(resource)
+				if err != nil {
+					return err
+				}
+				edges += n
+			}
+			progress.report(vertices, edges)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calls f with each page of the vertices of vertexType, in order.
+func eachVertices(tx graph.Tx, vertexType string, f func([]graph.Vertex) error) error {
+
+	for page := (graph.Page{Limit: pageSize}); ; page.Offset += pageSize {
+
+		vertices, err := tx.FindVertices(vertexType, nil, nil, page)
+		if err != nil {
+			return err
+		}
+
+		err = f(vertices)
+		if err != nil {
+			return err
+		}
+
+		if int64(len(vertices)) < pageSize {
+			return nil
+		}
+	}
+}
+
+// Returns the identifier of vertex with its relationships, and the number of
+// edges from it.
+func relationships(tx graph.Tx, vertex graph.Vertex) (Resource, int64, error) {
+
+	var n int64
+
+	resource := Resource{
+		Type:          vertex.Type,
+		ID:            vertex.Identifier,
+		Relationships: make(map[string]Relationship),
+	}
+
+	keys, err := tx.FindDistinctEdgeKeys(vertex.Type, vertex.Identifier)
+	if err != nil {
+		return resource, n, err
+	}
+
+	for _, key := range keys {
+
+		var relationship Relationship
+
+		for page := (graph.Page{Limit: pageSize}); ; page.Offset += pageSize {
+
+			edges, err := tx.FindEdges(vertex.Type, vertex.Identifier, key, nil, nil, page)
+			if err != nil {
+				return resource, n, err
+			}
+
+			for _, edge := range edges {
+				relationship.Data = append(relationship.Data, Identifier{
+					Type: edge.To.Type,
+					ID:   edge.To.Identifier,
+					Meta: raw(edge.Meta),
+				})
+				relationship.Meta.Positions = append(relationship.Meta.Positions, edge.Position)
+			}
+
+			if int64(len(edges)) < pageSize {
+				break
+			}
+		}
+
+		if len(relationship.Data) > 0 {
+			relationship.Meta.Cardinality, err = tx.FindEdgeKey(vertex.Type, key)
+			if err != nil {
+				return resource, n, err
+			}
+			resource.Relationships[key] = relationship
+			n += int64(len(relationship.Data))
+		}
+	}
+
+	return resource, n, nil
+}
+
+// Import reads resources from r into g, committing a transaction after each
+// batch of lines.
+func Import(ctx context.Context, g graph.Graph, r io.Reader, batch int, progress Progress) error {
+
+	var (
+		vertices, edges int64
+		line            int
+		decoder         = json.NewDecoder(r)
+	)
+
+	if batch < 1 {
+		batch = 1
+	}
+
+	for more := true; more; {
+
+		tx, err := g.Transaction(ctx, false)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < batch; i++ {
+
+			var resource Resource
+
+			err = decoder.Decode(&resource)
+			if err == io.EOF {
+				more = false
+				break
+			} else if err != nil {
+				tx.Close()
+				return fmt.Errorf("line %d: %w", line+1, err)
+			}
+
+			line++
+
+			v, e, err := put(tx, resource)
+			if err != nil {
+				tx.Close()
+				return fmt.Errorf("line %d: %s/%s: %w", line, resource.Type, resource.ID, err)
+			}
+
+			vertices, edges = vertices+v, edges+e
+		}
+
+		err = tx.Commit()
+		tx.Close()
+		if err != nil {
+			return err
+		}
+
+		progress.report(vertices, edges)
+	}
+
+	return nil
+}
+
+// Put resource to tx, returning the numbers of vertices and edges written.
+// A line of relationships only leaves the attributes and meta of a vertex
+// as they are.
+func put(tx graph.Tx, resource Resource) (int64, int64, error) {
+
+	var vertices, edges int64
+
+	attributes, err := attributesOf(resource)
+	if err != nil {
+		return vertices, edges, err
+	}
+
+	meta := metaOf(resource.Meta)
+
+	vertex, err := tx.FindVertex(resource.Type, resource.ID)
+	if err == graph.ErrNoRows {
+		err = tx.InsertVertex(resource.Type, resource.ID, attributes, meta)
+		if err != nil {
+			return vertices, edges, err
+		}
+		vertices++
+	} else if err != nil {
+		return vertices, edges, err
+	} else if len(resource.Relationships) == 0 || resource.Attributes != nil || resource.Meta != nil {
+		if !equal(vertex.Attributes, attributes) || !equal(vertex.Meta, meta) {
+			err = tx.UpdateVertex(resource.Type, resource.ID, 0, attributes, meta)
+			if err != nil {
+				return vertices, edges, err
+			}
+			vertices++
+		}
+	}
+
+	for key, relationship := range resource.Relationships {
+
+		// Declared before any edge is inserted, as an edge would otherwise
+		// leave the relationship of no cardinality
+		switch relationship.Meta.Cardinality {
+		case "":
+		case graph.ToOne, graph.ToMany:
+			err = tx.DeclareEdgeKey(resource.Type, key, relationship.Meta.Cardinality)
+			if err == graph.ErrConflict {
+				return vertices, edges, fmt.Errorf("relationship %s is not of cardinality %s", key, relationship.Meta.Cardinality)
+			} else if err != nil {
+				return vertices, edges, err
+			}
+		default:
+			return vertices, edges, fmt.Errorf("relationship %s is of unknown cardinality %s", key, relationship.Meta.Cardinality)
+		}
+
+		for i, identifier := range relationship.Data {
+
+			position := i
+			if len(relationship.Meta.Positions) == len(relationship.Data) {
+				position = relationship.Meta.Positions[i]
+			}
+
+			edge, err := tx.FindEdge(resource.Type, resource.ID, identifier.Type, identifier.ID, key)
+			if err == nil && edge.Position == position && equal(edge.Meta, metaOf(identifier.Meta)) {
+				continue
+			} else if err == nil {
+				err = tx.DeleteEdge(resource.Type, resource.ID, identifier.Type, identifier.ID, key)
+			} else if err == graph.ErrNoRows {
+				err = nil
+			}
+			if err != nil {
+				return vertices, edges, err
+			}
+
+			err = tx.InsertEdge(resource.Type, resource.ID, identifier.Type, identifier.ID, key, position, metaOf(identifier.Meta))
+			if err == graph.ErrNoRows {
+				return vertices, edges, fmt.Errorf("no vertex %s/%s for relationship %s", identifier.Type, identifier.ID, key)
+			} else if err != nil {
+				return vertices, edges, err
+			}
+
+			edges++
+		}
+	}
+
+	return vertices, edges, nil
+}
+
+func (p Progress) report(vertices, edges int64) {
+
+	if p != nil {
+		p(vertices, edges)
+	}
+}
+
+// Returns b as raw JSON, or nil if b is null or not valid JSON (e.g.,
+// empty), as meta of either is exported as none.
+func raw(b []byte) json.RawMessage {
+
+	if !json.Valid(b) || bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil
+	}
+
+	return json.RawMessage(b)
+}
+
+// Returns the meta m of a vertex or edge as stored, being null if missing,
+// as a vertex or edge written through the API stores it.
+func metaOf(m json.RawMessage) json.RawMessage {
+
+	if m == nil {
+		return json.RawMessage(`null`)
+	}
+
+	return m
+}
+
+// Returns the attributes of resource, being an empty object if missing or
+// null, or an error if not an object, as a resource could not then be read.
+func attributesOf(resource Resource) (json.RawMessage, error) {
+
+	if resource.Attributes == nil || bytes.Equal(bytes.TrimSpace(resource.Attributes), []byte("null")) {
+		return json.RawMessage(`{}`), nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(resource.Attributes, &object); err != nil {
+		return nil, fmt.Errorf("attributes are not an object")
+	}
+
+	return resource.Attributes, nil
+}
+
+// Reports whether a and b are the same JSON, or are both not JSON.
+func equal(a, b []byte) bool {
+
+	var x, y bytes.Buffer
+
+	if json.Compact(&x, a) != nil {
+		return json.Compact(&y, b) != nil
+	} else if json.Compact(&y, b) != nil {
+		return false
+	}
+
+	return bytes.Equal(x.Bytes(), y.Bytes())
+}
@@ -0,0 +1,243 @@
+package ndjson
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wamuir/go-jsonapi-core"
+	"github.com/wamuir/go-jsonapi-server/graph"
+	sqlite3 "github.com/wamuir/go-jsonapi-server/graph/sqlite3"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+func TestExportImport(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	ctx := context.Background()
+
+	// graphs, to export from and import to
+	src, err := sqlite3.Connect("file:ndjson-src?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer src.Close()
+
+	dst, err := sqlite3.Connect("file:ndjson-dst?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer dst.Close()
+
+	tx, err := src.Transaction(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, v := range []struct{ typ, id, attributes, meta string }{
+		{"people", "1", `{"name":"Alice"}`, `{"source":"test"}`},
+		{"people", "2", `{"name":"Bob"}`, ``},
+		{"people", "3", `{"name":"Carol"}`, ``},
+		{"groups", "a", `{"title":"Admins"}`, ``},
+	} {
+		err = tx.InsertVertex(v.typ, v.id, []byte(v.attributes), []byte(v.meta))
+		if err != nil {
+			tx.Close()
+			t.Fatal(err)
+			return
+		}
+	}
+
+	for key, cardinality := range map[string]graph.Cardinality{"friends": graph.ToMany, "manager": graph.ToOne} {
+		err = tx.DeclareEdgeKey("people", key, cardinality)
+		if err != nil {
+			tx.Close()
+			t.Fatal(err)
+			return
+		}
+	}
+
+	for _, e := range []struct {
+		from, toType, to, key string
+		position              int
+		meta                  string
+	}{
+		{"1", "people", "3", "friends", 2, `{"since":2019}`},
+		{"1", "people", "2", "friends", 5, ``},
+		{"2", "groups", "a", "groups", 0, `{"role":"owner"}`},
+		{"3", "people", "1", "manager", 0, ``},
+	} {
+		err = tx.InsertEdge("people", e.from, e.toType, e.to, e.key, e.position, []byte(e.meta))
+		if err != nil {
+			tx.Close()
+			t.Fatal(err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	/////////////////////////////////////// TESTS
+
+	// export
+	var exported bytes.Buffer
+
+	var vertices, edges int64
+	err = Export(ctx, src, &exported, func(v, e int64) { vertices, edges = v, e })
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if vertices != 4 || edges != 4 {
+		t.Errorf("Exported %d vertices and %d edges, want 4 and 4", vertices, edges)
+	}
+	if n := bytes.Count(exported.Bytes(), []byte("\n")); n != 7 {
+		t.Errorf("Exported %d lines, want 7", n)
+	}
+
+	// import, in batches of less than the lines
+	err = Import(ctx, dst, bytes.NewReader(exported.Bytes()), 4, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// re-export
+	var reexported bytes.Buffer
+
+	err = Export(ctx, dst, &reexported, nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !bytes.Equal(exported.Bytes(), reexported.Bytes()) {
+		t.Errorf("Re-exported:\n%s\nwant:\n%s", reexported.String(), exported.String())
+	}
+
+	// positions and edge meta
+	tx, err = dst.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	edge, err := tx.FindEdge("people", "1", "people", "3", "friends")
+	if err != nil {
+		t.Error(err)
+	} else if edge.Position != 2 || string(edge.Meta) != `{"since":2019}` {
+		t.Errorf("Got edge at %d with meta %s, want at 2 with meta {\"since\":2019}", edge.Position, edge.Meta)
+	}
+
+	// cardinality, of which a to-one relationship is a resource
+	for key, want := range map[string]graph.Cardinality{"friends": graph.ToMany, "manager": graph.ToOne, "groups": ""} {
+		if cardinality, err := tx.FindEdgeKey("people", key); err != nil {
+			t.Error(err)
+		} else if cardinality != want {
+			t.Errorf("Got cardinality %q of %s, want %q", cardinality, key, want)
+		}
+	}
+
+	vertex, err := tx.FindVertex("people", "1")
+	if err != nil {
+		t.Error(err)
+	} else if string(vertex.Meta) != `{"source":"test"}` {
+		t.Errorf("Got vertex meta %s, want {\"source\":\"test\"}", vertex.Meta)
+	}
+
+	last, err := tx.FindLastChangeID()
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	document, errObj := model.GetRelationship(ctx, dst, "people", "3", "manager", url.URL{}, model.QueryParams{})
+	if errObj != nil {
+		t.Error(errObj.Detail)
+	} else if _, ok := document.Data.(core.Resource); !ok {
+		t.Errorf("Got data of %T for a to-one relationship, want a resource", document.Data)
+	}
+
+	// import again, changing nothing
+	err = Import(ctx, dst, bytes.NewReader(exported.Bytes()), 100, func(v, e int64) { vertices, edges = v, e })
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if vertices != 0 || edges != 0 {
+		t.Errorf("Re-imported %d vertices and %d edges, want 0 and 0", vertices, edges)
+	}
+
+	tx, err = dst.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	id, err := tx.FindLastChangeID()
+	tx.Close()
+	if err != nil {
+		t.Fatal(err)
+	} else if id != last {
+		t.Errorf("Got last change %d after re-import, want %d", id, last)
+	}
+
+	// edges to missing vertices
+	err = Import(ctx, dst, bytes.NewReader([]byte(`{"type":"people","id":"1","relationships":{"friends":{"data":[{"type":"people","id":"9"}]}}}`)), 1, nil)
+	if err == nil {
+		t.Error("Imported edge to missing vertex, want error")
+	}
+
+	// vertices without attributes
+	err = Import(ctx, dst, bytes.NewReader([]byte("{\"type\":\"robots\",\"id\":\"r1\"}\n{\"type\":\"robots\",\"id\":\"r2\",\"attributes\":null}\n")), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = dst.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, id := range []string{"r1", "r2"} {
+		if v, err := tx.FindVertex("robots", id); err != nil {
+			t.Fatal(err)
+		} else if string(v.Attributes) != `{}` {
+			t.Errorf("Got attributes %s for %s, want {}", v.Attributes, id)
+		}
+	}
+	tx.Close()
+
+	// vertices with attributes not an object
+	err = Import(ctx, dst, bytes.NewReader([]byte("{\"type\":\"robots\",\"id\":\"r3\"}\n{\"type\":\"robots\",\"id\":\"r4\",\"attributes\":[1]}\n")), 10, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("Got %v for attributes not an object, want error on line 2", err)
+	}
+
+	// vertices, in order
+	tx, err = dst.Transaction(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer tx.Close()
+
+	found, err := tx.FindVertices("people", nil, nil, graph.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(found) != 3 || found[0].Identifier != "1" || found[2].Identifier != "3" {
+		t.Errorf("Got %d people, want 3 in order", len(found))
+	}
+}
@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Resources holds JSON schemas for the attributes and meta members of
+// resource objects, by resource type.  A nil *Resources holds none.
+type Resources struct {
+	Attributes map[string]*gojsonschema.Schema
+	Meta       map[string]*gojsonschema.Schema
+}
+
+// LoadResources loads the JSON schemas at the root of fsys, whether a
+// directory (os.DirFS) or embedded (embed.FS).  Schemas for the attributes
+// of resources of a type are named <type>.attributes.json, and those for
+// meta <type>.meta.json.  Other files are ignored.
+func LoadResources(fsys fs.FS) (*Resources, error) {
+
+	resources := &Resources{
+		Attributes: make(map[string]*gojsonschema.Schema),
+		Meta:       make(map[string]*gojsonschema.Schema),
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		var (
+			name    = strings.TrimSuffix(entry.Name(), ".json")
+			schemas map[string]*gojsonschema.Schema
+		)
+
+		switch path.Ext(name) {
+		case ".attributes":
+			schemas = resources.Attributes
+		case ".meta":
+			schemas = resources.Meta
+		default:
+			continue
+		}
+
+		b, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		schemas[strings.TrimSuffix(name, path.Ext(name))] = s
+	}
+
+	return resources, nil
+}
+
+// ValidateAttributes validates the attributes of a resource of type t
+// against the schema for that type, returning the violations, if any.
+func (r *Resources) ValidateAttributes(t string, attributes interface{}) ([]gojsonschema.ResultError, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return validateAgainst(r.Attributes[t], attributes)
+}
+
+// ValidateMeta validates the meta of a resource of type t against the
+// schema for that type, returning the violations, if any.
+func (r *Resources) ValidateMeta(t string, meta interface{}) ([]gojsonschema.ResultError, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return validateAgainst(r.Meta[t], meta)
+}
+
+func validateAgainst(s *gojsonschema.Schema, document interface{}) ([]gojsonschema.ResultError, error) {
+
+	if s == nil {
+		return nil, nil
+	}
+
+	// A missing member is validated as an empty object
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	result, err := s.Validate(gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Errors(), nil
+}
+
+// Pointer returns a JSON Pointer (RFC 6901) to the value at fault for e,
+// relative to prefix, the pointer to the document validated.  For a missing
+// required property, this is the property.
+func Pointer(prefix string, e gojsonschema.ResultError) string {
+
+	const del = "\x00"
+
+	var b strings.Builder
+	b.WriteString(prefix)
+
+	segments := strings.Split(e.Context().String(del), del)
+	if e.Type() == "required" {
+		if property, ok := e.Details()["property"].(string); ok {
+			segments = append(segments, property)
+		}
+	}
+
+	// The first segment is the root
+	for _, segment := range segments[1:] {
+		segment = strings.ReplaceAll(segment, "~", "~0")
+		segment = strings.ReplaceAll(segment, "/", "~1")
+		b.WriteString("/")
+		b.WriteString(segment)
+	}
+
+	return b.String()
+}
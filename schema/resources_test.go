@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadResources(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"foo.attributes.json": &fstest.MapFile{
+			Data: []byte(`{"type":"object","required":["a"],"properties":{"a":{"type":"string"},"b/c":{"type":"integer"}}}`),
+		},
+		"foo.meta.json": &fstest.MapFile{
+			Data: []byte(`{"type":"object","additionalProperties":false}`),
+		},
+		"README.md": &fstest.MapFile{
+			Data: []byte(`not a schema`),
+		},
+	}
+
+	r, err := LoadResources(fsys)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(r.Attributes) != 1 || len(r.Meta) != 1 {
+		t.Fatalf("Got %d and %d schemas, want 1 and 1", len(r.Attributes), len(r.Meta))
+	}
+
+	// valid attributes
+	if errs, err := r.ValidateAttributes("foo", map[string]interface{}{"a": "x"}); err != nil {
+		t.Fatal(err)
+	} else if len(errs) != 0 {
+		t.Fatalf("Got %v, want none", errs)
+	}
+
+	// missing required attribute and wrong type, each a violation
+	errs, err := r.ValidateAttributes("foo", map[string]interface{}{"b/c": "x"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(errs) != 2 {
+		t.Fatalf("Got %v, want 2 violations", errs)
+	}
+	pointers := map[string]bool{}
+	for _, e := range errs {
+		pointers[Pointer("/data/attributes", e)] = true
+	}
+	for _, want := range []string{"/data/attributes/a", "/data/attributes/b~1c"} {
+		if !pointers[want] {
+			t.Errorf("Got %v, want %s", pointers, want)
+		}
+	}
+
+	// meta
+	if errs, err := r.ValidateMeta("foo", map[string]interface{}{"x": 1}); err != nil {
+		t.Fatal(err)
+	} else if len(errs) != 1 {
+		t.Fatalf("Got %v, want 1 violation", errs)
+	}
+
+	// type without schema
+	if errs, err := r.ValidateAttributes("bar", map[string]interface{}{"b/c": "x"}); err != nil {
+		t.Fatal(err)
+	} else if len(errs) != 0 {
+		t.Fatalf("Got %v, want none", errs)
+	}
+
+	// invalid schema
+	fsys["bar.attributes.json"] = &fstest.MapFile{Data: []byte(`{"type":1}`)}
+	if _, err := LoadResources(fsys); err == nil {
+		t.Fatal("Got nil, want error")
+	}
+}
@@ -0,0 +1,489 @@
+// Package webhook delivers the events of the change feed to the webhooks
+// subscribed to them.  Subscriptions are resources, of a type reserved for
+// them, managed as any other.  A Worker consumes the outbox of a graph,
+// queues a delivery for each event to each subscription matching it, and
+// POSTs each delivery, signed with the secret of its subscription,
+// retrying with exponential backoff until delivered or out of attempts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+	"github.com/wamuir/go-jsonapi-server/model"
+)
+
+// Headers of a delivery.  The signature is of the timestamp (in seconds
+// since the Unix epoch) and the payload, joined by a period, as the
+// hex-encoded HMAC-SHA256 keyed by the secret of the subscription, prefixed
+// with sha256=.
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Name of the consumer of the outbox queuing deliveries, and the lease of
+// its claims, which are acked within the transaction in which they are made.
+const (
+	consumer = "webhooks"
+	lease    = time.Minute
+)
+
+// Maximum number of changes, subscriptions and deliveries read at once.
+const batch = 100
+
+// ErrNotAllowed is the error of a subscription to a URL not allowed by the
+// hosts of a Worker.
+var ErrNotAllowed = errors.New("url is not of an allowed host, by http or https")
+
+// Subscription is a webhook subscription to the events of Events (or of all
+// events, if none) for resources of ResourceType, or for their relationship
+// keyed Relationship only, if given.  It is the resource of type Type and
+// identifier ID, with the other fields as its attributes.
+type Subscription struct {
+	Type         string   `json:"-"`
+	ID           string   `json:"-"`
+	URL          string   `json:"url"`
+	Secret       string   `json:"secret"`
+	ResourceType string   `json:"resourceType"`
+	Relationship string   `json:"relationship"`
+	Events       []string `json:"events"`
+}
+
+// Reports whether the subscription is to event.
+func (s Subscription) matches(event model.Event) bool {
+
+	if s.ResourceType != event.Type || s.Relationship != event.Relationship && s.Relationship != "" {
+		return false
+	}
+
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, name := range s.Events {
+		if name == event.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature of payload, sent at timestamp (in seconds since
+// the Unix epoch), by secret, as given by HeaderSignature.
+func Sign(secret string, timestamp int64, payload []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker delivers the events of the outbox of Graph to subscriptions,
+// the resources of Type.  A delivery is attempted up to Attempts times, at
+// first once due and then after Backoff, doubling for each attempt after,
+// up to MaxBackoff, before it is dead.  Deliveries are made only to URLs of
+// scheme http or https and of a host among Hosts, where a host prefixed by
+// "*." is any of its subdomains, and redirects are not followed; other
+// subscriptions are skipped.
+type Worker struct {
+	Graph      graph.Graph
+	Type       string
+	Hosts      []string
+	Client     *http.Client
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Stderr     *log.Logger
+	Stdout     *log.Logger
+	Now        func() time.Time // Defaults to time.Now
+}
+
+// Run polls at each interval, until ctx is done.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := w.Poll(ctx)
+		if err != nil {
+			w.Stderr.Print(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll queues deliveries for changes committed since the last poll and
+// attempts those due.
+func (w *Worker) Poll(ctx context.Context) error {
+
+	err := w.Queue(ctx)
+	if err != nil {
+		return err
+	}
+
+	return w.Deliver(ctx)
+}
+
+// Queue queues a delivery for each event of a change committed since the
+// last call, for each subscription to it, exactly once.  The first call
+// registers the worker as a consumer of the outbox, from the first change
+// then in it.
+func (w *Worker) Queue(ctx context.Context) error {
+
+	for {
+		more, err := w.queue(ctx)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+// Queue deliveries for the next batch of events, reporting whether there
+// may be more.
+func (w *Worker) queue(ctx context.Context) (bool, error) {
+
+	transaction, err := w.Graph.Transaction(ctx, false)
+	if err != nil {
+		return false, err
+	}
+	defer transaction.Close()
+
+	var tx *model.Tx = &model.Tx{Tx: transaction}
+
+	claim, err := tx.ClaimMessages(consumer, batch, lease)
+	if err == graph.ErrClaimed {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else if len(claim.Messages) == 0 {
+		return false, tx.Commit()
+	}
+
+	subscriptions, err := w.subscriptions(tx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, message := range claim.Messages {
+
+		event, errObj := model.NewEvent(message)
+		if errObj != nil {
+			return false, errors.New(errObj.Detail)
+		}
+
+		// Subscriptions are not themselves subscribed to, as they hold
+		// their secrets
+		if event.Type == w.Type {
+			continue
+		}
+
+		event.Document.Meta["event"] = event.Name
+
+		payload, err := json.Marshal(event.Document)
+		if err != nil {
+			return false, err
+		}
+
+		for _, subscription := range subscriptions {
+
+			if !subscription.matches(event) {
+				continue
+			}
+
+			err = tx.InsertDelivery(graph.Delivery{
+				SubscriptionType: subscription.Type,
+				SubscriptionID:   subscription.ID,
+				ChangeID:         event.ID,
+				Event:            event.Name,
+				Payload:          payload,
+				NextAttempt:      w.now(),
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	err = tx.AckMessages(claim)
+	if err != nil {
+		return false, err
+	}
+
+	return len(claim.Messages) == batch, tx.Commit()
+}
+
+// Returns the subscriptions, as found within tx.
+func (w *Worker) subscriptions(tx *model.Tx) ([]Subscription, error) {
+
+	var subscriptions []Subscription
+
+	for page := (graph.Page{Limit: batch}); ; page.Offset += batch {
+
+		vertices, err := tx.FindVertices(w.Type, nil, nil, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, vertex := range vertices {
+			subscription, err := w.newSubscription(vertex)
+			if err != nil {
+				w.Stderr.Printf("Skipped webhook %s: %s", vertex.Identifier, err.Error())
+				continue
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+
+		if len(vertices) < batch {
+			return subscriptions, nil
+		}
+	}
+}
+
+// Returns the subscription that is vertex, or ErrNotAllowed if its URL is
+// not allowed.
+func (w *Worker) newSubscription(vertex graph.Vertex) (Subscription, error) {
+
+	var subscription Subscription
+
+	err := json.Unmarshal(vertex.Attributes, &subscription)
+	if err != nil {
+		return subscription, err
+	} else if subscription.URL == "" || subscription.ResourceType == "" {
+		return subscription, errors.New("url and resourceType are required")
+	} else if !w.allows(subscription.URL) {
+		return subscription, ErrNotAllowed
+	}
+
+	subscription.Type, subscription.ID = vertex.Type, vertex.Identifier
+
+	return subscription, nil
+}
+
+// Reports whether rawURL is of scheme http or https and of a host among the
+// hosts of the worker.
+func (w *Worker) allows(rawURL string) bool {
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	for _, allowed := range w.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Deliver attempts each delivery due, logging each attempt.
+func (w *Worker) Deliver(ctx context.Context) error {
+
+	for {
+		deliveries, err := w.due(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			err = w.deliver(ctx, delivery)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < batch {
+			return nil
+		}
+	}
+}
+
+// Claims the next batch of deliveries due, so that no other worker attempts
+// them, until the lease of the claim ends.  The lease is long enough for
+// each delivery to be attempted in turn, to the timeout of the client; a
+// delivery not attempted by then, as by a worker stopped, is due again.
+func (w *Worker) due(ctx context.Context) ([]graph.Delivery, error) {
+
+	tx, err := w.Graph.Transaction(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+
+	timeout := lease
+	if w.Client != nil && w.Client.Timeout > 0 {
+		timeout = w.Client.Timeout
+	}
+
+	at := w.now()
+
+	deliveries, err := tx.ClaimDueDeliveries(at, at.Add(batch*timeout), batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// Attempt delivery, then record the attempt and the state of delivery.  The
+// graph is not held by a transaction during the attempt.
+func (w *Worker) deliver(ctx context.Context, delivery graph.Delivery) error {
+
+	attempt := graph.DeliveryAttempt{DeliveryID: delivery.ID}
+
+	subscription, err := w.subscription(ctx, delivery)
+	if err == nil {
+		attempt.StatusCode, err = w.post(ctx, subscription, delivery)
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	attempt.Time = w.now()
+	delivery.Attempts++
+
+	switch {
+	case err == nil && attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		delivery.State = graph.Delivered
+		w.Stdout.Printf("Delivered webhook %d to %s/%s", delivery.ID, delivery.SubscriptionType, delivery.SubscriptionID)
+	case delivery.Attempts >= w.Attempts || err == graph.ErrNoRows || err == ErrNotAllowed:
+		delivery.State = graph.Dead
+		w.Stderr.Printf("Dead webhook %d to %s/%s after %d attempts", delivery.ID, delivery.SubscriptionType, delivery.SubscriptionID, delivery.Attempts)
+	default:
+		delivery.State = graph.Pending
+		delivery.NextAttempt = attempt.Time.Add(w.backoff(delivery.Attempts))
+	}
+
+	if delivery.State != graph.Pending {
+		delivery.NextAttempt = attempt.Time
+	}
+
+	tx, err := w.Graph.Transaction(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	err = tx.InsertDeliveryAttempt(attempt)
+	if err != nil {
+		return err
+	}
+
+	err = tx.UpdateDelivery(delivery)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns the subscription of delivery, or graph.ErrNoRows if it is no
+// longer, or ErrNotAllowed if its URL is no longer allowed.
+func (w *Worker) subscription(ctx context.Context, delivery graph.Delivery) (Subscription, error) {
+
+	tx, err := w.Graph.Transaction(ctx, true)
+	if err != nil {
+		return Subscription{}, err
+	}
+	defer tx.Close()
+
+	vertex, err := tx.FindVertex(delivery.SubscriptionType, delivery.SubscriptionID)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	return w.newSubscription(vertex)
+}
+
+// POST the payload of delivery to subscription, returning the status code
+// of the response.
+func (w *Worker) post(ctx context.Context, subscription Subscription, delivery graph.Delivery) (int, error) {
+
+	timestamp := w.now().Unix()
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+	r.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	r.Header.Set(HeaderEvent, delivery.Event)
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	r.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, delivery.Payload))
+
+	client := *http.DefaultClient
+	if w.Client != nil {
+		client = *w.Client
+	}
+
+	// A redirect, to a host perhaps not allowed, is not accepted
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	o, err := client.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	defer o.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, o.Body)
+
+	return o.StatusCode, nil
+}
+
+// Returns the backoff after attempts.
+func (w *Worker) backoff(attempts int) time.Duration {
+
+	backoff := w.Backoff
+
+	for i := 1; i < attempts && backoff < w.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > w.MaxBackoff && w.MaxBackoff > 0 {
+		backoff = w.MaxBackoff
+	}
+
+	return backoff
+}
+
+func (w *Worker) now() time.Time {
+
+	if w.Now == nil {
+		return time.Now()
+	}
+
+	return w.Now()
+}
@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wamuir/go-jsonapi-server/graph"
+	memory "github.com/wamuir/go-jsonapi-server/graph/memory"
+)
+
+func TestWorker(t *testing.T) {
+
+	/////////////////////////////////////// SETUP
+
+	// graph
+	g := memory.New()
+	defer g.Close()
+
+	// open /dev/null for logging to nowhere
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer devnull.Close()
+
+	// receivers, of which one fails
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderSignature) != Sign("s3cr3t", timestamp, payload) {
+			t.Errorf("Got signature %s, want valid signature", r.Header.Get(HeaderSignature))
+		}
+		mu.Lock()
+		received = append(received, r.Header.Get(HeaderEvent))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	clock := time.Now()
+
+	worker := &Worker{
+		Graph:      g,
+		Type:       "webhooks",
+		Hosts:      []string{"127.0.0.1"},
+		Client:     receiver.Client(),
+		Attempts:   3,
+		Backoff:    time.Minute,
+		MaxBackoff: time.Hour,
+		Stderr:     log.New(devnull, "", 0),
+		Stdout:     log.New(devnull, "", 0),
+		Now:        func() time.Time { return clock },
+	}
+
+	ctx := context.Background()
+
+	// follow from here
+	if err := worker.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, _ := g.Transaction(ctx, false)
+	_ = tx.InsertVertex("webhooks", "a", []byte(`{"url":"`+receiver.URL+`","secret":"s3cr3t","resourceType":"foo","events":["create"]}`), nil)
+	_ = tx.InsertVertex("webhooks", "b", []byte(`{"url":"`+receiver.URL+`","secret":"s3cr3t","resourceType":"foo","relationship":"bar"}`), nil)
+	_ = tx.InsertVertex("webhooks", "c", []byte(`{"url":"`+failing.URL+`","secret":"s3cr3t","resourceType":"baz"}`), nil)
+	_ = tx.InsertVertex("webhooks", "d", []byte(`{"url":"http://169.254.169.254/","secret":"s3cr3t","resourceType":"foo"}`), nil)
+	_ = tx.InsertVertex("foo", "1", []byte(`{"a":"b"}`), nil)
+	_ = tx.UpdateVertex("foo", "1", 0, []byte(`{"a":"c"}`), nil)
+	_ = tx.InsertVertex("baz", "2", nil, nil)
+	_ = tx.InsertEdge("foo", "1", "baz", "2", "bar", 0, nil)
+	_ = tx.Commit()
+	tx.Close()
+
+	// Returns the deliveries to subscription c
+	deliveries := func() []graph.Delivery {
+		tx, _ := g.Transaction(ctx, true)
+		defer tx.Close()
+		d, err := tx.FindDeliveries("webhooks", "c", graph.Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(d) != 1 {
+			t.Fatalf("Got %d deliveries, want 1", len(d))
+		}
+		return d
+	}
+
+	/////////////////////////////////////// TESTS
+
+	if err := worker.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if len(received) != 2 || received[0] != "create" || received[1] != "add" {
+		t.Errorf("Got %v, want [create add]", received)
+	}
+	mu.Unlock()
+
+	// None to a host not allowed
+	tx, _ = g.Transaction(ctx, true)
+	if d, _ := tx.FindDeliveries("webhooks", "d", graph.Page{Limit: 10}); len(d) != 0 {
+		t.Errorf("Got %d deliveries to a host not allowed, want 0", len(d))
+	}
+	tx.Close()
+
+	tests := []struct {
+		advance  time.Duration
+		state    graph.DeliveryState
+		attempts int
+	}{
+		{0, graph.Pending, 1},
+		{time.Minute - time.Second, graph.Pending, 1},
+		{time.Second, graph.Pending, 2},
+		{2 * time.Minute, graph.Dead, 3},
+		{time.Hour, graph.Dead, 3},
+	}
+
+	for n, test := range tests {
+
+		clock = clock.Add(test.advance)
+
+		if n > 0 {
+			if err := worker.Poll(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d := deliveries()[0]
+		if d.State != test.state || d.Attempts != test.attempts {
+			t.Errorf("%d: got %s after %d attempts, want %s after %d", n, d.State, d.Attempts, test.state, test.attempts)
+		}
+	}
+
+	// Delivery log
+	id := deliveries()[0].ID
+
+	tx, _ = g.Transaction(ctx, true)
+	defer tx.Close()
+
+	attempts, err := tx.FindDeliveryAttempts(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(attempts) != 3 {
+		t.Fatalf("Got %d attempts, want 3", len(attempts))
+	}
+	for _, attempt := range attempts {
+		if attempt.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Got %d, want %d", attempt.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	worker := &Worker{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	for attempts, want := range []time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if attempts == 0 || want == 0 {
+			continue
+		}
+		if got := worker.backoff(attempts); got != want {
+			t.Errorf("%d: got %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+
+	worker := &Worker{Hosts: []string{"hooks.example.com", "*.example.org"}}
+
+	for rawURL, want := range map[string]bool{
+		"https://hooks.example.com/a":     true,
+		"http://HOOKS.example.com:8080/a": true,
+		"https://a.example.org/":          true,
+		"https://a.b.example.org/":        true,
+		"https://example.org/":            false,
+		"https://badexample.org/":         false,
+		"https://example.com/":            false,
+		"ftp://hooks.example.com/":        false,
+		"http://169.254.169.254/":         false,
+		"http://hooks.example.com.evil/":  false,
+		"//hooks.example.com/":            false,
+		"https://hooks.example.com@evil/": false,
+	} {
+		if got := worker.allows(rawURL); got != want {
+			t.Errorf("%s: got %v, want %v", rawURL, got, want)
+		}
+	}
+}